@@ -14,6 +14,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/firewall"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/placement"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -25,11 +26,13 @@ type InstancePipe struct {
 }
 
 type InstanceInfo struct {
-	Node          string        `json:"node"`
-	Disks         []string      `json:"disks"`
-	FirewallRules []string      `json:"firewall_rules"`
-	Authorities   []string      `json:"authorities"`
-	UsbDevices    []*usb.Device `json:"usb_devices"`
+	Node               string        `json:"node"`
+	Disks              []string      `json:"disks"`
+	FirewallRules      []string      `json:"firewall_rules"`
+	Authorities        []string      `json:"authorities"`
+	UsbDevices         []*usb.Device `json:"usb_devices"`
+	Placement          string        `json:"placement"`
+	PlacementViolation bool          `json:"placement_violation"`
 }
 
 type InstanceAggregate struct {
@@ -120,6 +123,8 @@ func GetInstancePaged(db *database.Database, query *bson.M, page,
 	firesRoles := map[primitive.ObjectID]set.Set{}
 	authrsOrg := map[primitive.ObjectID]map[string][]*authority.Authority{}
 	authrsRoles := map[primitive.ObjectID]set.Set{}
+	grps := map[primitive.ObjectID]*placement.Group{}
+	grpsViolations := set.NewSet()
 
 	for cursor.Next(db) {
 		doc := &InstancePipe{}
@@ -152,6 +157,37 @@ func GetInstancePaged(db *database.Database, query *bson.M, page,
 			)
 		}
 
+		if !doc.PlacementGroup.IsZero() {
+			grp, ok := grps[doc.PlacementGroup]
+			if !ok {
+				grp, err = placement.Get(db, doc.PlacementGroup)
+				if err != nil {
+					if _, ok := err.(*database.NotFoundError); !ok {
+						return
+					}
+					err = nil
+					grp = nil
+				} else {
+					instIds, e := grp.GetViolations(db)
+					if e != nil {
+						err = e
+						return
+					}
+
+					for _, instId := range instIds {
+						grpsViolations.Add(instId)
+					}
+				}
+
+				grps[doc.PlacementGroup] = grp
+			}
+
+			if grp != nil {
+				info.Placement = grp.Name
+				info.PlacementViolation = grpsViolations.Contains(doc.Id)
+			}
+		}
+
 		fires := firesOrg[doc.Organization]
 		if fires == nil {
 			fires, err = firewall.GetOrgMapRoles(db, doc.Organization)
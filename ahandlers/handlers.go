@@ -55,6 +55,13 @@ func Register(engine *gin.Engine) {
 	csrfGroup.DELETE("/authority", authoritiesDelete)
 	csrfGroup.DELETE("/authority/:authority_id", authorityDelete)
 
+	csrfGroup.GET("/autoscale_group", autoscaleGroupsGet)
+	csrfGroup.GET("/autoscale_group/:group_id", autoscaleGroupGet)
+	csrfGroup.PUT("/autoscale_group/:group_id", autoscaleGroupPut)
+	csrfGroup.POST("/autoscale_group", autoscaleGroupPost)
+	csrfGroup.DELETE("/autoscale_group", autoscaleGroupsDelete)
+	csrfGroup.DELETE("/autoscale_group/:group_id", autoscaleGroupDelete)
+
 	csrfGroup.GET("/block", blocksGet)
 	csrfGroup.GET("/block/:block_id", blockGet)
 	csrfGroup.PUT("/block/:block_id", blockPut)
@@ -118,6 +125,13 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/instance", instancesPut)
 	csrfGroup.GET("/instance/:instance_id", instanceGet)
 	csrfGroup.PUT("/instance/:instance_id", instancePut)
+	csrfGroup.PUT("/instance/:instance_id/migrate", instanceMigratePut)
+	csrfGroup.DELETE("/instance/:instance_id/migrate", instanceMigrateDelete)
+	csrfGroup.GET("/instance/:instance_id/serial", instanceSerialGet)
+	csrfGroup.GET("/instance/:instance_id/serial_log", instanceSerialLogGet)
+	csrfGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
+	csrfGroup.POST("/instance/:instance_id/agent", instanceAgentPost)
+	csrfGroup.GET("/instance/:instance_id/metrics", instanceMetricsGet)
 	csrfGroup.POST("/instance", instancePost)
 	csrfGroup.DELETE("/instance", instancesDelete)
 	csrfGroup.DELETE("/instance/:instance_id", instanceDelete)
@@ -130,14 +144,31 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/node", nodesGet)
 	csrfGroup.GET("/node/:node_id", nodeGet)
 	csrfGroup.PUT("/node/:node_id", nodePut)
+	csrfGroup.GET("/node/:node_id/evacuate", nodeEvacuateGet)
+	csrfGroup.PUT("/node/:node_id/evacuate", nodeEvacuatePut)
 	csrfGroup.DELETE("/node/:node_id", nodeDelete)
 
 	csrfGroup.GET("/organization", organizationsGet)
 	csrfGroup.GET("/organization/:org_id", organizationGet)
+	csrfGroup.GET("/organization/:org_id/usage", organizationUsageGet)
 	csrfGroup.PUT("/organization/:org_id", organizationPut)
 	csrfGroup.POST("/organization", organizationPost)
 	csrfGroup.DELETE("/organization/:org_id", organizationDelete)
 
+	csrfGroup.GET("/placement_group", placementGroupsGet)
+	csrfGroup.GET("/placement_group/:group_id", placementGroupGet)
+	csrfGroup.PUT("/placement_group/:group_id", placementGroupPut)
+	csrfGroup.POST("/placement_group", placementGroupPost)
+	csrfGroup.DELETE("/placement_group", placementGroupsDelete)
+	csrfGroup.DELETE("/placement_group/:group_id", placementGroupDelete)
+
+	csrfGroup.GET("/elastic_ip", elasticIpsGet)
+	csrfGroup.GET("/elastic_ip/:eip_id", elasticIpGet)
+	csrfGroup.PUT("/elastic_ip/:eip_id", elasticIpPut)
+	csrfGroup.POST("/elastic_ip", elasticIpPost)
+	csrfGroup.DELETE("/elastic_ip", elasticIpsDelete)
+	csrfGroup.DELETE("/elastic_ip/:eip_id", elasticIpDelete)
+
 	csrfGroup.GET("/policy", policiesGet)
 	csrfGroup.GET("/policy/:policy_id", policyGet)
 	csrfGroup.PUT("/policy/:policy_id", policyPut)
@@ -160,6 +191,14 @@ func Register(engine *gin.Engine) {
 	csrfGroup.GET("/subscription/update", subscriptionUpdateGet)
 	csrfGroup.POST("/subscription", subscriptionPost)
 
+	csrfGroup.GET("/template", templatesGet)
+	csrfGroup.GET("/template/:template_id", templateGet)
+	csrfGroup.PUT("/template/:template_id", templatePut)
+	csrfGroup.POST("/template", templatePost)
+	csrfGroup.POST("/template/:template_id/launch", templateLaunchPost)
+	csrfGroup.DELETE("/template", templatesDelete)
+	csrfGroup.DELETE("/template/:template_id", templateDelete)
+
 	csrfGroup.PUT("/theme", themePut)
 
 	csrfGroup.GET("/user", usersGet)
@@ -173,6 +212,9 @@ func Register(engine *gin.Engine) {
 	csrfGroup.PUT("/vpc/:vpc_id", vpcPut)
 	csrfGroup.GET("/vpc/:vpc_id/routes", vpcRoutesGet)
 	csrfGroup.PUT("/vpc/:vpc_id/routes", vpcRoutesPut)
+	csrfGroup.GET("/vpc/:vpc_id/ips", vpcIpsGet)
+	csrfGroup.POST("/vpc/:vpc_id/ips", vpcIpPost)
+	csrfGroup.DELETE("/vpc/:vpc_id/ips/:ip_id", vpcIpDelete)
 	csrfGroup.POST("/vpc", vpcPost)
 	csrfGroup.DELETE("/vpc", vpcsDelete)
 	csrfGroup.DELETE("/vpc/:vpc_id", vpcDelete)
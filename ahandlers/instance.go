@@ -17,6 +17,8 @@ import (
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/scheduler"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
@@ -24,28 +26,40 @@ import (
 )
 
 type instanceData struct {
-	Id               primitive.ObjectID `json:"id"`
-	Organization     primitive.ObjectID `json:"organization"`
-	Zone             primitive.ObjectID `json:"zone"`
-	Vpc              primitive.ObjectID `json:"vpc"`
-	Subnet           primitive.ObjectID `json:"subnet"`
-	Node             primitive.ObjectID `json:"node"`
-	Image            primitive.ObjectID `json:"image"`
-	ImageBacking     bool               `json:"image_backing"`
-	Domain           primitive.ObjectID `json:"domain"`
-	Name             string             `json:"name"`
-	Comment          string             `json:"comment"`
-	State            string             `json:"state"`
-	DeleteProtection bool               `json:"delete_protection"`
-	InitDiskSize     int                `json:"init_disk_size"`
-	Memory           int                `json:"memory"`
-	Processors       int                `json:"processors"`
-	NetworkRoles     []string           `json:"network_roles"`
-	UsbDevices       []*usb.Device      `json:"usb_devices"`
-	Vnc              bool               `json:"vnc"`
-	NoPublicAddress  bool               `json:"no_public_address"`
-	NoHostAddress    bool               `json:"no_host_address"`
-	Count            int                `json:"count"`
+	Id               primitive.ObjectID  `json:"id"`
+	Organization     primitive.ObjectID  `json:"organization"`
+	Datacenter       primitive.ObjectID  `json:"datacenter"`
+	Zone             primitive.ObjectID  `json:"zone"`
+	Vpc              primitive.ObjectID  `json:"vpc"`
+	Subnet           primitive.ObjectID  `json:"subnet"`
+	StaticIp         string              `json:"static_ip"`
+	Adapters         []*instance.Adapter `json:"adapters"`
+	Node             primitive.ObjectID  `json:"node"`
+	PlacementGroup   primitive.ObjectID  `json:"placement_group"`
+	Image            primitive.ObjectID  `json:"image"`
+	ImageBacking     bool                `json:"image_backing"`
+	Domain           primitive.ObjectID  `json:"domain"`
+	Name             string              `json:"name"`
+	Comment          string              `json:"comment"`
+	State            string              `json:"state"`
+	DeleteProtection bool                `json:"delete_protection"`
+	Ha               bool                `json:"ha"`
+	InitDiskSize     int                 `json:"init_disk_size"`
+	Memory           int                 `json:"memory"`
+	Processors       int                 `json:"processors"`
+	NetworkRoles     []string            `json:"network_roles"`
+	UserData         string              `json:"user_data"`
+	UsbDevices       []*usb.Device       `json:"usb_devices"`
+	Vnc              bool                `json:"vnc"`
+	NoPublicAddress  bool                `json:"no_public_address"`
+	NoHostAddress    bool                `json:"no_host_address"`
+	Firmware         string              `json:"firmware"`
+	Tpm              bool                `json:"tpm"`
+	Count            int                 `json:"count"`
+}
+
+type instanceMigrateData struct {
+	Node primitive.ObjectID `json:"node"`
 }
 
 type instanceMultiData struct {
@@ -90,38 +104,53 @@ func instancePut(c *gin.Context) {
 	inst.Comment = dta.Comment
 	inst.Vpc = dta.Vpc
 	inst.Subnet = dta.Subnet
+	inst.StaticIp = dta.StaticIp
+	inst.Adapters = dta.Adapters
 	if dta.State != "" {
 		inst.State = dta.State
 	}
 	inst.DeleteProtection = dta.DeleteProtection
+	inst.Ha = dta.Ha
 	inst.Memory = dta.Memory
 	inst.Processors = dta.Processors
 	inst.NetworkRoles = dta.NetworkRoles
+	inst.UserData = dta.UserData
 	inst.UsbDevices = dta.UsbDevices
 	inst.Vnc = dta.Vnc
 	inst.Domain = dta.Domain
+	inst.PlacementGroup = dta.PlacementGroup
 	inst.NoPublicAddress = dta.NoPublicAddress
 	inst.NoHostAddress = dta.NoHostAddress
+	inst.Firmware = dta.Firmware
+	inst.Tpm = dta.Tpm
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"vpc",
 		"subnet",
+		"static_ip",
+		"adapters",
 		"state",
 		"restart",
+		"restart_reason",
 		"restart_block_ip",
 		"delete_protection",
+		"ha",
 		"memory",
 		"processors",
 		"network_roles",
+		"user_data",
 		"usb_devices",
 		"vnc",
 		"vnc_display",
 		"vnc_password",
 		"domain",
+		"placement_group",
 		"no_public_address",
 		"no_host_address",
+		"firmware",
+		"tpm",
 	)
 
 	errData, err := inst.Validate(db)
@@ -171,6 +200,12 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
+	instanceCreate(c, db, dta)
+}
+
+func instanceCreate(c *gin.Context, db *database.Database,
+	dta *instanceData) {
+
 	img, err := image.GetOrgPublic(db, dta.Organization, dta.Image)
 	if err != nil {
 		if _, ok := err.(*database.NotFoundError); ok {
@@ -213,12 +248,56 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
+	var schd *scheduler.Scheduler
+	if !dta.Node.IsZero() {
+		nde, err := node.Get(db, dta.Node)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		if nde.Maintenance {
+			errData := &errortypes.ErrorData{
+				Error:   "node_maintenance",
+				Message: "Node is in maintenance",
+			}
+			c.JSON(400, errData)
+			return
+		}
+	} else {
+		if !dta.Zone.IsZero() {
+			schd, err = scheduler.NewZone(db, dta.Zone)
+		} else if !dta.Datacenter.IsZero() {
+			schd, err = scheduler.NewDatacenter(db, dta.Datacenter)
+		}
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	insts := []*instance.Instance{}
 
 	if dta.Count == 0 {
 		dta.Count = 1
 	}
 
+	staticIp := dta.StaticIp != ""
+	for _, adapter := range dta.Adapters {
+		if adapter.StaticIp != "" {
+			staticIp = true
+		}
+	}
+
+	if dta.Count > 1 && staticIp {
+		errData := &errortypes.ErrorData{
+			Error:   "static_ip_count",
+			Message: "Static private IP cannot be used with multiple instances",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	for i := 0; i < dta.Count; i++ {
 		name := ""
 		if strings.Contains(dta.Name, "%") {
@@ -233,21 +312,44 @@ func instancePost(c *gin.Context) {
 			Zone:             dta.Zone,
 			Vpc:              dta.Vpc,
 			Subnet:           dta.Subnet,
+			StaticIp:         dta.StaticIp,
+			Adapters:         dta.Adapters,
 			Node:             dta.Node,
+			PlacementGroup:   dta.PlacementGroup,
 			Image:            dta.Image,
 			ImageBacking:     dta.ImageBacking,
 			DeleteProtection: dta.DeleteProtection,
+			Ha:               dta.Ha,
 			Name:             name,
 			Comment:          dta.Comment,
 			InitDiskSize:     dta.InitDiskSize,
 			Memory:           dta.Memory,
 			Processors:       dta.Processors,
 			NetworkRoles:     dta.NetworkRoles,
+			UserData:         dta.UserData,
 			UsbDevices:       dta.UsbDevices,
 			Vnc:              dta.Vnc,
 			Domain:           dta.Domain,
 			NoPublicAddress:  dta.NoPublicAddress,
 			NoHostAddress:    dta.NoHostAddress,
+			Firmware:         dta.Firmware,
+			Tpm:              dta.Tpm,
+		}
+
+		if schd != nil {
+			nde, errData, err := schd.ScheduleInstance(db, inst)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if errData != nil {
+				c.JSON(400, errData)
+				return
+			}
+
+			inst.Node = nde.Id
+			inst.Zone = nde.Zone
 		}
 
 		errData, err := inst.Validate(db)
@@ -299,6 +401,7 @@ func instancesPut(c *gin.Context) {
 
 	if dta.State != instance.Start {
 		doc["restart"] = false
+		doc["restart_reason"] = ""
 		doc["restart_block_ip"] = false
 	}
 
@@ -313,6 +416,97 @@ func instancesPut(c *gin.Context) {
 	c.JSON(200, nil)
 }
 
+func instanceMigratePut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	dta := &instanceMigrateData{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(dta)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	nde, err := node.Get(db, dta.Node)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			errData := &errortypes.ErrorData{
+				Error:   "migrate_node_invalid",
+				Message: "Invalid migration node",
+			}
+			c.JSON(400, errData)
+		} else {
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	errData, err := inst.Migrate(db, nde)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, inst)
+}
+
+func instanceMigrateDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.Get(db, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData, err := inst.MigrateCancel(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, inst)
+}
+
 func instanceDelete(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
@@ -0,0 +1,58 @@
+package ahandlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/metric"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func getMetricRange(c *gin.Context) (resolution string,
+	start, end time.Time) {
+
+	resolution = c.Query("resolution")
+	if resolution != metric.Hour {
+		resolution = metric.Minute
+	}
+
+	end = time.Now()
+	endUnix, _ := strconv.ParseInt(c.Query("end"), 10, 64)
+	if endUnix > 0 {
+		end = time.Unix(endUnix, 0)
+	}
+
+	if resolution == metric.Hour {
+		start = end.Add(-168 * time.Hour)
+	} else {
+		start = end.Add(-1 * time.Hour)
+	}
+	startUnix, _ := strconv.ParseInt(c.Query("start"), 10, 64)
+	if startUnix > 0 {
+		start = time.Unix(startUnix, 0)
+	}
+
+	return
+}
+
+func instanceMetricsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	resolution, start, end := getMetricRange(c)
+
+	metrics, err := metric.GetRange(db, instanceId, resolution, start, end)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, metrics)
+}
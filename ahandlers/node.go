@@ -13,7 +13,9 @@ import (
 	"github.com/pritunl/pritunl-cloud/demo"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/scheduler"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -40,6 +42,7 @@ type nodeData struct {
 	HostNatExcludes      []string                `json:"host_nat_excludes"`
 	JumboFrames          bool                    `json:"jumbo_frames"`
 	UsbPassthrough       bool                    `json:"usb_passthrough"`
+	Maintenance          bool                    `json:"maintenance"`
 	ForwardedForHeader   string                  `json:"forwarded_for_header"`
 	ForwardedProtoHeader string                  `json:"forwarded_proto_header"`
 	Firewall             bool                    `json:"firewall"`
@@ -48,6 +51,11 @@ type nodeData struct {
 	OracleHostRoute      bool                    `json:"oracle_host_route"`
 }
 
+type nodeEvacuateData struct {
+	Instances []*instance.Instance `json:"instances"`
+	Count     int                  `json:"count"`
+}
+
 type nodesData struct {
 	Nodes []*node.Node `json:"nodes"`
 	Count int64        `json:"count"`
@@ -98,6 +106,7 @@ func nodePut(c *gin.Context) {
 	nde.HostNatExcludes = data.HostNatExcludes
 	nde.JumboFrames = data.JumboFrames
 	nde.UsbPassthrough = data.UsbPassthrough
+	nde.Maintenance = data.Maintenance
 	nde.ForwardedForHeader = data.ForwardedForHeader
 	nde.ForwardedProtoHeader = data.ForwardedProtoHeader
 	nde.Firewall = data.Firewall
@@ -126,6 +135,7 @@ func nodePut(c *gin.Context) {
 		"host_nat_excludes",
 		"jumbo_frames",
 		"usb_passthrough",
+		"maintenance",
 		"forwarded_for_header",
 		"forwarded_proto_header",
 		"firewall",
@@ -168,6 +178,79 @@ func nodePut(c *gin.Context) {
 	c.JSON(200, nde)
 }
 
+func nodeEvacuatePut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	nodeId, ok := utils.ParseObjectId(c.Param("node_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	nde, err := node.Get(db, nodeId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if !nde.Maintenance {
+		errData := &errortypes.ErrorData{
+			Error:   "node_not_maintenance",
+			Message: "Node must be in maintenance to evacuate",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
+	insts, err := scheduler.Evacuate(db, nde)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, inst := range insts {
+		inst.Json()
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, &nodeEvacuateData{
+		Instances: insts,
+		Count:     len(insts),
+	})
+}
+
+func nodeEvacuateGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	nodeId, ok := utils.ParseObjectId(c.Param("node_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"node": nodeId,
+	})
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, inst := range insts {
+		inst.Json()
+	}
+
+	c.JSON(200, &nodeEvacuateData{
+		Instances: insts,
+		Count:     len(insts),
+	})
+}
+
 func nodeDelete(c *gin.Context) {
 	if demo.Blocked(c) {
 		return
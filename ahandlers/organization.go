@@ -12,9 +12,15 @@ import (
 )
 
 type organizationData struct {
-	Id    primitive.ObjectID `json:"id"`
-	Name  string             `json:"name"`
-	Roles []string           `json:"roles"`
+	Id    primitive.ObjectID  `json:"id"`
+	Name  string              `json:"name"`
+	Roles []string            `json:"roles"`
+	Quota *organization.Quota `json:"quota"`
+}
+
+type organizationUsageData struct {
+	Quota *organization.Quota `json:"quota"`
+	Usage *organization.Usage `json:"usage"`
 }
 
 func organizationPut(c *gin.Context) {
@@ -51,6 +57,11 @@ func organizationPut(c *gin.Context) {
 		"roles",
 	)
 
+	if data.Quota != nil {
+		org.Quota = data.Quota
+		fields.Add("quota")
+	}
+
 	errData, err := org.Validate(db)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
@@ -92,6 +103,7 @@ func organizationPost(c *gin.Context) {
 	org := &organization.Organization{
 		Name:  data.Name,
 		Roles: data.Roles,
+		Quota: data.Quota,
 	}
 
 	errData, err := org.Validate(db)
@@ -158,6 +170,38 @@ func organizationGet(c *gin.Context) {
 	c.JSON(200, org)
 }
 
+func organizationUsageGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	orgId, ok := utils.ParseObjectId(c.Param("org_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	org, err := organization.Get(db, orgId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usage, err := organization.GetUsage(db, org.Id)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	quota := org.Quota
+	if quota == nil {
+		quota = &organization.Quota{}
+	}
+
+	c.JSON(200, &organizationUsageData{
+		Quota: quota,
+		Usage: usage,
+	})
+}
+
 func organizationsGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 
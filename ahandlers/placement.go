@@ -0,0 +1,268 @@
+package ahandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type placementGroupData struct {
+	Id           primitive.ObjectID `json:"id"`
+	Name         string             `json:"name"`
+	Organization primitive.ObjectID `json:"organization"`
+	Policy       string             `json:"policy"`
+}
+
+type placementGroupsData struct {
+	PlacementGroups []*placement.Group `json:"placement_groups"`
+	Count           int64              `json:"count"`
+}
+
+func placementGroupPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &placementGroupData{}
+
+	grpId, ok := utils.ParseObjectId(c.Param("group_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp, err := placement.Get(db, grpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp.Name = data.Name
+	grp.Organization = data.Organization
+	grp.Policy = data.Policy
+
+	fields := set.NewSet(
+		"name",
+		"organization",
+		"policy",
+	)
+
+	errData, err := grp.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = grp.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp.Violations, err = grp.GetViolations(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "placement_group.change")
+
+	c.JSON(200, grp)
+}
+
+func placementGroupPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &placementGroupData{
+		Name:   "New Placement Group",
+		Policy: placement.Spread,
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp := &placement.Group{
+		Name:         data.Name,
+		Organization: data.Organization,
+		Policy:       data.Policy,
+	}
+
+	errData, err := grp.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = grp.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp.Violations = []primitive.ObjectID{}
+
+	event.PublishDispatch(db, "placement_group.change")
+
+	c.JSON(200, grp)
+}
+
+func placementGroupDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	grpId, ok := utils.ParseObjectId(c.Param("group_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := placement.Remove(db, grpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "placement_group.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func placementGroupsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = placement.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "placement_group.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func placementGroupGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	grpId, ok := utils.ParseObjectId(c.Param("group_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	grp, err := placement.Get(db, grpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp.Violations, err = grp.GetViolations(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, grp)
+}
+
+func placementGroupsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	grpId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = grpId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	policy := strings.TrimSpace(c.Query("policy"))
+	if policy != "" {
+		query["policy"] = policy
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	grps, count, err := placement.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, grp := range grps {
+		grp.Violations, err = grp.GetViolations(db)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	data := &placementGroupsData{
+		PlacementGroups: grps,
+		Count:           count,
+	}
+
+	c.JSON(200, data)
+}
@@ -23,6 +23,7 @@ type settingsData struct {
 	AuthUserMaxDuration    int                           `json:"auth_user_max_duration"`
 	ElasticAddress         string                        `json:"elastic_address"`
 	ElasticProxyRequests   bool                          `json:"elastic_proxy_requests"`
+	MetricsToken           string                        `json:"metrics_token"`
 }
 
 func getSettingsData() *settingsData {
@@ -33,6 +34,7 @@ func getSettingsData() *settingsData {
 		AuthAdminMaxDuration:   settings.Auth.AdminMaxDuration,
 		AuthUserExpire:         settings.Auth.UserExpire,
 		AuthUserMaxDuration:    settings.Auth.UserMaxDuration,
+		MetricsToken:           settings.System.MetricsToken,
 	}
 
 	return data
@@ -105,6 +107,17 @@ func settingsPut(c *gin.Context) {
 		return
 	}
 
+	if settings.System.MetricsToken != data.MetricsToken {
+		settings.System.MetricsToken = data.MetricsToken
+
+		err = settings.Commit(db, settings.System, set.NewSet(
+			"metrics_token"))
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
 	event.PublishDispatch(db, "settings.change")
 
 	data = getSettingsData()
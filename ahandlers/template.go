@@ -0,0 +1,385 @@
+package ahandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/launch"
+	"github.com/pritunl/pritunl-cloud/usb"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type templateData struct {
+	Id               primitive.ObjectID `json:"id"`
+	Name             string             `json:"name"`
+	Comment          string             `json:"comment"`
+	Organization     primitive.ObjectID `json:"organization"`
+	Datacenter       primitive.ObjectID `json:"datacenter"`
+	Zone             primitive.ObjectID `json:"zone"`
+	Vpc              primitive.ObjectID `json:"vpc"`
+	Subnet           primitive.ObjectID `json:"subnet"`
+	Node             primitive.ObjectID `json:"node"`
+	PlacementGroup   primitive.ObjectID `json:"placement_group"`
+	Image            primitive.ObjectID `json:"image"`
+	ImageBacking     bool               `json:"image_backing"`
+	Domain           primitive.ObjectID `json:"domain"`
+	DeleteProtection bool               `json:"delete_protection"`
+	Ha               bool               `json:"ha"`
+	InitDiskSize     int                `json:"init_disk_size"`
+	Memory           int                `json:"memory"`
+	Processors       int                `json:"processors"`
+	NetworkRoles     []string           `json:"network_roles"`
+	UsbDevices       []*usb.Device      `json:"usb_devices"`
+	Vnc              bool               `json:"vnc"`
+	NoPublicAddress  bool               `json:"no_public_address"`
+	NoHostAddress    bool               `json:"no_host_address"`
+	Firmware         string             `json:"firmware"`
+	Tpm              bool               `json:"tpm"`
+}
+
+type templatesData struct {
+	Templates []*launch.Template `json:"templates"`
+	Count     int64              `json:"count"`
+}
+
+func templatePut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &templateData{}
+
+	tplId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tpl, err := launch.Get(db, tplId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tpl.Name = data.Name
+	tpl.Comment = data.Comment
+	tpl.Organization = data.Organization
+	tpl.Datacenter = data.Datacenter
+	tpl.Zone = data.Zone
+	tpl.Vpc = data.Vpc
+	tpl.Subnet = data.Subnet
+	tpl.Node = data.Node
+	tpl.PlacementGroup = data.PlacementGroup
+	tpl.Image = data.Image
+	tpl.ImageBacking = data.ImageBacking
+	tpl.Domain = data.Domain
+	tpl.DeleteProtection = data.DeleteProtection
+	tpl.Ha = data.Ha
+	tpl.InitDiskSize = data.InitDiskSize
+	tpl.Memory = data.Memory
+	tpl.Processors = data.Processors
+	tpl.NetworkRoles = data.NetworkRoles
+	tpl.UsbDevices = data.UsbDevices
+	tpl.Vnc = data.Vnc
+	tpl.NoPublicAddress = data.NoPublicAddress
+	tpl.NoHostAddress = data.NoHostAddress
+	tpl.Firmware = data.Firmware
+	tpl.Tpm = data.Tpm
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"organization",
+		"datacenter",
+		"zone",
+		"vpc",
+		"subnet",
+		"node",
+		"placement_group",
+		"image",
+		"image_backing",
+		"domain",
+		"delete_protection",
+		"ha",
+		"init_disk_size",
+		"memory",
+		"processors",
+		"network_roles",
+		"usb_devices",
+		"vnc",
+		"no_public_address",
+		"no_host_address",
+		"firmware",
+		"tpm",
+	)
+
+	errData, err := tpl.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tpl.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, tpl)
+}
+
+func templatePost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := &templateData{
+		Name: "New Template",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	tpl := &launch.Template{
+		Name:             data.Name,
+		Comment:          data.Comment,
+		Organization:     data.Organization,
+		Datacenter:       data.Datacenter,
+		Zone:             data.Zone,
+		Vpc:              data.Vpc,
+		Subnet:           data.Subnet,
+		Node:             data.Node,
+		PlacementGroup:   data.PlacementGroup,
+		Image:            data.Image,
+		ImageBacking:     data.ImageBacking,
+		Domain:           data.Domain,
+		DeleteProtection: data.DeleteProtection,
+		Ha:               data.Ha,
+		InitDiskSize:     data.InitDiskSize,
+		Memory:           data.Memory,
+		Processors:       data.Processors,
+		NetworkRoles:     data.NetworkRoles,
+		UsbDevices:       data.UsbDevices,
+		Vnc:              data.Vnc,
+		NoPublicAddress:  data.NoPublicAddress,
+		NoHostAddress:    data.NoHostAddress,
+		Firmware:         data.Firmware,
+		Tpm:              data.Tpm,
+	}
+
+	errData, err := tpl.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = tpl.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, tpl)
+}
+
+func templateLaunchPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	tplId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	tpl, err := launch.Get(db, tplId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	dta := &instanceData{
+		Name:             tpl.Name,
+		Comment:          tpl.Comment,
+		Organization:     tpl.Organization,
+		Datacenter:       tpl.Datacenter,
+		Zone:             tpl.Zone,
+		Vpc:              tpl.Vpc,
+		Subnet:           tpl.Subnet,
+		Node:             tpl.Node,
+		PlacementGroup:   tpl.PlacementGroup,
+		Image:            tpl.Image,
+		ImageBacking:     tpl.ImageBacking,
+		Domain:           tpl.Domain,
+		DeleteProtection: tpl.DeleteProtection,
+		Ha:               tpl.Ha,
+		InitDiskSize:     tpl.InitDiskSize,
+		Memory:           tpl.Memory,
+		Processors:       tpl.Processors,
+		NetworkRoles:     tpl.NetworkRoles,
+		UsbDevices:       tpl.UsbDevices,
+		Vnc:              tpl.Vnc,
+		NoPublicAddress:  tpl.NoPublicAddress,
+		NoHostAddress:    tpl.NoHostAddress,
+		Firmware:         tpl.Firmware,
+		Tpm:              tpl.Tpm,
+	}
+
+	if c.Request.ContentLength != 0 {
+		err = c.Bind(dta)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	}
+
+	instanceCreate(c, db, dta)
+}
+
+func templateDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+
+	tplId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := launch.Remove(db, tplId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, nil)
+}
+
+func templatesDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = launch.RemoveMulti(db, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "template.change")
+
+	c.JSON(200, nil)
+}
+
+func templateGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	tplId, ok := utils.ParseObjectId(c.Param("template_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	tpl, err := launch.Get(db, tplId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, tpl)
+}
+
+func templatesGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{}
+
+	tplId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = tplId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	zoneId, ok := utils.ParseObjectId(c.Query("zone"))
+	if ok {
+		query["zone"] = zoneId
+	}
+
+	organization, ok := utils.ParseObjectId(c.Query("organization"))
+	if ok {
+		query["organization"] = organization
+	}
+
+	tpls, count, err := launch.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &templatesData{
+		Templates: tpls,
+		Count:     count,
+	}
+
+	c.JSON(200, data)
+}
@@ -10,6 +10,9 @@ const (
 	AdminDeviceApprove         = "admin_device_approve"
 	AdminDeviceRegisterRequest = "admin_device_register_request"
 	AdminDeviceRegister        = "admin_device_register"
+	AdminInstanceSerial        = "admin_instance_serial"
+	AdminInstanceVnc           = "admin_instance_vnc"
+	AdminInstanceAgent         = "admin_instance_agent"
 
 	ProxyLogin                 = "proxy_login"
 	ProxyLoginFailed           = "proxy_login_failed"
@@ -32,6 +35,9 @@ const (
 	UserDeviceRegisterRequest = "user_device_register_request"
 	UserDeviceRegister        = "user_device_register"
 	UserAccountDisable        = "user_account_disable"
+	UserInstanceSerial        = "user_instance_serial"
+	UserInstanceVnc           = "user_instance_vnc"
+	UserInstanceAgent         = "user_instance_agent"
 
 	DeviceRegister       = "device_register"
 	DeviceRegisterFailed = "device_register_failed"
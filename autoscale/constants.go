@@ -0,0 +1,19 @@
+package autoscale
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+)
+
+const (
+	None = ""
+	Tcp  = "tcp"
+	Http = "http"
+)
+
+var (
+	ValidHealthChecks = set.NewSet(
+		None,
+		Tcp,
+		Http,
+	)
+)
@@ -0,0 +1,366 @@
+package autoscale
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/launch"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/scheduler"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type Group struct {
+	Id              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	Comment         string             `bson:"comment" json:"comment"`
+	Organization    primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
+	Template        primitive.ObjectID `bson:"template" json:"template"`
+	Minimum         int                `bson:"minimum" json:"minimum"`
+	Maximum         int                `bson:"maximum" json:"maximum"`
+	Desired         int                `bson:"desired" json:"desired"`
+	HealthCheck     string             `bson:"health_check" json:"health_check"`
+	HealthPort      int                `bson:"health_port" json:"health_port"`
+	HealthPath      string             `bson:"health_path" json:"health_path"`
+	HealthThreshold int                `bson:"health_threshold" json:"health_threshold"`
+	HealthGrace     int                `bson:"health_grace" json:"health_grace"`
+	ScaleUpCpu      int                `bson:"scale_up_cpu" json:"scale_up_cpu"`
+	ScaleDownCpu    int                `bson:"scale_down_cpu" json:"scale_down_cpu"`
+	Cooldown        int                `bson:"cooldown" json:"cooldown"`
+	LastScale       time.Time          `bson:"last_scale" json:"last_scale"`
+}
+
+func (g *Group) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if g.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if g.Template.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "template_required",
+			Message: "Missing required launch template",
+		}
+		return
+	}
+
+	_, err = launch.GetOrg(db, g.Organization, g.Template)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "template_invalid",
+				Message: "Launch template does not exist",
+			}
+		}
+		return
+	}
+
+	if g.Minimum < 0 {
+		g.Minimum = 0
+	}
+
+	if g.Maximum < 1 {
+		g.Maximum = 1
+	}
+
+	if g.Minimum > g.Maximum {
+		errData = &errortypes.ErrorData{
+			Error:   "size_invalid",
+			Message: "Minimum size cannot be greater than maximum size",
+		}
+		return
+	}
+
+	if g.Desired < g.Minimum {
+		g.Desired = g.Minimum
+	} else if g.Desired > g.Maximum {
+		g.Desired = g.Maximum
+	}
+
+	if !ValidHealthChecks.Contains(g.HealthCheck) {
+		errData = &errortypes.ErrorData{
+			Error:   "health_check_invalid",
+			Message: "Health check type invalid",
+		}
+		return
+	}
+
+	if g.HealthCheck != None {
+		if g.HealthPort < 1 || g.HealthPort > 65535 {
+			errData = &errortypes.ErrorData{
+				Error:   "health_port_invalid",
+				Message: "Health check port invalid",
+			}
+			return
+		}
+	} else {
+		g.HealthPort = 0
+	}
+
+	if g.HealthCheck == Http {
+		if g.HealthPath == "" {
+			g.HealthPath = "/"
+		} else if !strings.HasPrefix(g.HealthPath, "/") {
+			g.HealthPath = "/" + g.HealthPath
+		}
+	} else {
+		g.HealthPath = ""
+	}
+
+	if g.HealthThreshold < 1 {
+		g.HealthThreshold = 3
+	}
+
+	if g.HealthGrace < 0 {
+		g.HealthGrace = 0
+	}
+
+	if g.ScaleUpCpu < 0 || g.ScaleUpCpu > 100 ||
+		g.ScaleDownCpu < 0 || g.ScaleDownCpu > 100 {
+
+		errData = &errortypes.ErrorData{
+			Error:   "scale_cpu_invalid",
+			Message: "Scaling CPU threshold must be between 0 and 100",
+		}
+		return
+	}
+
+	if g.ScaleUpCpu != 0 && g.ScaleDownCpu >= g.ScaleUpCpu {
+		errData = &errortypes.ErrorData{
+			Error:   "scale_cpu_invalid",
+			Message: "Scale down CPU threshold must be less than scale up",
+		}
+		return
+	}
+
+	if g.Cooldown < 0 {
+		g.Cooldown = 0
+	}
+
+	return
+}
+
+func (g *Group) IsScaling() bool {
+	return g.ScaleUpCpu != 0 && g.Minimum != g.Maximum
+}
+
+func (g *Group) Launch(db *database.Database, tpl *launch.Template) (
+	inst *instance.Instance, errData *errortypes.ErrorData, err error) {
+
+	if tpl.Organization != g.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "template_invalid",
+			Message: "Launch template does not exist",
+		}
+		return
+	}
+
+	errData, err = tpl.ValidateOrg(db, g.Organization)
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		return
+	}
+
+	suffix, err := utils.RandStr(6)
+	if err != nil {
+		return
+	}
+
+	inst = &instance.Instance{
+		State:           instance.Start,
+		Organization:    g.Organization,
+		Zone:            tpl.Zone,
+		Vpc:             tpl.Vpc,
+		Subnet:          tpl.Subnet,
+		Node:            tpl.Node,
+		PlacementGroup:  tpl.PlacementGroup,
+		AutoscaleGroup:  g.Id,
+		Image:           tpl.Image,
+		ImageBacking:    tpl.ImageBacking,
+		Ha:              tpl.Ha,
+		Name:            g.Name + "-" + strings.ToLower(suffix),
+		Comment:         tpl.Comment,
+		InitDiskSize:    tpl.InitDiskSize,
+		Memory:          tpl.Memory,
+		Processors:      tpl.Processors,
+		NetworkRoles:    tpl.NetworkRoles,
+		UsbDevices:      tpl.UsbDevices,
+		Vnc:             tpl.Vnc,
+		Domain:          tpl.Domain,
+		NoPublicAddress: tpl.NoPublicAddress,
+		NoHostAddress:   tpl.NoHostAddress,
+		Firmware:        tpl.Firmware,
+		Tpm:             tpl.Tpm,
+	}
+
+	if !tpl.Node.IsZero() {
+		nde, e := node.Get(db, tpl.Node)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if nde.Maintenance {
+			errData = &errortypes.ErrorData{
+				Error:   "node_maintenance",
+				Message: "Node is in maintenance",
+			}
+			return
+		}
+	} else {
+		var schd *scheduler.Scheduler
+		if !tpl.Zone.IsZero() {
+			schd, err = scheduler.NewZone(db, tpl.Zone)
+		} else {
+			schd, err = scheduler.NewDatacenter(db, tpl.Datacenter)
+		}
+		if err != nil {
+			return
+		}
+
+		var nde *node.Node
+		nde, errData, err = schd.ScheduleInstance(db, inst)
+		if err != nil {
+			return
+		}
+
+		if errData != nil {
+			return
+		}
+
+		inst.Node = nde.Id
+		inst.Zone = nde.Zone
+	}
+
+	errData, err = inst.Validate(db)
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		return
+	}
+
+	err = inst.Insert(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) CheckHealth(inst *instance.Instance) (err error) {
+	addr := ""
+	if len(inst.PublicIps) > 0 {
+		addr = inst.PublicIps[0]
+	} else if len(inst.PublicIps6) > 0 {
+		addr = inst.PublicIps6[0]
+	} else if len(inst.HostIps) > 0 {
+		addr = inst.HostIps[0]
+	}
+
+	if addr == "" {
+		return
+	}
+
+	addr = net.JoinHostPort(addr, strconv.Itoa(g.HealthPort))
+
+	switch g.HealthCheck {
+	case Tcp:
+		conn, e := net.DialTimeout("tcp", addr, 5*time.Second)
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "autoscale: Health check connection failed"),
+			}
+			return
+		}
+		conn.Close()
+		break
+	case Http:
+		client := &http.Client{
+			Timeout: 5 * time.Second,
+		}
+
+		resp, e := client.Get(fmt.Sprintf("http://%s%s", addr, g.HealthPath))
+		if e != nil {
+			err = &errortypes.RequestError{
+				errors.Wrap(e, "autoscale: Health check request failed"),
+			}
+			return
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			err = &errortypes.RequestError{
+				errors.Newf("autoscale: Health check bad status %d",
+					resp.StatusCode),
+			}
+			return
+		}
+		break
+	}
+
+	return
+}
+
+func (g *Group) Commit(db *database.Database) (err error) {
+	coll := db.AutoscaleGroups()
+
+	err = coll.Commit(g.Id, g)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.AutoscaleGroups()
+
+	err = coll.CommitFields(g.Id, g, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) Insert(db *database.Database) (err error) {
+	coll := db.AutoscaleGroups()
+
+	if !g.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("autoscale: Autoscale group already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, g)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
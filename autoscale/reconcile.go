@@ -0,0 +1,259 @@
+package autoscale
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/launch"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func (g *Group) healthy(db *database.Database,
+	inst *instance.Instance) (healthy bool, err error) {
+
+	if inst.IsMigrating() || inst.HaPending {
+		healthy = true
+		return
+	}
+
+	if inst.State != instance.Start || inst.VmState == vm.Failed {
+		return
+	}
+
+	healthy = true
+
+	if g.HealthCheck == None || inst.VmState != vm.Running ||
+		time.Since(inst.VmTimestamp) < time.Duration(
+			g.HealthGrace)*time.Second {
+
+		return
+	}
+
+	e := g.CheckHealth(inst)
+	if e != nil {
+		inst.HealthFailures += 1
+
+		logrus.WithFields(logrus.Fields{
+			"autoscale_group": g.Id.Hex(),
+			"instance_id":     inst.Id.Hex(),
+			"failures":        inst.HealthFailures,
+			"error":           e,
+		}).Warn("autoscale: Instance failed health check")
+
+		if inst.HealthFailures >= g.HealthThreshold {
+			healthy = false
+		}
+	} else if inst.HealthFailures != 0 {
+		inst.HealthFailures = 0
+	} else {
+		return
+	}
+
+	err = inst.CommitFields(db, set.NewSet("health_failures"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) getCpu(db *database.Database,
+	insts []*instance.Instance) (cpu float64, ok bool, err error) {
+
+	ndeIds := set.NewSet()
+	for _, inst := range insts {
+		if !inst.Node.IsZero() {
+			ndeIds.Add(inst.Node)
+		}
+	}
+
+	if ndeIds.Len() == 0 {
+		return
+	}
+
+	ndes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	total := 0.0
+	count := 0
+	for _, nde := range ndes {
+		if !ndeIds.Contains(nde.Id) || !nde.IsHypervisor() {
+			continue
+		}
+
+		total += nde.Load1
+		count += 1
+	}
+
+	if count == 0 {
+		return
+	}
+
+	cpu = total / float64(count)
+	ok = true
+
+	return
+}
+
+func (g *Group) scale(db *database.Database,
+	insts []*instance.Instance) (err error) {
+
+	if !g.IsScaling() || time.Since(g.LastScale) < time.Duration(
+		g.Cooldown)*time.Second {
+
+		return
+	}
+
+	cpu, ok, err := g.getCpu(db, insts)
+	if err != nil || !ok {
+		return
+	}
+
+	desired := g.Desired
+	if cpu >= float64(g.ScaleUpCpu) {
+		desired += 1
+	} else if cpu <= float64(g.ScaleDownCpu) {
+		desired -= 1
+	}
+
+	if desired < g.Minimum {
+		desired = g.Minimum
+	} else if desired > g.Maximum {
+		desired = g.Maximum
+	}
+
+	if desired == g.Desired {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"autoscale_group": g.Id.Hex(),
+		"cpu":             cpu,
+		"desired":         desired,
+		"current":         g.Desired,
+	}).Info("autoscale: Scaling instance group")
+
+	g.Desired = desired
+	g.LastScale = time.Now()
+
+	err = g.CommitFields(db, set.NewSet("desired", "last_scale"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func destroyInstance(db *database.Database, inst *instance.Instance) (
+	err error) {
+
+	inst.State = instance.Destroy
+	inst.DeleteProtection = false
+
+	err = inst.CommitFields(db, set.NewSet("state", "delete_protection"))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) Reconcile(db *database.Database) (changed bool, err error) {
+	tpl, err := launch.GetOrg(db, g.Organization, g.Template)
+	if err != nil {
+		return
+	}
+
+	insts, err := instance.GetAll(db, &bson.M{
+		"autoscale_group": g.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	active := []*instance.Instance{}
+	for _, inst := range insts {
+		if inst.State == instance.Destroy {
+			continue
+		}
+
+		healthy, e := g.healthy(db, inst)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !healthy {
+			logrus.WithFields(logrus.Fields{
+				"autoscale_group": g.Id.Hex(),
+				"instance_id":     inst.Id.Hex(),
+				"state":           inst.State,
+				"vm_state":        inst.VmState,
+			}).Warn("autoscale: Replacing unhealthy instance")
+
+			err = destroyInstance(db, inst)
+			if err != nil {
+				return
+			}
+			changed = true
+
+			continue
+		}
+
+		active = append(active, inst)
+	}
+
+	err = g.scale(db, active)
+	if err != nil {
+		return
+	}
+
+	if len(active) > g.Desired {
+		sort.Slice(active, func(i, j int) bool {
+			return active[i].Id.Timestamp().After(active[j].Id.Timestamp())
+		})
+
+		for _, inst := range active[:len(active)-g.Desired] {
+			err = destroyInstance(db, inst)
+			if err != nil {
+				return
+			}
+			changed = true
+		}
+	}
+
+	for i := len(active); i < g.Desired; i++ {
+		inst, errData, e := g.Launch(db, tpl)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			logrus.WithFields(logrus.Fields{
+				"autoscale_group": g.Id.Hex(),
+				"error":           errData.Error,
+				"message":         errData.Message,
+			}).Error("autoscale: Failed to launch group instance")
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"autoscale_group": g.Id.Hex(),
+			"instance_name":   inst.Name,
+			"node_id":         inst.Node.Hex(),
+		}).Info("autoscale: Launched group instance")
+
+		changed = true
+	}
+
+	return
+}
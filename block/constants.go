@@ -3,4 +3,5 @@ package block
 const (
 	External = "external"
 	Host     = "host"
+	Elastic  = "elastic"
 )
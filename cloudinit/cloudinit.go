@@ -20,6 +20,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/resolver"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
@@ -37,22 +38,23 @@ MIME-Version: 1.0
 
 const netConfigTmpl = `version: 1
 config:
-  - type: physical
-    name: eth0
+{{range .Interfaces}}  - type: physical
+    name: {{.Name}}
     mac_address: {{.Mac}}{{.Mtu}}
     subnets:
       - type: static
         address: {{.Address}}
         netmask: {{.Netmask}}
-        network: {{.Network}}
-        gateway: {{.Gateway}}
-        dns_nameservers:
-          - 8.8.8.8
-          - 8.8.4.4
+        network: {{.Network}}{{if .Gateway}}
+        gateway: {{.Gateway}}{{end}}{{if .Nameservers}}
+        dns_nameservers:{{range .Nameservers}}
+          - {{.}}{{end}}{{end}}{{if .SearchDomains}}
+        dns_search:{{range .SearchDomains}}
+          - {{.}}{{end}}{{end}}
       - type: static
-        address: {{.Address6}}
-        gateway: {{.Gateway6}}
-`
+        address: {{.Address6}}{{if .Gateway6}}
+        gateway: {{.Gateway6}}{{end}}
+{{end}}`
 
 const netMtu = `
     mtu: %d`
@@ -79,6 +81,15 @@ users:
 {{range .Keys}}      - {{.}}
 {{end}}`
 
+const userMergeType = "list(append)+dict(no_replace,recurse_list)+str()"
+
+const ntpConfigTmpl = `#cloud-config
+ntp:
+  enabled: true
+  servers:
+{{range .}}    - {{.}}
+{{end}}`
+
 const cloudScriptTmpl = `#!/bin/bash
 %s`
 
@@ -90,17 +101,25 @@ EOF
 var (
 	cloudConfig = template.Must(template.New("cloud").Parse(cloudConfigTmpl))
 	netConfig   = template.Must(template.New("net").Parse(netConfigTmpl))
+	ntpConfig   = template.Must(template.New("ntp").Parse(ntpConfigTmpl))
 )
 
 type netConfigData struct {
-	Mac      string
-	Mtu      string
-	Address  string
-	Netmask  string
-	Network  string
-	Gateway  string
-	Address6 string
-	Gateway6 string
+	Interfaces []*netIfaceData
+}
+
+type netIfaceData struct {
+	Name          string
+	Mac           string
+	Mtu           string
+	Address       string
+	Netmask       string
+	Network       string
+	Gateway       string
+	Address6      string
+	Gateway6      string
+	Nameservers   []string
+	SearchDomains []string
 }
 
 type cloudConfigData struct {
@@ -117,7 +136,17 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		return
 	}
 
-	if len(authrs) == 0 {
+	ntpServers := []string{}
+	if !inst.Vpc.IsZero() {
+		vc, e := vpc.Get(db, inst.Vpc)
+		if e != nil {
+			err = e
+			return
+		}
+		ntpServers = vc.NtpServers
+	}
+
+	if len(authrs) == 0 && len(ntpServers) == 0 && inst.UserData == "" {
 		return
 	}
 
@@ -151,30 +180,51 @@ func getUserData(db *database.Database, inst *instance.Instance,
 
 	items := []string{}
 
-	output := &bytes.Buffer{}
-	err = cloudConfig.Execute(output, data)
-	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "cloudinit: Failed to exec cloud template"),
+	if len(authrs) > 0 {
+		output := &bytes.Buffer{}
+		err = cloudConfig.Execute(output, data)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "cloudinit: Failed to exec cloud template"),
+			}
+			return
+		}
+		items = append(items, output.String())
+
+		if trusted != "" {
+			cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/trusted", trusted)
+		}
+		if principals != "" {
+			cloudScript += fmt.Sprintf(
+				teeTmpl, "/etc/ssh/principals", principals)
 		}
-		return
-	}
-	items = append(items, output.String())
 
-	if trusted != "" {
-		cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/trusted", trusted)
+		if cloudScript != "" {
+			items = append(items, fmt.Sprintf(cloudScriptTmpl, cloudScript))
+		}
 	}
-	if principals != "" {
-		cloudScript += fmt.Sprintf(teeTmpl, "/etc/ssh/principals", principals)
+
+	if len(ntpServers) > 0 {
+		output := &bytes.Buffer{}
+		err = ntpConfig.Execute(output, ntpServers)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "cloudinit: Failed to exec ntp template"),
+			}
+			return
+		}
+		items = append(items, output.String())
 	}
 
-	if cloudScript != "" {
-		items = append(items, fmt.Sprintf(cloudScriptTmpl, cloudScript))
+	userItem := -1
+	if inst.UserData != "" {
+		userItem = len(items)
+		items = append(items, inst.UserData)
 	}
 
 	buffer := &bytes.Buffer{}
 	message := multipart.NewWriter(buffer)
-	for _, item := range items {
+	for i, item := range items {
 		header := textproto.MIMEHeader{}
 
 		header.Set("Content-Transfer-Encoding", "base64")
@@ -186,6 +236,12 @@ func getUserData(db *database.Database, inst *instance.Instance,
 		} else {
 			header.Set("Content-Type",
 				"text/cloud-config; charset=\"utf-8\"")
+
+			// Append user lists to the generated config instead of
+			// replacing the cloud user and keys
+			if i == userItem {
+				header.Set("Merge-Type", userMergeType)
+			}
 		}
 
 		part, e := message.CreatePart(header)
@@ -223,28 +279,23 @@ func getUserData(db *database.Database, inst *instance.Instance,
 	return
 }
 
-func getNetData(db *database.Database, inst *instance.Instance,
-	virt *vm.VirtualMachine) (netData string, err error) {
+func GetUserData(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (usrData string, err error) {
 
-	if len(virt.NetworkAdapters) == 0 {
-		err = &errortypes.NotFoundError{
-			errors.Wrap(err, "cloudinit: Instance missing network adapters"),
-		}
+	usrData, err = getUserData(db, inst, virt, false)
+	if err != nil {
 		return
 	}
 
-	adapter := virt.NetworkAdapters[0]
+	return
+}
 
-	if adapter.Vpc.IsZero() {
-		err = &errortypes.NotFoundError{
-			errors.Wrap(err, "cloudinit: Instance missing VPC"),
-		}
-		return
-	}
+func getNetData(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (netData string, err error) {
 
-	if adapter.Subnet.IsZero() {
+	if len(virt.NetworkAdapters) == 0 {
 		err = &errortypes.NotFoundError{
-			errors.Wrap(err, "cloudinit: Instance missing VPC subnet"),
+			errors.Wrap(err, "cloudinit: Instance missing network adapters"),
 		}
 		return
 	}
@@ -259,34 +310,8 @@ func getNetData(db *database.Database, inst *instance.Instance,
 		vxlan = true
 	}
 
-	vc, err := vpc.Get(db, adapter.Vpc)
-	if err != nil {
-		return
-	}
-
-	vcNet, err := vc.GetNetwork()
-	if err != nil {
-		return
-	}
-
-	addr, gatewayAddr, err := vc.GetIp(db, inst.Subnet, inst.Id)
-	if err != nil {
-		return
-	}
-
-	addr6 := vc.GetIp6(addr)
-	gatewayAddr6 := vc.GetIp6(gatewayAddr)
-
-	data := netConfigData{
-		Mac:      adapter.MacAddress,
-		Address:  addr.String(),
-		Netmask:  net.IP(vcNet.Mask).String(),
-		Network:  vcNet.IP.String(),
-		Gateway:  gatewayAddr.String(),
-		Address6: addr6.String(),
-		Gateway6: gatewayAddr6.String(),
-	}
-
+	mtu := ""
+	maxMtu := settings.Hypervisor.NormalMtu
 	jumboFrames := node.Self.JumboFrames
 	if jumboFrames || vxlan {
 		mtuSize := 0
@@ -300,7 +325,93 @@ func getNetData(db *database.Database, inst *instance.Instance,
 			mtuSize -= 54
 		}
 
-		data.Mtu = fmt.Sprintf(netMtu, mtuSize)
+		mtu = fmt.Sprintf(netMtu, mtuSize)
+		maxMtu = mtuSize
+	}
+
+	data := netConfigData{
+		Interfaces: []*netIfaceData{},
+	}
+
+	for i, adapter := range virt.NetworkAdapters {
+		if adapter.Vpc.IsZero() {
+			err = &errortypes.NotFoundError{
+				errors.Wrap(err, "cloudinit: Instance missing VPC"),
+			}
+			return
+		}
+
+		if adapter.Subnet.IsZero() {
+			err = &errortypes.NotFoundError{
+				errors.Wrap(err, "cloudinit: Instance missing VPC subnet"),
+			}
+			return
+		}
+
+		vc, e := vpc.Get(db, adapter.Vpc)
+		if e != nil {
+			err = e
+			return
+		}
+
+		vcNet, e := vc.GetNetwork()
+		if e != nil {
+			err = e
+			return
+		}
+
+		addr, gatewayAddr, e := vc.GetInstanceIp(db, adapter.Subnet,
+			inst.Id, adapter.StaticIp)
+		if e != nil {
+			err = e
+			return
+		}
+
+		iface := &netIfaceData{
+			Name:          fmt.Sprintf("eth%d", i),
+			Mac:           adapter.MacAddress,
+			Mtu:           mtu,
+			Address:       addr.String(),
+			Netmask:       net.IP(vcNet.Mask).String(),
+			Network:       vcNet.IP.String(),
+			Address6:      vc.GetIp6(addr).String(),
+			SearchDomains: vc.SearchDomains,
+		}
+
+		// VPC MTU can only lower the interface MTU below the host path
+		if vc.Mtu > maxMtu {
+			err = &errortypes.NetworkError{
+				errors.Newf("cloudinit: VPC MTU %d exceeds node "+
+					"network MTU %d", vc.Mtu, maxMtu),
+			}
+			return
+		} else if vc.Mtu > 0 {
+			iface.Mtu = fmt.Sprintf(netMtu, vc.Mtu)
+		}
+
+		// Only the primary interface carries the default routes
+		if i == 0 {
+			iface.Gateway = gatewayAddr.String()
+			iface.Gateway6 = vc.GetIp6(gatewayAddr).String()
+
+			if vc.PrivateDns {
+				// Resolver forwards other queries to the VPC nameservers or
+				// the default nameservers when none are configured
+				iface.Nameservers = []string{resolver.Address}
+
+				domain := resolver.GetDomain(vc.Name)
+				if domain != "" {
+					iface.SearchDomains = append(
+						[]string{domain}, vc.SearchDomains...)
+				}
+			} else if len(vc.Nameservers) > 0 {
+				iface.Nameservers = vc.Nameservers
+			} else {
+				iface.Nameservers = resolver.DefaultNameservers
+			}
+		}
+
+		data.Interfaces = append(data.Interfaces, iface)
 	}
 
 	output := &bytes.Buffer{}
@@ -0,0 +1,146 @@
+package console
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qga"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type agentResponse struct {
+	Error  string          `json:"error,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+func runAgentLocal(instId primitive.ObjectID,
+	action *qga.Action) (resp *agentResponse) {
+
+	resp = &agentResponse{}
+
+	result, err := action.Run(paths.GetGuestPath(instId))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": instId.Hex(),
+			"action":      action.Action,
+			"error":       err,
+		}).Warn("console: Guest agent action failed")
+
+		resp.Error = errors.GetMessage(err)
+		return
+	}
+
+	if result != nil {
+		data, e := json.Marshal(result)
+		if e != nil {
+			resp.Error = "console: Failed to marshal agent result"
+			return
+		}
+		resp.Result = data
+	}
+
+	return
+}
+
+func handleAgent(w http.ResponseWriter, r *http.Request, tkn *Token) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		utils.WriteStatus(w, 400)
+		return
+	}
+
+	action := &qga.Action{}
+	err = json.Unmarshal(body, action)
+	if err != nil {
+		utils.WriteStatus(w, 400)
+		return
+	}
+
+	errData := action.Validate()
+	if errData != nil {
+		utils.WriteStatus(w, 400)
+		return
+	}
+
+	resp := runAgentLocal(tkn.Instance, action)
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		utils.WriteStatus(w, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(data)
+}
+
+func RunAgent(db *database.Database, inst *instance.Instance,
+	action *qga.Action) (result json.RawMessage,
+	errData *errortypes.ErrorData, err error) {
+
+	if inst.Node.IsZero() {
+		err = &errortypes.NotFoundError{
+			errors.New("console: Instance not assigned to node"),
+		}
+		return
+	}
+
+	tkn, err := NewToken(db, Agent, inst.Id, inst.Node)
+	if err != nil {
+		return
+	}
+
+	resp := &agentResponse{}
+
+	if inst.Node == node.Self.Id {
+		_, err = ClaimToken(db, tkn.Id)
+		if err != nil {
+			return
+		}
+
+		resp = runAgentLocal(inst.Id, action)
+	} else {
+		body, e := json.Marshal(action)
+		if e != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(e, "console: Failed to marshal agent action"),
+			}
+			return
+		}
+
+		data, e := nodeRequest(db, inst.Node, tkn.Id, body)
+		if e != nil {
+			err = e
+			return
+		}
+
+		err = json.Unmarshal(data, resp)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "console: Failed to parse agent response"),
+			}
+			return
+		}
+	}
+
+	if resp.Error != "" {
+		errData = &errortypes.ErrorData{
+			Error:   "agent_error",
+			Message: resp.Error,
+		}
+		return
+	}
+
+	result = resp.Result
+	return
+}
@@ -0,0 +1,498 @@
+package console
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gorilla/websocket"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type wsStream struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func (s *wsStream) Read(p []byte) (n int, err error) {
+	for {
+		if s.reader == nil {
+			_, s.reader, err = s.conn.NextReader()
+			if err != nil {
+				return
+			}
+		}
+
+		n, err = s.reader.Read(p)
+		if err == io.EOF {
+			s.reader = nil
+			err = nil
+			if n == 0 {
+				continue
+			}
+		}
+
+		return
+	}
+}
+
+func (s *wsStream) Write(p []byte) (n int, err error) {
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	err = s.conn.WriteMessage(websocket.BinaryMessage, p)
+	if err != nil {
+		return
+	}
+
+	n = len(p)
+	return
+}
+
+func (s *wsStream) Close() error {
+	return s.conn.Close()
+}
+
+func getTlsConfig(db *database.Database, nde *node.Node) (
+	conf *tls.Config, err error) {
+
+	if nde.Protocol == "http" {
+		return
+	}
+
+	// Node serves the first configured certificate that loads or the
+	// self certificate, pin all of them
+	nodeCerts := [][]byte{}
+	for _, certId := range nde.Certificates {
+		cert, e := certificate.Get(db, certId)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				continue
+			}
+			err = e
+			return
+		}
+
+		block, _ := pem.Decode([]byte(cert.Certificate))
+		if block != nil {
+			nodeCerts = append(nodeCerts, block.Bytes)
+		}
+	}
+
+	block, _ := pem.Decode([]byte(nde.SelfCertificate))
+	if block != nil {
+		nodeCerts = append(nodeCerts, block.Bytes)
+	}
+
+	if len(nodeCerts) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("console: Failed to decode node certificate"),
+		}
+		return
+	}
+
+	conf = &tls.Config{
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		VerifyPeerCertificate: func(rawCerts [][]byte,
+			_ [][]*x509.Certificate) error {
+
+			if len(rawCerts) != 0 {
+				for _, nodeCert := range nodeCerts {
+					if bytes.Equal(rawCerts[0], nodeCert) {
+						return nil
+					}
+				}
+			}
+
+			return &errortypes.VerificationError{
+				errors.New("console: Node certificate mismatch"),
+			}
+		},
+	}
+
+	return
+}
+
+func getNodeUrl(db *database.Database, ndeId primitive.ObjectID,
+	scheme string) (nde *node.Node, url string, err error) {
+
+	nde, err = node.Get(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	url = nde.GetWebUrl(scheme)
+	if url == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("console: Missing instance node address"),
+		}
+		return
+	}
+
+	return
+}
+
+func nodeRequest(db *database.Database, ndeId primitive.ObjectID,
+	tknId string, body []byte) (data []byte, err error) {
+
+	nde, url, err := getNodeUrl(db, ndeId, "http")
+	if err != nil {
+		return
+	}
+
+	tlsConf, err := getTlsConfig(db, nde)
+	if err != nil {
+		return
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   tlsConf,
+		},
+	}
+
+	var resp *http.Response
+	if body == nil {
+		resp, err = client.Get(url + "/console/" + tknId)
+	} else {
+		resp, err = client.Post(url+"/console/"+tknId,
+			"application/json", bytes.NewReader(body))
+	}
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "console: Node request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("console: Node request bad status %d",
+				resp.StatusCode),
+		}
+		return
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "console: Failed to read node response"),
+		}
+		return
+	}
+
+	return
+}
+
+func dialLocal(instId primitive.ObjectID, typ string) (
+	stream io.ReadWriteCloser, err error) {
+
+	sockPath := ""
+	switch typ {
+	case Serial:
+		sockPath = paths.GetSerialSockPath(instId)
+		break
+	case Vnc:
+		sockPath = paths.GetVncSockPath(instId)
+		break
+	default:
+		err = &errortypes.UnknownError{
+			errors.Newf("console: Unknown console type '%s'", typ),
+		}
+		return
+	}
+
+	conn, err := net.DialTimeout("unix", sockPath, dialTimeout)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "console: Failed to connect to socket"),
+		}
+		return
+	}
+
+	stream = conn
+	return
+}
+
+func readLocalLog(instId primitive.ObjectID) (output string, err error) {
+	logPath := paths.GetSerialLogPath(instId)
+
+	for _, pth := range []string{logPath + ".1", logPath} {
+		exists, e := utils.ExistsFile(pth)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !exists {
+			continue
+		}
+
+		data, e := ioutil.ReadFile(pth)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "console: Failed to read serial log"),
+			}
+			return
+		}
+
+		output += string(data)
+	}
+
+	return
+}
+
+func Dial(db *database.Database, inst *instance.Instance, typ string) (
+	stream io.ReadWriteCloser, err error) {
+
+	if inst.Node.IsZero() {
+		err = &errortypes.NotFoundError{
+			errors.New("console: Instance not assigned to node"),
+		}
+		return
+	}
+
+	tkn, err := NewToken(db, typ, inst.Id, inst.Node)
+	if err != nil {
+		return
+	}
+
+	if inst.Node == node.Self.Id {
+		_, err = ClaimToken(db, tkn.Id)
+		if err != nil {
+			return
+		}
+
+		stream, err = dialLocal(inst.Id, typ)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	nde, url, err := getNodeUrl(db, inst.Node, "ws")
+	if err != nil {
+		return
+	}
+
+	tlsConf, err := getTlsConfig(db, nde)
+	if err != nil {
+		return
+	}
+
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: dialTimeout,
+		TLSClientConfig:  tlsConf,
+	}
+
+	conn, _, err := dialer.Dial(url+"/console/"+tkn.Id, nil)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "console: Failed to connect to instance node"),
+		}
+		return
+	}
+
+	stream = &wsStream{
+		conn: conn,
+	}
+	return
+}
+
+func GetSerialLog(db *database.Database, inst *instance.Instance) (
+	output string, err error) {
+
+	if inst.Node.IsZero() {
+		err = &errortypes.NotFoundError{
+			errors.New("console: Instance not assigned to node"),
+		}
+		return
+	}
+
+	tkn, err := NewToken(db, SerialLog, inst.Id, inst.Node)
+	if err != nil {
+		return
+	}
+
+	if inst.Node == node.Self.Id {
+		_, err = ClaimToken(db, tkn.Id)
+		if err != nil {
+			return
+		}
+
+		output, err = readLocalLog(inst.Id)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	data, err := nodeRequest(db, inst.Node, tkn.Id, nil)
+	if err != nil {
+		return
+	}
+
+	output = string(data)
+	return
+}
+
+func UpgradeHeader(r *http.Request) (header http.Header) {
+	for _, protocol := range websocket.Subprotocols(r) {
+		if protocol == "binary" {
+			header = http.Header{}
+			header.Set("Sec-WebSocket-Protocol", protocol)
+			return
+		}
+	}
+
+	return
+}
+
+func Relay(conn *websocket.Conn, stream io.ReadWriteCloser) {
+	done := make(chan struct{})
+
+	defer func() {
+		close(done)
+		conn.Close()
+		stream.Close()
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(pingWait))
+	conn.SetPongHandler(func(x string) (err error) {
+		conn.SetReadDeadline(time.Now().Add(pingWait))
+		return
+	})
+
+	go func() {
+		defer conn.Close()
+
+		buf := make([]byte, 4096)
+		for {
+			n, e := stream.Read(buf)
+			if n > 0 {
+				conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+				e = conn.WriteMessage(websocket.BinaryMessage, buf[:n])
+				if e != nil {
+					return
+				}
+			}
+			if e != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := conn.WriteControl(websocket.PingMessage, []byte{},
+					time.Now().Add(writeTimeout))
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		_, err = stream.Write(data)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func Handle(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDatabase()
+	tkn, err := ClaimToken(db, strings.TrimPrefix(r.URL.Path, "/console/"))
+	db.Close()
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError, *errortypes.AuthenticationError:
+			utils.WriteStatus(w, 401)
+			break
+		default:
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("console: Failed to claim console token")
+			utils.WriteStatus(w, 500)
+		}
+		return
+	}
+
+	if tkn.Node != node.Self.Id {
+		utils.WriteStatus(w, 401)
+		return
+	}
+
+	switch tkn.Type {
+	case SerialLog:
+		output, e := readLocalLog(tkn.Instance)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": tkn.Instance.Hex(),
+				"error":       e,
+			}).Error("console: Failed to read serial log")
+			utils.WriteStatus(w, 500)
+			return
+		}
+
+		utils.WriteText(w, 200, output)
+		break
+	case Agent:
+		handleAgent(w, r, tkn)
+		break
+	default:
+		stream, e := dialLocal(tkn.Instance, tkn.Type)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": tkn.Instance.Hex(),
+				"type":        tkn.Type,
+				"error":       e,
+			}).Error("console: Failed to connect to instance console")
+			utils.WriteStatus(w, 500)
+			return
+		}
+
+		conn, e := event.Upgrader.Upgrade(w, r, UpgradeHeader(r))
+		if e != nil {
+			stream.Close()
+			return
+		}
+
+		Relay(conn, stream)
+		break
+	}
+}
@@ -0,0 +1,20 @@
+package console
+
+import (
+	"time"
+)
+
+const (
+	Serial    = "serial"
+	SerialLog = "serial_log"
+	Vnc       = "vnc"
+	Agent     = "agent"
+)
+
+const (
+	tokenTtl     = 30 * time.Second
+	dialTimeout  = 10 * time.Second
+	writeTimeout = 10 * time.Second
+	pingInterval = 30 * time.Second
+	pingWait     = 40 * time.Second
+)
@@ -0,0 +1,81 @@
+package console
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type Token struct {
+	Id        string             `bson:"_id"`
+	Type      string             `bson:"type"`
+	Instance  primitive.ObjectID `bson:"instance"`
+	Node      primitive.ObjectID `bson:"node"`
+	Timestamp time.Time          `bson:"timestamp"`
+}
+
+func (t *Token) claimable(deleted int64, now time.Time) bool {
+	return deleted == 1 && now.Sub(t.Timestamp) <= tokenTtl
+}
+
+func NewToken(db *database.Database, typ string, instId,
+	ndeId primitive.ObjectID) (tkn *Token, err error) {
+
+	coll := db.ConsoleTokens()
+
+	tknId, err := utils.RandStr(48)
+	if err != nil {
+		return
+	}
+
+	tkn = &Token{
+		Id:        tknId,
+		Type:      typ,
+		Instance:  instId,
+		Node:      ndeId,
+		Timestamp: time.Now(),
+	}
+
+	_, err = coll.InsertOne(db, tkn)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func ClaimToken(db *database.Database, tknId string) (
+	tkn *Token, err error) {
+
+	coll := db.ConsoleTokens()
+	tkn = &Token{}
+
+	err = coll.FindOneId(tknId, tkn)
+	if err != nil {
+		return
+	}
+
+	resp, err := coll.DeleteOne(db, &bson.M{
+		"_id": tknId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if !tkn.claimable(resp.DeletedCount, time.Now()) {
+		tkn = nil
+		err = &errortypes.AuthenticationError{
+			errors.New("console: Token expired or already used"),
+		}
+		return
+	}
+
+	return
+}
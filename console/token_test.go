@@ -0,0 +1,39 @@
+package console
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenClaimable(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		deleted int64
+		age     time.Duration
+		want    bool
+	}{
+		{"new", 1, 0, true},
+		{"recent", 1, 10 * time.Second, true},
+		{"ttl", 1, tokenTtl, true},
+		{"expired", 1, tokenTtl + time.Millisecond, false},
+		{"old", 1, time.Hour, false},
+		{"already claimed", 0, 0, false},
+		{"already claimed expired", 0, time.Hour, false},
+		{"future", 1, -time.Second, true},
+	}
+
+	for _, test := range tests {
+		tkn := &Token{
+			Id:        "test",
+			Type:      Serial,
+			Timestamp: now.Add(-test.age),
+		}
+
+		if got := tkn.claimable(test.deleted, now); got != test.want {
+			t.Errorf("%s: claimable = %t, want %t",
+				test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qga"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func convertDisk(srcPth, dstPth string) (err error) {
+	err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
+		"-O", "qcow2", "-c", srcPth, dstPth)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+type diskInfo struct {
+	VirtualSize int64 `json:"virtual-size"`
+}
+
+// Create an empty qcow2 disk matching the virtual size of the source
+func createCopyDisk(srcPth, dstPth string) (err error) {
+	output, err := utils.ExecCombinedOutputLogged(
+		nil,
+		"qemu-img", "info", "-U", "--output=json", srcPth,
+	)
+	if err != nil {
+		return
+	}
+
+	info := &diskInfo{}
+	err = json.Unmarshal([]byte(output), info)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "data: Failed to parse disk info"),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"qemu-img", "create",
+		"-f", "qcow2",
+		dstPth,
+		strconv.FormatInt(info.VirtualSize, 10),
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func getRunningInstance(db *database.Database, dsk *disk.Disk) (
+	inst *instance.Instance, index int, err error) {
+
+	if dsk.Instance.IsZero() {
+		return
+	}
+
+	index, e := strconv.Atoi(dsk.Index)
+	if e != nil {
+		return
+	}
+
+	instc, err := instance.Get(db, dsk.Instance)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	if instc.Node != node.Self.Id || instc.VmState != vm.Running {
+		return
+	}
+
+	running, e := qms.IsRunning(instc.Id)
+	if e != nil || !running {
+		return
+	}
+
+	inst = instc
+	return
+}
+
+func exportDisk(db *database.Database, dsk *disk.Disk, dstPth string) (
+	consistency string, err error) {
+
+	dskPth := paths.GetDiskPath(dsk.Id)
+
+	inst, index, err := getRunningInstance(db, dsk)
+	if err != nil {
+		return
+	}
+
+	if inst == nil {
+		err = convertDisk(dskPth, dstPth)
+		if err != nil {
+			return
+		}
+
+		consistency = image.Offline
+		return
+	}
+
+	copyPth := path.Join(node.Self.GetCachePath(),
+		fmt.Sprintf("copy-%s", primitive.NewObjectID().Hex()))
+	defer utils.Remove(copyPth)
+
+	err = createCopyDisk(dskPth, copyPth)
+	if err != nil {
+		return
+	}
+
+	guestPth := paths.GetGuestPath(inst.Id)
+
+	frozen := false
+	_, e := qga.Freeze(guestPth)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"disk_id":     dsk.Id.Hex(),
+			"error":       e,
+		}).Warn("data: Failed to freeze guest filesystems, " +
+			"disk copy will be crash consistent")
+	} else {
+		frozen = true
+	}
+
+	// Backup job captures a point-in-time copy when started, filesystems
+	// can be thawed before the copy completes
+	jobId, err := qms.StartBackup(inst.Id, index, copyPth)
+
+	if frozen {
+		_, e = qga.Thaw(guestPth)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"disk_id":     dsk.Id.Hex(),
+				"error":       e,
+			}).Error("data: Failed to thaw guest filesystems")
+		}
+	}
+
+	if err != nil {
+		return
+	}
+
+	err = qms.WaitBackup(inst.Id, jobId, time.Duration(
+		settings.Hypervisor.BackupTimeout)*time.Second)
+	if err != nil {
+		return
+	}
+
+	err = convertDisk(copyPth, dstPth)
+	if err != nil {
+		return
+	}
+
+	if frozen {
+		consistency = image.Application
+	} else {
+		consistency = image.Crash
+	}
+
+	return
+}
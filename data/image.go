@@ -21,6 +21,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/storage"
+	"github.com/pritunl/pritunl-cloud/telemetry"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/zone"
 	"golang.org/x/crypto/openpgp"
@@ -68,6 +69,11 @@ func getImage(db *database.Database, img *image.Image,
 		"path":       pth,
 	}).Info("data: Downloading image")
 
+	start := time.Now()
+	defer func() {
+		telemetry.ObserveImageDownload(start, err)
+	}()
+
 	client, err := minio.New(
 		store.Endpoint, store.AccessKey, store.SecretKey, !store.Insecure)
 	if err != nil {
@@ -526,6 +532,11 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk) (err error) {
 		"disk_path":  dskPth,
 	}).Info("data: Creating disk snapshot")
 
+	start := time.Now()
+	defer func() {
+		telemetry.ObserveBackup(telemetry.Snapshot, start, err)
+	}()
+
 	imgId := primitive.NewObjectID()
 	tmpPath := path.Join(cacheDir,
 		fmt.Sprintf("snapshot-%s", imgId.Hex()))
@@ -540,11 +551,11 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk) (err error) {
 	}
 
 	defer utils.Remove(tmpPath)
-	err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
-		"-O", "qcow2", "-c", dskPth, tmpPath)
+	consistency, err := exportDisk(db, dsk, tmpPath)
 	if err != nil {
 		return
 	}
+	img.Consistency = consistency
 
 	err = utils.Chmod(tmpPath, 0600)
 	if err != nil {
@@ -552,10 +563,11 @@ func CreateSnapshot(db *database.Database, dsk *disk.Disk) (err error) {
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"disk_id":    dsk.Id.Hex(),
-		"disk_path":  dskPth,
-		"storage_id": store.Id.Hex(),
-		"object_key": img.Key,
+		"disk_id":     dsk.Id.Hex(),
+		"disk_path":   dskPth,
+		"storage_id":  store.Id.Hex(),
+		"object_key":  img.Key,
+		"consistency": img.Consistency,
 	}).Info("data: Uploading disk snapshot")
 
 	client, err := minio.New(
@@ -662,6 +674,11 @@ func CreateBackup(db *database.Database, dsk *disk.Disk) (err error) {
 		"disk_path":  dskPth,
 	}).Info("data: Creating disk backup")
 
+	start := time.Now()
+	defer func() {
+		telemetry.ObserveBackup(telemetry.Backup, start, err)
+	}()
+
 	imgId := primitive.NewObjectID()
 	tmpPath := path.Join(cacheDir,
 		fmt.Sprintf("backup-%s", imgId.Hex()))
@@ -677,11 +694,11 @@ func CreateBackup(db *database.Database, dsk *disk.Disk) (err error) {
 	}
 
 	defer utils.Remove(tmpPath)
-	err = utils.Exec("", "qemu-img", "convert", "-f", "qcow2",
-		"-O", "qcow2", "-c", dskPth, tmpPath)
+	consistency, err := exportDisk(db, dsk, tmpPath)
 	if err != nil {
 		return
 	}
+	img.Consistency = consistency
 
 	err = utils.Chmod(tmpPath, 0600)
 	if err != nil {
@@ -689,10 +706,11 @@ func CreateBackup(db *database.Database, dsk *disk.Disk) (err error) {
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"disk_id":    dsk.Id.Hex(),
-		"disk_path":  dskPth,
-		"storage_id": store.Id.Hex(),
-		"object_key": img.Key,
+		"disk_id":     dsk.Id.Hex(),
+		"disk_path":   dskPth,
+		"storage_id":  store.Id.Hex(),
+		"object_key":  img.Key,
+		"consistency": img.Consistency,
 	}).Info("data: Uploading disk backup")
 
 	client, err := minio.New(
@@ -805,6 +823,11 @@ func RestoreBackup(db *database.Database, dsk *disk.Disk) (err error) {
 		return
 	}
 
+	err = utils.ExistsMkdir(paths.GetDisksPath(), 0755)
+	if err != nil {
+		return
+	}
+
 	err = utils.Exec("", "mv", "-f", tmpPath, dskPth)
 	if err != nil {
 		return
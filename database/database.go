@@ -111,6 +111,16 @@ func (d *Database) SecondaryTokens() (coll *Collection) {
 	return
 }
 
+func (d *Database) ConsoleTokens() (coll *Collection) {
+	coll = d.getCollection("console_tokens")
+	return
+}
+
+func (d *Database) MetadataTokens() (coll *Collection) {
+	coll = d.getCollection("metadata_tokens")
+	return
+}
+
 func (d *Database) Nonces() (coll *Collection) {
 	coll = d.getCollection("nonces")
 	return
@@ -166,6 +176,16 @@ func (d *Database) Instances() (coll *Collection) {
 	return
 }
 
+func (d *Database) InstanceMetrics() (coll *Collection) {
+	coll = d.getCollection("instance_metrics")
+	return
+}
+
+func (d *Database) InstanceMetricsHourly() (coll *Collection) {
+	coll = d.getCollection("instance_metrics_hourly")
+	return
+}
+
 func (d *Database) Disks() (coll *Collection) {
 	coll = d.getCollection("disks")
 	return
@@ -186,6 +206,26 @@ func (d *Database) Firewalls() (coll *Collection) {
 	return
 }
 
+func (d *Database) LaunchTemplates() (coll *Collection) {
+	coll = d.getCollection("launch_templates")
+	return
+}
+
+func (d *Database) PlacementGroups() (coll *Collection) {
+	coll = d.getCollection("placement_groups")
+	return
+}
+
+func (d *Database) ElasticIps() (coll *Collection) {
+	coll = d.getCollection("elastic_ips")
+	return
+}
+
+func (d *Database) AutoscaleGroups() (coll *Collection) {
+	coll = d.getCollection("autoscale_groups")
+	return
+}
+
 func (d *Database) Vpcs() (coll *Collection) {
 	coll = d.getCollection("vpcs")
 	return
@@ -452,6 +492,30 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.ConsoleTokens(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 3 * time.Minute,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.MetadataTokens(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 20 * time.Minute,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Nodes(),
 		Keys: &bson.D{
@@ -837,6 +901,66 @@ func addIndexes() (err error) {
 	if err != nil {
 		return
 	}
+	index = &Index{
+		Collection: db.LaunchTemplates(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.PlacementGroups(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.ElasticIps(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.ElasticIps(),
+		Keys: &bson.D{
+			{"instance", 1},
+		},
+		Unique: true,
+		Partial: &bson.M{
+			"instance": &bson.M{
+				"$exists": true,
+			},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.AutoscaleGroups(),
+		Keys: &bson.D{
+			{"organization", 1},
+			{"name", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
 
 	index = &Index{
 		Collection: db.Zones(),
@@ -902,6 +1026,26 @@ func addIndexes() (err error) {
 	if err != nil {
 		return
 	}
+	index = &Index{
+		Collection: db.Instances(),
+		Keys: &bson.D{
+			{"placement_group", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+	index = &Index{
+		Collection: db.Instances(),
+		Keys: &bson.D{
+			{"autoscale_group", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
 	index = &Index{
 		Collection: db.Instances(),
 		Keys: &bson.D{
@@ -975,6 +1119,55 @@ func addIndexes() (err error) {
 		return
 	}
 
+	index = &Index{
+		Collection: db.InstanceMetrics(),
+		Keys: &bson.D{
+			{"instance", 1},
+			{"timestamp", 1},
+		},
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.InstanceMetrics(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 48 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.InstanceMetricsHourly(),
+		Keys: &bson.D{
+			{"instance", 1},
+			{"timestamp", 1},
+		},
+		Unique: true,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
+	index = &Index{
+		Collection: db.InstanceMetricsHourly(),
+		Keys: &bson.D{
+			{"timestamp", 1},
+		},
+		Expire: 2160 * time.Hour,
+	}
+	err = index.Create()
+	if err != nil {
+		return
+	}
+
 	index = &Index{
 		Collection: db.Geo(),
 		Keys: &bson.D{
@@ -40,6 +40,12 @@ func Deploy(stat *state.State) (err error) {
 		return
 	}
 
+	migrations := NewMigrations(stat)
+	err = migrations.Deploy()
+	if err != nil {
+		return
+	}
+
 	namespaces := NewNamespace(stat)
 	err = namespaces.Deploy()
 	if err != nil {
@@ -1,20 +1,25 @@
 package deploy
 
 import (
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/metadata"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/qemu"
 	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/resolver"
 	"github.com/pritunl/pritunl-cloud/state"
 	"github.com/pritunl/pritunl-cloud/store"
 	"github.com/pritunl/pritunl-cloud/utils"
@@ -23,8 +28,10 @@ import (
 )
 
 var (
-	instancesLock = utils.NewMultiTimeoutLock(5 * time.Minute)
-	limiter       = utils.NewLimiter(5)
+	instancesLock    = utils.NewMultiTimeoutLock(5 * time.Minute)
+	limiter          = utils.NewLimiter(5)
+	resizeFailed     = map[primitive.ObjectID]string{}
+	resizeFailedLock = sync.Mutex{}
 )
 
 type Instances struct {
@@ -53,6 +60,10 @@ func (s *Instances) create(inst *instance.Instance) {
 		db := database.GetDatabase()
 		defer db.Close()
 
+		if !s.owner(db, inst) {
+			return
+		}
+
 		err := qemu.Create(db, inst, inst.Virt)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
@@ -77,6 +88,37 @@ func (s *Instances) create(inst *instance.Instance) {
 	}()
 }
 
+func (s *Instances) owner(db *database.Database,
+	inst *instance.Instance) bool {
+
+	cur, err := instance.Get(db, inst.Id)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"error":       err,
+		}).Error("deploy: Failed to get instance owner")
+		return false
+	}
+
+	if node.Self.LeaseExpired() {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+		}).Warning("deploy: Ignoring start of instance on node " +
+			"with expired lease")
+		return false
+	}
+
+	if cur.Node != node.Self.Id {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"node_id":     cur.Node.Hex(),
+		}).Warning("deploy: Ignoring start of instance owned by another node")
+		return false
+	}
+
+	return true
+}
+
 func (s *Instances) start(inst *instance.Instance) {
 	if !limiter.Acquire() {
 		return
@@ -98,11 +140,17 @@ func (s *Instances) start(inst *instance.Instance) {
 		db := database.GetDatabase()
 		defer db.Close()
 
+		if !s.owner(db, inst) {
+			return
+		}
+
 		if inst.Restart || inst.RestartBlockIp {
 			inst.Restart = false
+			inst.RestartReason = ""
 			inst.RestartBlockIp = false
 			err := inst.CommitFields(db,
-				set.NewSet("restart", "restart_block_ip"))
+				set.NewSet("restart", "restart_reason",
+					"restart_block_ip"))
 			if err != nil {
 				logrus.WithFields(logrus.Fields{
 					"error": err,
@@ -236,9 +284,11 @@ func (s *Instances) restart(inst *instance.Instance) {
 
 		if inst.Restart || inst.RestartBlockIp {
 			inst.Restart = false
+			inst.RestartReason = ""
 			inst.RestartBlockIp = false
 			err = inst.CommitFields(db,
-				set.NewSet("restart", "restart_block_ip"))
+				set.NewSet("restart", "restart_reason",
+					"restart_block_ip"))
 			if err != nil {
 				logrus.WithFields(logrus.Fields{
 					"error": err,
@@ -334,6 +384,73 @@ func (s *Instances) destroy(inst *instance.Instance) {
 	}()
 }
 
+func (s *Instances) haStart(db *database.Database,
+	inst *instance.Instance) (err error) {
+
+	dsks := s.stat.GetInstaceDisks(inst.Id)
+	for _, dsk := range dsks {
+		if dsk.State != disk.Available {
+			return
+		}
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": inst.Id.Hex(),
+	}).Info("deploy: Starting failed over instance")
+
+	inst.State = instance.Start
+	inst.HaPending = false
+	err = inst.CommitFields(db, set.NewSet("state", "ha_pending"))
+	if err != nil {
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+
+	return
+}
+
+func (s *Instances) fence(virt *vm.VirtualMachine) {
+	acquired, lockId := instancesLock.LockOpen(virt.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(virt.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		inst, err := instance.Get(db, virt.Id)
+		if err != nil {
+			if _, ok := err.(*database.NotFoundError); !ok {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": virt.Id.Hex(),
+					"error":       err,
+				}).Error("deploy: Failed to get instance owner")
+			}
+			return
+		}
+
+		if inst.Node == node.Self.Id || inst.MigrateNode == node.Self.Id {
+			return
+		}
+
+		err = qemu.Fence(db, virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": virt.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to fence instance")
+			return
+		}
+	}()
+}
+
 func (s *Instances) diskRemove(inst *instance.Instance, remDisks []*vm.Disk) {
 	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
 	if !acquired {
@@ -364,6 +481,45 @@ func (s *Instances) diskRemove(inst *instance.Instance, remDisks []*vm.Disk) {
 	}()
 }
 
+func (s *Instances) resize(inst *instance.Instance,
+	curVirt *vm.VirtualMachine) {
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		key := fmt.Sprintf("%d-%d", inst.Virt.Processors, inst.Virt.Memory)
+
+		err := qemu.Resize(curVirt, inst.Virt.Processors, inst.Virt.Memory)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to resize instance")
+
+			resizeFailedLock.Lock()
+			resizeFailed[inst.Id] = key
+			resizeFailedLock.Unlock()
+		} else {
+			resizeFailedLock.Lock()
+			delete(resizeFailed, inst.Id)
+			resizeFailedLock.Unlock()
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
 func (s *Instances) diff(db *database.Database,
 	inst *instance.Instance) (err error) {
 
@@ -374,24 +530,47 @@ func (s *Instances) diff(db *database.Database,
 		changed = true
 	}
 
+	live, reason := inst.ResizeChanged(curVirt)
+	if live {
+		resizeFailedLock.Lock()
+		failedKey := resizeFailed[inst.Id]
+		resizeFailedLock.Unlock()
+
+		if failedKey == fmt.Sprintf("%d-%d",
+			inst.Virt.Processors, inst.Virt.Memory) {
+
+			live = false
+			reason = "Live resize failed, requires restart"
+		}
+	}
+	if reason != "" {
+		changed = true
+	}
+
 	if instancesLock.Locked(inst.Id.Hex()) {
 		return
 	}
 
-	if changed && !inst.Restart {
+	if changed && (!inst.Restart || inst.RestartReason != reason) {
 		inst.Restart = true
-		err = inst.CommitFields(db, set.NewSet("restart"))
+		inst.RestartReason = reason
+		err = inst.CommitFields(db, set.NewSet("restart", "restart_reason"))
 		if err != nil {
 			return
 		}
 	} else if !changed && inst.Restart {
 		inst.Restart = false
-		err = inst.CommitFields(db, set.NewSet("restart"))
+		inst.RestartReason = ""
+		err = inst.CommitFields(db, set.NewSet("restart", "restart_reason"))
 		if err != nil {
 			return
 		}
 	}
 
+	if live {
+		s.resize(inst, curVirt)
+	}
+
 	if len(remDisks) > 0 {
 		s.diskRemove(inst, remDisks)
 	}
@@ -565,6 +744,58 @@ func (s *Instances) routes(inst *instance.Instance) (err error) {
 	return
 }
 
+func (s *Instances) elasticIp(inst *instance.Instance,
+	curVirt *vm.VirtualMachine) (err error) {
+
+	nde := s.stat.Node()
+	if nde.NetworkMode != node.Static || inst.NoPublicAddress ||
+		len(curVirt.NetworkAdapters) == 0 {
+
+		return
+	}
+
+	curAddr := curVirt.NetworkAdapters[0].IpAddress
+	if curAddr == "" {
+		return
+	}
+
+	eip := s.stat.ElasticIp(inst.Id)
+	if eip != nil {
+		if eip.Address == curAddr {
+			return
+		}
+	} else if !s.stat.IsElasticIp(curAddr) {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		e := qemu.UpdateStaticAddr(db, inst.Virt)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       e,
+			}).Error("deploy: Failed to update instance elastic IP")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+
+	return
+}
+
 func (s *Instances) Deploy() (err error) {
 	db := database.GetDatabase()
 	defer db.Close()
@@ -579,10 +810,21 @@ func (s *Instances) Deploy() (err error) {
 
 	cpuUnits := 0
 	memoryUnits := 0.0
+	haVirts := set.NewSet()
 
 	for _, inst := range instances {
 		curVirt := s.stat.GetVirt(inst.Id)
 
+		if inst.Ha {
+			haVirts.Add(inst.Id)
+		}
+
+		if inst.IsMigrating() {
+			cpuUnits += inst.Processors
+			memoryUnits += float64(inst.Memory) / float64(1024)
+			continue
+		}
+
 		if inst.State == instance.Destroy {
 			if inst.DeleteProtection {
 				logrus.WithFields(logrus.Fields{
@@ -609,6 +851,14 @@ func (s *Instances) Deploy() (err error) {
 		cpuUnits += inst.Processors
 		memoryUnits += float64(inst.Memory) / float64(1024)
 
+		if inst.HaPending {
+			err = s.haStart(db, inst)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
 		if curVirt == nil {
 			if inst.State == instance.Start {
 				s.create(inst)
@@ -651,6 +901,35 @@ func (s *Instances) Deploy() (err error) {
 				return
 			}
 
+			err = s.elasticIp(inst, curVirt)
+			if err != nil {
+				return
+			}
+
+			e = metadata.Ensure(inst.Virt)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       e,
+				}).Error("deploy: Failed to start instance metadata server")
+			}
+
+			e = resolver.Ensure(inst.Virt, s.stat.Vpc(inst.Vpc))
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       e,
+				}).Error("deploy: Failed to start instance DNS resolver")
+			}
+
+			e = qemu.RotateSerialLog(inst.Id)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       e,
+				}).Error("deploy: Failed to rotate serial log")
+			}
+
 			break
 		case instance.Cleanup:
 			s.cleanup(inst)
@@ -678,6 +957,14 @@ func (s *Instances) Deploy() (err error) {
 		}
 	}
 
+	for _, virt := range s.stat.Virts() {
+		if s.stat.GetInstace(virt.Id) == nil {
+			s.fence(virt)
+		}
+	}
+
+	qemu.SetHaVirts(haVirts)
+
 	node.Self.CpuUnitsRes = cpuUnits
 	node.Self.MemoryUnitsRes = memoryUnits
 
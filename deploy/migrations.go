@@ -0,0 +1,986 @@
+package deploy
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qemu"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/state"
+	"github.com/pritunl/pritunl-cloud/transfer"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const (
+	migrateVarsFile = "ovmf_vars"
+)
+
+var (
+	migrateFields = set.NewSet(
+		"migrate_node",
+		"migrate_cold",
+		"migrate_state",
+		"migrate_progress",
+		"migrate_address",
+		"migrate_port",
+		"migrate_nbd_port",
+		"migrate_token",
+		"migrate_disks",
+		"migrate_vars_size",
+		"migrate_timestamp",
+	)
+)
+
+type Migrations struct {
+	stat *state.State
+}
+
+func getMigratePort(exclude ...int) (port int, err error) {
+	start := settings.Hypervisor.MigratePort
+	count := settings.Hypervisor.MigratePorts
+	if count < 1 {
+		count = 1
+	}
+	offset := rand.Intn(count)
+
+	for i := 0; i < count; i++ {
+		curPort := start + (offset+i)%count
+
+		excluded := false
+		for _, excludePort := range exclude {
+			if curPort == excludePort {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		listener, e := net.Listen("tcp", fmt.Sprintf(":%d", curPort))
+		if e != nil {
+			continue
+		}
+		listener.Close()
+
+		port = curPort
+		return
+	}
+
+	err = &errortypes.NetworkError{
+		errors.New("deploy: No migration ports available"),
+	}
+	return
+}
+
+func (s *Migrations) failed(db *database.Database,
+	inst *instance.Instance, err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": inst.Id.Hex(),
+		"error":       err,
+	}).Error("deploy: Failed to migrate instance")
+
+	inst.MigrateState = instance.MigrateFailed
+	inst.MigrateError = errors.GetMessage(err)
+	err = inst.CommitFields(db, set.NewSet("migrate_state", "migrate_error"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("deploy: Failed to commit instance")
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+}
+
+func (s *Migrations) prepare(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		curVirt := s.stat.GetVirt(inst.Id)
+		if curVirt != nil && curVirt.Resized {
+			s.failed(db, inst, &errortypes.RequestError{
+				errors.New("deploy: Cannot live migrate resized " +
+					"instance, restart instance or use cold migration"),
+			})
+			return
+		}
+
+		dsks, err := qemu.GetMigrateDisks(inst.Virt)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		varsSize, err := qemu.GetMigrateVarsSize(inst.Virt)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		inst.MigrateDisks = dsks
+		inst.MigrateVarsSize = varsSize
+		inst.MigrateState = instance.MigratePrepare
+		err = inst.CommitFields(db, set.NewSet(
+			"migrate_state", "migrate_disks", "migrate_vars_size"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Migrations) incoming(inst *instance.Instance) {
+	if !limiter.Acquire() {
+		return
+	}
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), 10*time.Minute)
+	if !acquired {
+		limiter.Release()
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+			limiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		addr := s.stat.Node().GetInternalAddr()
+		if addr == "" {
+			s.failed(db, inst, &errortypes.NotFoundError{
+				errors.New("deploy: Missing node migration address"),
+			})
+			return
+		}
+
+		port, err := getMigratePort()
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		nbdPort, err := getMigratePort(port)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		randKey, err := utils.RandBytes(32)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+		inst.MigrateToken = fmt.Sprintf("%x", randKey)
+
+		err = qemu.MigrateIncoming(db, inst, inst.Virt, addr, port, nbdPort)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		inst.MigrateAddress = addr
+		inst.MigratePort = port
+		inst.MigrateNbdPort = nbdPort
+		inst.MigrateState = instance.MigrateReady
+		err = inst.CommitFields(db, set.NewSet(
+			"migrate_state",
+			"migrate_address",
+			"migrate_port",
+			"migrate_nbd_port",
+			"migrate_token",
+		))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Migrations) cancel(db *database.Database,
+	inst *instance.Instance, err error) {
+
+	e := qemu.MigrateStop(inst, inst.Virt, true)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"error":       e,
+		}).Error("deploy: Failed to stop instance migration")
+	}
+
+	s.failed(db, inst, err)
+}
+
+func (s *Migrations) finish(db *database.Database, inst *instance.Instance) {
+	err := qemu.MigrateStop(inst, inst.Virt, false)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"error":       err,
+		}).Warn("deploy: Failed to release instance migration")
+	}
+
+	err = disk.SetInstanceNode(db, inst.Id, inst.MigrateNode, true)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("deploy: Failed to update instance disks")
+		return
+	}
+
+	inst.Node = inst.MigrateNode
+	inst.MigrateState = instance.MigrateComplete
+	inst.MigrateProgress = 100
+	err = inst.CommitFields(db, set.NewSet(
+		"node", "migrate_state", "migrate_progress"))
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("deploy: Failed to commit instance")
+		return
+	}
+
+	event.PublishDispatch(db, "instance.change")
+	event.PublishDispatch(db, "disk.change")
+
+	err = qemu.MigrateRemove(db, inst.Virt)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("deploy: Failed to remove migrated instance")
+		return
+	}
+}
+
+func (s *Migrations) migrate(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpenTimeout(inst.Id.Hex(),
+		time.Duration(settings.Hypervisor.MigrateTimeout+60)*time.Second)
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		timeout := time.Duration(
+			settings.Hypervisor.MigrateTimeout) * time.Second
+		start := time.Now()
+
+		err := qemu.MigrateStart(inst, inst.Virt)
+		if err != nil {
+			s.cancel(db, inst, err)
+			return
+		}
+
+		inst.MigrateState = instance.MigrateActive
+		inst.MigrateProgress = 0
+		err = inst.CommitFields(db,
+			set.NewSet("migrate_state", "migrate_progress"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+
+		for {
+			time.Sleep(1 * time.Second)
+
+			if time.Since(start) > timeout {
+				s.cancel(db, inst, &errortypes.TimeoutError{
+					errors.New("deploy: Migration timeout"),
+				})
+				return
+			}
+
+			curInst, e := instance.Get(db, inst.Id)
+			if e == nil && (curInst.State == instance.Destroy ||
+				curInst.MigrateState != instance.MigrateActive) {
+
+				s.cancel(db, inst, &errortypes.RequestError{
+					errors.New("deploy: Migration cancelled"),
+				})
+				return
+			}
+
+			status, e := qms.GetMigrateStatus(inst.Id)
+			if e != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       e,
+				}).Warn("deploy: Failed to get instance migration status")
+				continue
+			}
+
+			if status.Status == qms.MigrateCompleted {
+				break
+			}
+
+			if status.Status == qms.MigrateFailed ||
+				status.Status == qms.MigrateCancelled ||
+				status.Status == qms.MigrateNone {
+
+				s.cancel(db, inst, &errortypes.ExecError{
+					errors.Newf("deploy: Migration %s", status.Status),
+				})
+				return
+			}
+
+			progress := status.Progress()
+			if progress != inst.MigrateProgress {
+				inst.MigrateProgress = progress
+				err = inst.CommitFields(db, set.NewSet("migrate_progress"))
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("deploy: Failed to commit instance")
+					return
+				}
+
+				event.PublishDispatch(db, "instance.change")
+			}
+		}
+
+		s.finish(db, inst)
+	}()
+}
+
+// Migrations that are no longer managed by a migration routine, exceeded
+// the migration timeout or belong to a destroyed instance are stopped
+func (s *Migrations) expire(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		timeout := time.Duration(
+			settings.Hypervisor.MigrateTimeout) * time.Second
+		expired := time.Since(inst.MigrateTimestamp) > timeout
+
+		switch {
+		case inst.MigrateState == instance.MigrateFailed:
+			// Destination clears failed migrations, clear on the
+			// source if the destination never responds
+			if !expired {
+				return
+			}
+
+			inst.ClearMigrate()
+			err := inst.CommitFields(db, migrateFields)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"error": err,
+				}).Error("deploy: Failed to commit instance")
+				return
+			}
+
+			event.PublishDispatch(db, "instance.change")
+			break
+		case inst.MigrateState == instance.MigrateActive && !inst.MigrateCold:
+			status, e := qms.GetMigrateStatus(inst.Id)
+			if e == nil && status.Status == qms.MigrateCompleted {
+				s.finish(db, inst)
+				return
+			}
+
+			s.cancel(db, inst, &errortypes.ExecError{
+				errors.New("deploy: Migration interrupted"),
+			})
+			break
+		case inst.State == instance.Destroy:
+			s.failed(db, inst, &errortypes.RequestError{
+				errors.New("deploy: Migration cancelled"),
+			})
+			break
+		case expired:
+			s.failed(db, inst, &errortypes.TimeoutError{
+				errors.New("deploy: Migration timeout"),
+			})
+			break
+		}
+	}()
+}
+
+func (s *Migrations) serve(inst *instance.Instance) {
+	timeout := time.Duration(
+		settings.Hypervisor.MigrateTimeout) * time.Second
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), timeout+time.Minute)
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		dsks := []*instance.MigrateDisk{}
+		files := map[string]string{}
+
+		for _, dsk := range s.stat.GetInstaceDisks(inst.Id) {
+			diskPath := paths.GetDiskPath(dsk.Id)
+
+			info, err := os.Stat(diskPath)
+			if err != nil {
+				s.failed(db, inst, &errortypes.ReadError{
+					errors.Wrap(err, "deploy: Failed to stat disk"),
+				})
+				return
+			}
+
+			index := 0
+			for _, virtDsk := range inst.Virt.Disks {
+				if virtDsk.GetId() == dsk.Id {
+					index = virtDsk.Index
+					break
+				}
+			}
+
+			migrateDsk := &instance.MigrateDisk{
+				Id:    dsk.Id,
+				Index: index,
+				Size:  info.Size(),
+			}
+			files[dsk.Id.Hex()] = diskPath
+
+			if dsk.BackingImage != "" {
+				backingPath := paths.GetBackingImagePath(dsk.BackingImage)
+
+				info, err = os.Stat(backingPath)
+				if err != nil {
+					s.failed(db, inst, &errortypes.ReadError{
+						errors.Wrap(err,
+							"deploy: Failed to stat backing image"),
+					})
+					return
+				}
+
+				migrateDsk.BackingImage = dsk.BackingImage
+				migrateDsk.BackingSize = info.Size()
+				files[dsk.BackingImage] = backingPath
+			}
+
+			dsks = append(dsks, migrateDsk)
+		}
+
+		varsSize, err := qemu.GetMigrateVarsSize(inst.Virt)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+		if varsSize > 0 {
+			files[migrateVarsFile] = paths.GetOvmfVarsPath(inst.Id)
+		}
+
+		addr := s.stat.Node().GetInternalAddr()
+		if addr == "" {
+			s.failed(db, inst, &errortypes.NotFoundError{
+				errors.New("deploy: Missing node migration address"),
+			})
+			return
+		}
+
+		port, err := getMigratePort()
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		token, err := utils.RandStr(64)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		server := &transfer.Server{
+			Port:  port,
+			Token: token,
+			Files: files,
+		}
+
+		err = server.Start()
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+		defer server.Close()
+
+		migrateNode := inst.MigrateNode
+
+		inst.MigrateAddress = addr
+		inst.MigratePort = port
+		inst.MigrateToken = token
+		inst.MigrateDisks = dsks
+		inst.MigrateVarsSize = varsSize
+		inst.MigrateState = instance.MigrateReady
+		err = inst.CommitFields(db, set.NewSet(
+			"migrate_state",
+			"migrate_address",
+			"migrate_port",
+			"migrate_token",
+			"migrate_disks",
+			"migrate_vars_size",
+		))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+
+		start := time.Now()
+
+		for {
+			time.Sleep(2 * time.Second)
+
+			curInst, e := instance.Get(db, inst.Id)
+			if e != nil {
+				if _, ok := e.(*database.NotFoundError); ok {
+					return
+				}
+				continue
+			}
+
+			if curInst.Node == migrateNode {
+				err = qemu.MigrateRemove(db, inst.Virt)
+				if err != nil {
+					logrus.WithFields(logrus.Fields{
+						"error": err,
+					}).Error("deploy: Failed to remove migrated instance")
+				}
+				return
+			}
+
+			if curInst.MigrateNode != migrateNode ||
+				(curInst.MigrateState != instance.MigrateReady &&
+					curInst.MigrateState != instance.MigrateActive) {
+
+				return
+			}
+
+			if curInst.State == instance.Destroy {
+				s.failed(db, inst, &errortypes.RequestError{
+					errors.New("deploy: Migration cancelled"),
+				})
+				return
+			}
+
+			if time.Since(start) > timeout {
+				s.failed(db, inst, &errortypes.TimeoutError{
+					errors.New("deploy: Migration timeout"),
+				})
+				return
+			}
+		}
+	}()
+}
+
+func (s *Migrations) download(inst *instance.Instance) {
+	if !limiter.Acquire() {
+		return
+	}
+
+	timeout := time.Duration(
+		settings.Hypervisor.MigrateTimeout) * time.Second
+
+	acquired, lockId := instancesLock.LockOpenTimeout(
+		inst.Id.Hex(), timeout+time.Minute)
+	if !acquired {
+		limiter.Release()
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+			limiter.Release()
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		inst.MigrateState = instance.MigrateActive
+		inst.MigrateProgress = 0
+		err := inst.CommitFields(db,
+			set.NewSet("migrate_state", "migrate_progress"))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+
+		client, err := transfer.NewClient(db, inst.Node,
+			inst.MigrateAddress, inst.MigratePort, inst.MigrateToken)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		err = utils.ExistsMkdir(paths.GetDisksPath(), 0755)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		err = utils.ExistsMkdir(paths.GetBackingPath(), 0755)
+		if err != nil {
+			s.failed(db, inst, err)
+			return
+		}
+
+		total := inst.MigrateVarsSize
+		for _, dsk := range inst.MigrateDisks {
+			total += dsk.Size + dsk.BackingSize
+		}
+
+		transferred := int64(0)
+		progressLock := sync.Mutex{}
+		progressTime := time.Now()
+		progress := func(n int64) {
+			progressLock.Lock()
+			defer progressLock.Unlock()
+
+			transferred += n
+			if total <= 0 || time.Since(progressTime) < 3*time.Second {
+				return
+			}
+			progressTime = time.Now()
+
+			inst.MigrateProgress = int(transferred * 100 / total)
+			e := inst.CommitFields(db, set.NewSet("migrate_progress"))
+			if e != nil {
+				return
+			}
+
+			event.PublishDispatch(db, "instance.change")
+		}
+
+		diskPaths := []string{}
+		for _, dsk := range inst.MigrateDisks {
+			if dsk.BackingImage != "" {
+				backingPath := paths.GetBackingImagePath(dsk.BackingImage)
+
+				exists, e := utils.Exists(backingPath)
+				if e != nil {
+					err = e
+					break
+				}
+
+				if exists {
+					progress(dsk.BackingSize)
+				} else {
+					err = client.Download(
+						dsk.BackingImage, backingPath, progress)
+					if err != nil {
+						break
+					}
+				}
+			}
+
+			diskPath := paths.GetDiskPath(dsk.Id)
+
+			exists, e := utils.Exists(diskPath)
+			if e != nil {
+				err = e
+				break
+			}
+			if exists {
+				err = &errortypes.WriteError{
+					errors.New("deploy: Migration disk already exists"),
+				}
+				break
+			}
+
+			err = client.Download(dsk.Id.Hex(), diskPath, progress)
+			if err != nil {
+				break
+			}
+			diskPaths = append(diskPaths, diskPath)
+
+			if dsk.BackingImage != "" {
+				_, err = utils.ExecCombinedOutputLogged(
+					nil,
+					"qemu-img", "rebase", "-u",
+					"-f", "qcow2",
+					"-F", "qcow2",
+					"-b", paths.GetBackingImagePath(dsk.BackingImage),
+					diskPath,
+				)
+				if err != nil {
+					break
+				}
+			}
+		}
+
+		if err == nil && inst.MigrateVarsSize > 0 {
+			err = utils.ExistsMkdir(paths.GetVmPath(inst.Id), 0755)
+			if err == nil {
+				err = client.Download(migrateVarsFile,
+					paths.GetOvmfVarsPath(inst.Id), progress)
+			}
+		}
+
+		if err != nil {
+			for _, diskPath := range diskPaths {
+				_ = utils.RemoveAll(diskPath)
+			}
+
+			s.failed(db, inst, err)
+			return
+		}
+
+		err = block.RemoveInstanceIps(db, inst.Id)
+		if err != nil {
+			for _, diskPath := range diskPaths {
+				_ = utils.RemoveAll(diskPath)
+			}
+
+			s.failed(db, inst, err)
+			return
+		}
+
+		err = disk.SetInstanceNode(db, inst.Id, s.stat.Node().Id, false)
+		if err != nil {
+			for _, diskPath := range diskPaths {
+				_ = utils.RemoveAll(diskPath)
+			}
+
+			s.failed(db, inst, err)
+			return
+		}
+
+		inst.Node = s.stat.Node().Id
+		inst.Zone = s.stat.Node().Zone
+		inst.ClearMigrate()
+
+		fields := migrateFields.Copy()
+		fields.Add("node")
+		fields.Add("zone")
+
+		err = inst.CommitFields(db, fields)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+		event.PublishDispatch(db, "disk.change")
+	}()
+}
+
+func (s *Migrations) complete(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		err := qemu.MigrateComplete(db, inst, inst.Virt)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       err,
+			}).Error("deploy: Failed to complete instance migration")
+		}
+
+		inst.ClearMigrate()
+		err = inst.CommitFields(db, migrateFields)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Migrations) abort(inst *instance.Instance) {
+	acquired, lockId := instancesLock.LockOpen(inst.Id.Hex())
+	if !acquired {
+		return
+	}
+
+	go func() {
+		defer func() {
+			time.Sleep(3 * time.Second)
+			instancesLock.Unlock(inst.Id.Hex(), lockId)
+		}()
+
+		db := database.GetDatabase()
+		defer db.Close()
+
+		if s.stat.GetVirt(inst.Id) != nil {
+			err := qemu.MigrateRemove(db, inst.Virt)
+			if err != nil {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"error":       err,
+				}).Error("deploy: Failed to remove incoming instance")
+				return
+			}
+		}
+
+		inst.ClearMigrate()
+		err := inst.CommitFields(db, migrateFields)
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("deploy: Failed to commit instance")
+			return
+		}
+
+		event.PublishDispatch(db, "instance.change")
+	}()
+}
+
+func (s *Migrations) Deploy() (err error) {
+	ndeId := s.stat.Node().Id
+
+	timeout := time.Duration(
+		settings.Hypervisor.MigrateTimeout) * time.Second
+
+	for _, inst := range s.stat.Instances() {
+		if !inst.IsMigrating() || inst.Node != ndeId {
+			continue
+		}
+
+		if inst.State == instance.Destroy ||
+			inst.MigrateState == instance.MigrateFailed ||
+			(inst.MigrateState == instance.MigrateActive &&
+				!inst.MigrateCold) ||
+			time.Since(inst.MigrateTimestamp) > timeout+time.Minute {
+
+			s.expire(inst)
+			continue
+		}
+
+		switch inst.MigrateState {
+		case instance.MigratePending:
+			if inst.MigrateCold {
+				s.serve(inst)
+			} else {
+				s.prepare(inst)
+			}
+			break
+		case instance.MigrateReady:
+			if !inst.MigrateCold {
+				s.migrate(inst)
+			}
+			break
+		}
+	}
+
+	for _, inst := range s.stat.Migrations() {
+		switch inst.MigrateState {
+		case instance.MigratePrepare:
+			if inst.Node != ndeId {
+				s.incoming(inst)
+			}
+			break
+		case instance.MigrateReady:
+			if inst.MigrateCold && inst.Node != ndeId {
+				s.download(inst)
+			}
+			break
+		case instance.MigrateComplete:
+			if inst.Node == ndeId {
+				s.complete(inst)
+			}
+			break
+		case instance.MigrateFailed:
+			if inst.Node != ndeId {
+				s.abort(inst)
+			}
+			break
+		}
+	}
+
+	return
+}
+
+func NewMigrations(stat *state.State) *Migrations {
+	return &Migrations{
+		stat: stat,
+	}
+}
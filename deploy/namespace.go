@@ -57,6 +57,11 @@ func (n *Namespace) Deploy() (err error) {
 		if externalNetwork {
 			curExternalIfaces.Add(vm.GetIfaceExternal(inst.Id, 0))
 		}
+
+		for i := 1; i < len(inst.Virt.NetworkAdapters); i++ {
+			curNamespaces.Add(vm.GetNamespace(inst.Id, i))
+			curVirtIfaces.Add(vm.GetIfaceVirt(inst.Id, 2+i))
+		}
 	}
 
 	for _, iface := range ifaces {
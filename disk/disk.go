@@ -12,6 +12,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -79,6 +80,33 @@ func (d *Disk) Validate(db *database.Database) (
 		d.Size = 10
 	}
 
+	req := &organization.Usage{}
+	if d.Id.IsZero() {
+		req.Disk = d.Size
+		if d.Backup {
+			req.BackupStorage = d.Size
+		}
+	} else if d.Backup {
+		cur, e := Get(db, d.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if !cur.Backup {
+			req.BackupStorage = d.Size
+		}
+	}
+
+	errData, err = organization.CheckQuota(db, d.Organization, req)
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		return
+	}
+
 	return
 }
 
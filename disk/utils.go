@@ -260,6 +260,31 @@ func Detach(db *database.Database, dskIds primitive.ObjectID) (err error) {
 	return
 }
 
+func SetInstanceNode(db *database.Database, instId,
+	ndeId primitive.ObjectID, clearBacking bool) (err error) {
+
+	coll := db.Disks()
+
+	update := bson.M{
+		"node": ndeId,
+	}
+	if clearBacking {
+		update["backing_image"] = ""
+	}
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$set": update,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func Delete(db *database.Database, dskId primitive.ObjectID) (err error) {
 	coll := db.Disks()
 
@@ -0,0 +1,223 @@
+package elasticip
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/organization"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type ElasticIp struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Comment      string             `bson:"comment" json:"comment"`
+	Organization primitive.ObjectID `bson:"organization" json:"organization"`
+	Block        primitive.ObjectID `bson:"block" json:"block"`
+	Ip           int64              `bson:"ip" json:"-"`
+	Address      string             `bson:"-" json:"address"`
+	Instance     primitive.ObjectID `bson:"instance,omitempty" json:"instance"`
+}
+
+func (e *ElasticIp) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if e.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if e.Block.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "block_required",
+			Message: "Missing required block",
+		}
+		return
+	}
+
+	_, err = block.Get(db, e.Block)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "block_not_found",
+				Message: "Block does not exist",
+			}
+		}
+		return
+	}
+
+	if e.Id.IsZero() {
+		available := false
+		available, err = blockAvailable(db, e.Organization, e.Block)
+		if err != nil {
+			return
+		}
+
+		if !available {
+			errData = &errortypes.ErrorData{
+				Error:   "block_unavailable",
+				Message: "Block is not available in organization datacenters",
+			}
+			return
+		}
+
+		errData, err = organization.CheckQuota(db, e.Organization,
+			&organization.Usage{
+				PublicIps: 1,
+			})
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	if !e.Instance.IsZero() {
+		errData, err = e.validateInstance(db)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (e *ElasticIp) validateInstance(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	inst, err := getInstance(db, e.Instance)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "instance_not_found",
+				Message: "Instance does not exist",
+			}
+		}
+		return
+	}
+
+	if inst.Organization != e.Organization {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_organization_invalid",
+			Message: "Instance must be in the same organization",
+		}
+		return
+	}
+
+	nde, err := getNode(db, inst.Node)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "instance_node_invalid",
+				Message: "Instance is not assigned to a node",
+			}
+		}
+		return
+	}
+
+	if nde.NetworkMode != "static" || !nde.HasBlock(e.Block) {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_block_invalid",
+			Message: "Instance node does not have elastic IP block",
+		}
+		return
+	}
+
+	coll := db.ElasticIps()
+
+	n, err := coll.CountDocuments(db, &bson.M{
+		"_id": &bson.M{
+			"$ne": e.Id,
+		},
+		"instance": e.Instance,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if n > 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_elastic_ip_exists",
+			Message: "Instance already has an elastic IP attached",
+		}
+		return
+	}
+
+	return
+}
+
+func (e *ElasticIp) Json() {
+	if e.Ip != 0 {
+		e.Address = utils.Int2IpAddress(e.Ip).String()
+	}
+}
+
+func (e *ElasticIp) Commit(db *database.Database) (err error) {
+	coll := db.ElasticIps()
+
+	err = coll.Commit(e.Id, e)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (e *ElasticIp) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.ElasticIps()
+
+	err = coll.CommitFields(e.Id, e, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (e *ElasticIp) Insert(db *database.Database) (err error) {
+	coll := db.ElasticIps()
+
+	if !e.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("elasticip: Elastic IP already exists"),
+		}
+		return
+	}
+
+	blck, err := block.Get(db, e.Block)
+	if err != nil {
+		return
+	}
+
+	e.Id = primitive.NewObjectID()
+
+	// Block address is owned by the elastic IP instead of an instance
+	// to keep the address when instances are removed
+	ip, err := blck.GetIp(db, e.Id, block.Elastic)
+	if err != nil {
+		e.Id = primitive.NilObjectID
+		return
+	}
+	e.Ip = utils.IpAddress2Int(ip)
+
+	_, err = coll.InsertOne(db, e)
+	if err != nil {
+		err = database.ParseError(err)
+		_ = block.RemoveInstanceIps(db, e.Id)
+		e.Id = primitive.NilObjectID
+		return
+	}
+
+	return
+}
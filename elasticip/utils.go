@@ -0,0 +1,379 @@
+package elasticip
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type member struct {
+	Id           primitive.ObjectID `bson:"_id"`
+	Organization primitive.ObjectID `bson:"organization"`
+	Node         primitive.ObjectID `bson:"node"`
+}
+
+type host struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	NetworkMode string             `bson:"network_mode"`
+	Blocks      []*hostBlock       `bson:"blocks"`
+}
+
+type hostBlock struct {
+	Block primitive.ObjectID `bson:"block"`
+}
+
+func (h *host) HasBlock(blckId primitive.ObjectID) bool {
+	for _, blck := range h.Blocks {
+		if blck.Block == blckId {
+			return true
+		}
+	}
+	return false
+}
+
+func getInstance(db *database.Database, instId primitive.ObjectID) (
+	inst *member, err error) {
+
+	coll := db.Instances()
+	inst = &member{}
+
+	err = coll.FindOne(
+		db,
+		&bson.M{
+			"_id": instId,
+		},
+		&options.FindOneOptions{
+			Projection: &bson.D{
+				{"organization", 1},
+				{"node", 1},
+			},
+		},
+	).Decode(inst)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func getNode(db *database.Database, ndeId primitive.ObjectID) (
+	nde *host, err error) {
+
+	coll := db.Nodes()
+	nde = &host{}
+
+	err = coll.FindOne(
+		db,
+		&bson.M{
+			"_id": ndeId,
+		},
+		&options.FindOneOptions{
+			Projection: &bson.D{
+				{"network_mode", 1},
+				{"blocks", 1},
+			},
+		},
+	).Decode(nde)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+// Check if the block is attached to a static node in a datacenter
+// available to the organization
+func blockAvailable(db *database.Database, orgId,
+	blckId primitive.ObjectID) (available bool, err error) {
+
+	dcIds, err := datacenter.DistinctOrg(db, orgId)
+	if err != nil {
+		return
+	}
+
+	if len(dcIds) == 0 {
+		return
+	}
+
+	coll := db.Zones()
+	zoneIdsInf, err := coll.Distinct(db, "_id", &bson.M{
+		"datacenter": &bson.M{
+			"$in": dcIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	if len(zoneIdsInf) == 0 {
+		return
+	}
+
+	coll = db.Nodes()
+	n, err := coll.CountDocuments(db, &bson.M{
+		"zone": &bson.M{
+			"$in": zoneIdsInf,
+		},
+		"network_mode": "static",
+		"blocks.block": blckId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	available = n > 0
+	return
+}
+
+func Get(db *database.Database, eipId primitive.ObjectID) (
+	eip *ElasticIp, err error) {
+
+	coll := db.ElasticIps()
+	eip = &ElasticIp{}
+
+	err = coll.FindOneId(eipId, eip)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, eipId primitive.ObjectID) (
+	eip *ElasticIp, err error) {
+
+	coll := db.ElasticIps()
+	eip = &ElasticIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          eipId,
+		"organization": orgId,
+	}).Decode(eip)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetInstance(db *database.Database, instId primitive.ObjectID) (
+	eip *ElasticIp, err error) {
+
+	coll := db.ElasticIps()
+	eip = &ElasticIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"instance": instId,
+	}).Decode(eip)
+	if err != nil {
+		err = database.ParseError(err)
+		eip = nil
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	eips []*ElasticIp, err error) {
+
+	coll := db.ElasticIps()
+	eips = []*ElasticIp{}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		eip := &ElasticIp{}
+		err = cursor.Decode(eip)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		eips = append(eips, eip)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (eips []*ElasticIp, count int64, err error) {
+
+	coll := db.ElasticIps()
+	eips = []*ElasticIp{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	page = utils.Min64(page, count/pageCount)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		eip := &ElasticIp{}
+		err = cursor.Decode(eip)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		eips = append(eips, eip)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Detach(db *database.Database, instId primitive.ObjectID) (err error) {
+	coll := db.ElasticIps()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"instance": instId,
+	}, &bson.M{
+		"$unset": &bson.M{
+			"instance": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, eipId primitive.ObjectID) (err error) {
+	err = RemoveMulti(db, []primitive.ObjectID{eipId})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, eipId primitive.ObjectID) (
+	err error) {
+
+	err = RemoveMultiOrg(db, orgId, []primitive.ObjectID{eipId})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, eipIds []primitive.ObjectID) (
+	err error) {
+
+	err = remove(db, &bson.M{
+		"_id": &bson.M{
+			"$in": eipIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	eipIds []primitive.ObjectID) (err error) {
+
+	err = remove(db, &bson.M{
+		"_id": &bson.M{
+			"$in": eipIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func remove(db *database.Database, query *bson.M) (err error) {
+	coll := db.ElasticIps()
+
+	eips, err := GetAll(db, query)
+	if err != nil {
+		return
+	}
+
+	for _, eip := range eips {
+		_, err = coll.DeleteOne(db, &bson.M{
+			"_id": eip.Id,
+		})
+		if err != nil {
+			err = database.ParseError(err)
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+			} else {
+				return
+			}
+		}
+
+		err = block.RemoveInstanceIps(db, eip.Id)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/requires"
+	"github.com/pritunl/pritunl-cloud/telemetry"
 )
 
 var (
@@ -475,6 +476,8 @@ func subscribe(channels []string) {
 				return true
 			}
 
+			telemetry.ObserveEvent(msg.Channel, msg.Timestamp)
+
 			for _, listener := range listeners[msg.Channel] {
 				listener(msg)
 			}
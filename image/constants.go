@@ -0,0 +1,7 @@
+package image
+
+const (
+	Offline     = "offline"
+	Application = "application"
+	Crash       = "crash"
+)
@@ -25,6 +25,7 @@ type Image struct {
 	LastModified time.Time          `bson:"last_modified" json:"last_modified"`
 	StorageClass string             `bson:"storage_class" json:"storage_class"`
 	Etag         string             `bson:"etag" json:"etag"`
+	Consistency  string             `bson:"consistency" json:"consistency"`
 }
 
 func (i *Image) Validate(db *database.Database) (
@@ -98,6 +99,7 @@ func (i *Image) Upsert(db *database.Database) (err error) {
 				"last_modified": i.LastModified,
 				"storage_class": i.StorageClass,
 				"etag":          i.Etag,
+				"consistency":   i.Consistency,
 			},
 		},
 		opts,
@@ -89,6 +89,31 @@ func GetOrgPublic(db *database.Database, orgId, imgId primitive.ObjectID) (
 	return
 }
 
+func GetDiskLast(db *database.Database, dskId primitive.ObjectID) (
+	img *Image, err error) {
+
+	coll := db.Images()
+	img = &Image{}
+
+	err = coll.FindOne(
+		db,
+		&bson.M{
+			"disk": dskId,
+		},
+		&options.FindOneOptions{
+			Sort: &bson.D{
+				{"_id", -1},
+			},
+		},
+	).Decode(img)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func Distinct(db *database.Database, storeId primitive.ObjectID) (
 	keys []string, err error) {
 
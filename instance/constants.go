@@ -2,6 +2,7 @@ package instance
 
 import (
 	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 const (
@@ -13,6 +14,21 @@ const (
 	Destroy   = "destroy"
 )
 
+const (
+	MigratePending  = "pending"
+	MigratePrepare  = "prepare"
+	MigrateReady    = "ready"
+	MigrateActive   = "active"
+	MigrateComplete = "complete"
+	MigrateFailed   = "failed"
+)
+
+const (
+	MemoryHotplugAlign = 128
+	MaxAdapters        = 7
+	MaxUserDataSize    = 16384
+)
+
 var (
 	ValidStates = set.NewSet(
 		Provision,
@@ -22,4 +38,9 @@ var (
 		Restart,
 		Destroy,
 	)
+	ValidFirmwares = set.NewSet(
+		vm.Bios,
+		vm.Uefi,
+		vm.SecureUefi,
+	)
 )
@@ -1,9 +1,12 @@
 package instance
 
 import (
+	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/dropbox/godropbox/errors"
@@ -13,12 +16,16 @@ import (
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/systemd"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
 )
 
 type Instance struct {
@@ -27,6 +34,8 @@ type Instance struct {
 	Zone                primitive.ObjectID `bson:"zone" json:"zone"`
 	Vpc                 primitive.ObjectID `bson:"vpc" json:"vpc"`
 	Subnet              primitive.ObjectID `bson:"subnet" json:"subnet"`
+	StaticIp            string             `bson:"static_ip" json:"static_ip"`
+	Adapters            []*Adapter         `bson:"adapters" json:"adapters"`
 	Image               primitive.ObjectID `bson:"image" json:"image"`
 	ImageBacking        bool               `bson:"image_backing" json:"image_backing"`
 	Status              string             `bson:"-" json:"status"`
@@ -36,8 +45,11 @@ type Instance struct {
 	VmState             string             `bson:"vm_state" json:"vm_state"`
 	VmTimestamp         time.Time          `bson:"vm_timestamp" json:"vm_timestamp"`
 	Restart             bool               `bson:"restart" json:"restart"`
+	RestartReason       string             `bson:"restart_reason" json:"restart_reason"`
 	RestartBlockIp      bool               `bson:"restart_block_ip" json:"restart_block_ip"`
 	DeleteProtection    bool               `bson:"delete_protection" json:"delete_protection"`
+	Ha                  bool               `bson:"ha" json:"ha"`
+	HaPending           bool               `bson:"ha_pending" json:"ha_pending"`
 	PublicIps           []string           `bson:"public_ips" json:"public_ips"`
 	PublicIps6          []string           `bson:"public_ips6" json:"public_ips6"`
 	PrivateIps          []string           `bson:"private_ips" json:"private_ips"`
@@ -45,7 +57,12 @@ type Instance struct {
 	HostIps             []string           `bson:"host_ips" json:"host_ips"`
 	NoPublicAddress     bool               `bson:"no_public_address" json:"no_public_address"`
 	NoHostAddress       bool               `bson:"no_host_address" json:"no_host_address"`
+	Firmware            string             `bson:"firmware" json:"firmware"`
+	Tpm                 bool               `bson:"tpm" json:"tpm"`
 	Node                primitive.ObjectID `bson:"node" json:"node"`
+	PlacementGroup      primitive.ObjectID `bson:"placement_group,omitempty" json:"placement_group"`
+	AutoscaleGroup      primitive.ObjectID `bson:"autoscale_group,omitempty" json:"autoscale_group"`
+	HealthFailures      int                `bson:"health_failures" json:"health_failures"`
 	Domain              primitive.ObjectID `bson:"domain,omitempty" json:"domain"`
 	Name                string             `bson:"name" json:"name"`
 	Comment             string             `bson:"comment" json:"comment"`
@@ -53,17 +70,49 @@ type Instance struct {
 	Memory              int                `bson:"memory" json:"memory"`
 	Processors          int                `bson:"processors" json:"processors"`
 	NetworkRoles        []string           `bson:"network_roles" json:"network_roles"`
+	UserData            string             `bson:"user_data" json:"user_data"`
 	UsbDevices          []*usb.Device      `bson:"usb_devices" json:"usb_devices"`
 	Vnc                 bool               `bson:"vnc" json:"vnc"`
 	VncPassword         string             `bson:"vnc_password" json:"vnc_password"`
 	VncDisplay          int                `bson:"vnc_display,omitempty" json:"vnc_display"`
+	MigrateNode         primitive.ObjectID `bson:"migrate_node,omitempty" json:"migrate_node"`
+	MigrateCold         bool               `bson:"migrate_cold" json:"migrate_cold"`
+	MigrateState        string             `bson:"migrate_state" json:"migrate_state"`
+	MigrateProgress     int                `bson:"migrate_progress" json:"migrate_progress"`
+	MigrateError        string             `bson:"migrate_error" json:"migrate_error"`
+	MigrateAddress      string             `bson:"migrate_address" json:"-"`
+	MigratePort         int                `bson:"migrate_port" json:"-"`
+	MigrateNbdPort      int                `bson:"migrate_nbd_port" json:"-"`
+	MigrateToken        string             `bson:"migrate_token" json:"-"`
+	MigrateDisks        []*MigrateDisk     `bson:"migrate_disks" json:"-"`
+	MigrateVarsSize     int64              `bson:"migrate_vars_size" json:"-"`
+	MigrateTimestamp    time.Time          `bson:"migrate_timestamp" json:"-"`
 	Virt                *vm.VirtualMachine `bson:"-" json:"-"`
 	curVpc              primitive.ObjectID `bson:"-" json:"-"`
 	curSubnet           primitive.ObjectID `bson:"-" json:"-"`
+	curAdapters         []*Adapter         `bson:"-" json:"-"`
 	curDeleteProtection bool               `bson:"-" json:"-"`
 	curState            string             `bson:"-" json:"-"`
 	curNoPublicAddress  bool               `bson:"-" json:"-"`
 	curNoHostAddress    bool               `bson:"-" json:"-"`
+	curMemory           int                `bson:"-" json:"-"`
+	curProcessors       int                `bson:"-" json:"-"`
+	curPlacementGroup   primitive.ObjectID `bson:"-" json:"-"`
+	curNode             primitive.ObjectID `bson:"-" json:"-"`
+}
+
+type MigrateDisk struct {
+	Id           primitive.ObjectID `bson:"id"`
+	Index        int                `bson:"index"`
+	Size         int64              `bson:"size"`
+	BackingImage string             `bson:"backing_image"`
+	BackingSize  int64              `bson:"backing_size"`
+}
+
+type Adapter struct {
+	Vpc      primitive.ObjectID `bson:"vpc" json:"vpc"`
+	Subnet   primitive.ObjectID `bson:"subnet" json:"subnet"`
+	StaticIp string             `bson:"static_ip" json:"static_ip"`
 }
 
 func (i *Instance) Validate(db *database.Database) (
@@ -75,6 +124,7 @@ func (i *Instance) Validate(db *database.Database) (
 
 	if i.State != Start {
 		i.Restart = false
+		i.RestartReason = ""
 		i.RestartBlockIp = false
 	}
 
@@ -110,6 +160,29 @@ func (i *Instance) Validate(db *database.Database) (
 		return
 	}
 
+	if i.Id.IsZero() || i.Node != i.curNode {
+		nde, e := node.Get(db, i.Node)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "node_invalid",
+					Message: "Node does not exist",
+				}
+				return
+			}
+			err = e
+			return
+		}
+
+		if nde.Maintenance {
+			errData = &errortypes.ErrorData{
+				Error:   "node_maintenance",
+				Message: "Node is in maintenance",
+			}
+			return
+		}
+	}
+
 	if i.Image.IsZero() {
 		errData = &errortypes.ErrorData{
 			Error:   "image_required",
@@ -148,6 +221,92 @@ func (i *Instance) Validate(db *database.Database) (
 		return
 	}
 
+	if i.StaticIp != "" {
+		errData, err = vc.CheckIp(db, i.Subnet, i.Id, i.StaticIp)
+		if err != nil || errData != nil {
+			return
+		}
+	}
+
+	if i.Adapters == nil {
+		i.Adapters = []*Adapter{}
+	} else if len(i.Adapters) > 0 {
+		if len(i.Adapters) > MaxAdapters {
+			errData = &errortypes.ErrorData{
+				Error:   "adapters_invalid",
+				Message: "Too many network adapters",
+			}
+			return
+		}
+
+		zne, e := zone.Get(db, i.Zone)
+		if e != nil {
+			err = e
+			return
+		}
+
+		vpcIds := set.NewSet(i.Vpc)
+		for _, adapter := range i.Adapters {
+			if adapter.Vpc.IsZero() {
+				errData = &errortypes.ErrorData{
+					Error:   "adapter_vpc_required",
+					Message: "Missing required network adapter VPC",
+				}
+				return
+			}
+
+			// Addresses, MACs and static IPs are keyed by VPC
+			if vpcIds.Contains(adapter.Vpc) {
+				errData = &errortypes.ErrorData{
+					Error:   "adapter_vpc_duplicate",
+					Message: "Network adapters must use different VPCs",
+				}
+				return
+			}
+			vpcIds.Add(adapter.Vpc)
+
+			adapterVc, e := vpc.Get(db, adapter.Vpc)
+			if e != nil {
+				err = e
+				return
+			}
+
+			if adapterVc.Organization != i.Organization ||
+				adapterVc.Datacenter != zne.Datacenter {
+
+				errData = &errortypes.ErrorData{
+					Error:   "adapter_vpc_invalid",
+					Message: "Network adapter VPC not in instance datacenter",
+				}
+				return
+			}
+
+			if adapter.Subnet.IsZero() {
+				errData = &errortypes.ErrorData{
+					Error:   "adapter_vpc_subnet_required",
+					Message: "Missing required network adapter VPC subnet",
+				}
+				return
+			}
+
+			if adapterVc.GetSubnet(adapter.Subnet) == nil {
+				errData = &errortypes.ErrorData{
+					Error:   "adapter_vpc_subnet_missing",
+					Message: "Network adapter VPC subnet does not exist",
+				}
+				return
+			}
+
+			if adapter.StaticIp != "" {
+				errData, err = adapterVc.CheckIp(db, adapter.Subnet,
+					i.Id, adapter.StaticIp)
+				if err != nil || errData != nil {
+					return
+				}
+			}
+		}
+	}
+
 	if i.InitDiskSize != 0 && i.InitDiskSize < 10 {
 		errData = &errortypes.ErrorData{
 			Error:   "init_disk_size_invalid",
@@ -164,6 +323,23 @@ func (i *Instance) Validate(db *database.Database) (
 		i.Processors = 1
 	}
 
+	if i.Firmware == "" {
+		i.Firmware = vm.Bios
+	}
+
+	if !ValidFirmwares.Contains(i.Firmware) {
+		errData = &errortypes.ErrorData{
+			Error:   "firmware_invalid",
+			Message: "Invalid instance firmware",
+		}
+		return
+	}
+
+	errData = i.validateUserData()
+	if errData != nil {
+		return
+	}
+
 	if i.NetworkRoles == nil {
 		i.NetworkRoles = []string{}
 	}
@@ -202,6 +378,58 @@ func (i *Instance) Validate(db *database.Database) (
 		}
 	}
 
+	if !i.PlacementGroup.IsZero() && (i.Id.IsZero() ||
+		i.PlacementGroup != i.curPlacementGroup) {
+
+		grp, e := placement.GetOrg(db, i.Organization, i.PlacementGroup)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "placement_group_invalid",
+					Message: "Placement group does not exist",
+				}
+				return
+			}
+			err = e
+			return
+		}
+
+		errData, err = grp.Check(db, i.Id, i.Node)
+		if err != nil {
+			return
+		}
+
+		if errData != nil {
+			return
+		}
+	}
+
+	req := &organization.Usage{}
+	if i.Id.IsZero() {
+		req.Instances = 1
+		req.Processors = i.Processors
+		req.Memory = i.Memory
+		req.Disk = utils.Max(i.InitDiskSize, 10)
+		if !i.NoPublicAddress {
+			req.PublicIps = 1
+		}
+	} else {
+		req.Processors = i.Processors - i.curProcessors
+		req.Memory = i.Memory - i.curMemory
+		if i.curNoPublicAddress && !i.NoPublicAddress {
+			req.PublicIps = 1
+		}
+	}
+
+	errData, err = organization.CheckQuota(db, i.Organization, req)
+	if err != nil {
+		return
+	}
+
+	if errData != nil {
+		return
+	}
+
 	if i.Vnc {
 		if i.VncDisplay == 0 {
 			i.VncDisplay = rand.Intn(9998) + 4101
@@ -219,6 +447,41 @@ func (i *Instance) Validate(db *database.Database) (
 	return
 }
 
+func (i *Instance) validateUserData() (errData *errortypes.ErrorData) {
+	if len(i.UserData) > MaxUserDataSize {
+		errData = &errortypes.ErrorData{
+			Error:   "user_data_size",
+			Message: "User data exceeds maximum size",
+		}
+		return
+	}
+
+	if i.UserData != "" {
+		if !utf8.ValidString(i.UserData) {
+			errData = &errortypes.ErrorData{
+				Error:   "user_data_invalid",
+				Message: "User data must be valid UTF-8",
+			}
+			return
+		}
+
+		i.UserData = strings.Replace(i.UserData, "\r\n", "\n", -1)
+
+		firstLine := strings.TrimSpace(strings.SplitN(i.UserData, "\n", 2)[0])
+		if firstLine != "#cloud-config" &&
+			!strings.HasPrefix(firstLine, "#!") {
+
+			errData = &errortypes.ErrorData{
+				Error:   "user_data_invalid",
+				Message: "User data must begin with #cloud-config or #!",
+			}
+			return
+		}
+	}
+
+	return
+}
+
 func (i *Instance) Format() {
 	// TODO Sort VPC IDs
 }
@@ -312,6 +575,10 @@ func (i *Instance) Json() {
 		break
 	}
 
+	if i.IsMigrating() {
+		i.Status = "Migrating"
+	}
+
 	i.PublicMac = vm.GetMacAddrExternal(i.Id, i.Vpc)
 	if i.VmTimestamp.IsZero() {
 		i.Uptime = ""
@@ -325,13 +592,36 @@ func (i *Instance) IsActive() bool {
 		i.VmState == vm.Starting || i.VmState == vm.Provisioning
 }
 
+func (i *Instance) IsMigrating() bool {
+	return i.MigrateState != ""
+}
+
+func (i *Instance) ClearMigrate() {
+	i.MigrateNode = primitive.NilObjectID
+	i.MigrateCold = false
+	i.MigrateState = ""
+	i.MigrateProgress = 0
+	i.MigrateAddress = ""
+	i.MigratePort = 0
+	i.MigrateNbdPort = 0
+	i.MigrateToken = ""
+	i.MigrateDisks = nil
+	i.MigrateVarsSize = 0
+	i.MigrateTimestamp = time.Time{}
+}
+
 func (i *Instance) PreCommit() {
 	i.curVpc = i.Vpc
 	i.curSubnet = i.Subnet
+	i.curAdapters = i.Adapters
 	i.curDeleteProtection = i.DeleteProtection
 	i.curState = i.State
 	i.curNoPublicAddress = i.NoPublicAddress
 	i.curNoHostAddress = i.NoHostAddress
+	i.curMemory = i.Memory
+	i.curProcessors = i.Processors
+	i.curPlacementGroup = i.PlacementGroup
+	i.curNode = i.Node
 }
 
 func (i *Instance) PostCommit(db *database.Database) (
@@ -346,6 +636,29 @@ func (i *Instance) PostCommit(db *database.Database) (
 		}
 	}
 
+	if i.curAdapters != nil {
+		adapterSubnets := map[primitive.ObjectID]primitive.ObjectID{}
+		for _, adapter := range i.Adapters {
+			adapterSubnets[adapter.Vpc] = adapter.Subnet
+		}
+
+		for _, adapter := range i.curAdapters {
+			if adapter.Vpc == i.Vpc {
+				continue
+			}
+
+			subId, ok := adapterSubnets[adapter.Vpc]
+			if ok && subId == adapter.Subnet {
+				continue
+			}
+
+			err = vpc.RemoveInstanceIp(db, i.Id, adapter.Vpc)
+			if err != nil {
+				return
+			}
+		}
+	}
+
 	if i.curDeleteProtection != i.DeleteProtection {
 		dskChange = true
 
@@ -359,6 +672,7 @@ func (i *Instance) PostCommit(db *database.Database) (
 		i.State == Restart) {
 
 		i.Restart = false
+		i.RestartReason = ""
 		i.RestartBlockIp = false
 	}
 
@@ -423,27 +737,47 @@ func (i *Instance) Insert(db *database.Database) (err error) {
 }
 
 func (i *Instance) LoadVirt(disks []*disk.Disk) {
+	maxProcessors := utils.Max(i.Processors,
+		settings.Hypervisor.MaxProcessors)
+	maxMemory := utils.Max(i.Memory, settings.Hypervisor.MaxMemory)
+
 	i.Virt = &vm.VirtualMachine{
-		Id:         i.Id,
-		Image:      i.Image,
-		Processors: i.Processors,
-		Memory:     i.Memory,
-		Vnc:        i.Vnc,
-		VncDisplay: i.VncDisplay,
-		Disks:      []*vm.Disk{},
+		Id:            i.Id,
+		Image:         i.Image,
+		Processors:    i.Processors,
+		Memory:        i.Memory,
+		MaxProcessors: maxProcessors,
+		MaxMemory:     maxMemory,
+		Vnc:           i.Vnc,
+		VncDisplay:    i.VncDisplay,
+		Disks:         []*vm.Disk{},
 		NetworkAdapters: []*vm.NetworkAdapter{
 			&vm.NetworkAdapter{
 				Type:       vm.Bridge,
 				MacAddress: vm.GetMacAddr(i.Id, i.Vpc),
 				Vpc:        i.Vpc,
 				Subnet:     i.Subnet,
+				StaticIp:   i.StaticIp,
 			},
 		},
 		NoPublicAddress: i.NoPublicAddress,
 		NoHostAddress:   i.NoHostAddress,
+		Firmware:        i.Firmware,
+		Tpm:             i.Tpm,
 		UsbDevices:      []*vm.UsbDevice{},
 	}
 
+	for _, adapter := range i.Adapters {
+		i.Virt.NetworkAdapters = append(i.Virt.NetworkAdapters,
+			&vm.NetworkAdapter{
+				Type:       vm.Bridge,
+				MacAddress: vm.GetMacAddr(i.Id, adapter.Vpc),
+				Vpc:        adapter.Vpc,
+				Subnet:     adapter.Subnet,
+				StaticIp:   adapter.StaticIp,
+			})
+	}
+
 	if disks != nil {
 		for _, dsk := range disks {
 			index, err := strconv.Atoi(dsk.Index)
@@ -471,16 +805,20 @@ func (i *Instance) LoadVirt(disks []*disk.Disk) {
 }
 
 func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
-	if i.Virt.Memory != curVirt.Memory ||
-		i.Virt.Processors != curVirt.Processors ||
-		i.Virt.Vnc != curVirt.Vnc ||
+	if i.Virt.Vnc != curVirt.Vnc ||
 		i.Virt.VncDisplay != curVirt.VncDisplay ||
 		i.Virt.NoPublicAddress != curVirt.NoPublicAddress ||
-		i.Virt.NoHostAddress != curVirt.NoHostAddress {
+		i.Virt.NoHostAddress != curVirt.NoHostAddress ||
+		i.Virt.Firmware != curVirt.Firmware ||
+		i.Virt.Tpm != curVirt.Tpm {
 
 		return true
 	}
 
+	if len(i.Virt.NetworkAdapters) != len(curVirt.NetworkAdapters) {
+		return true
+	}
+
 	for i, adapter := range i.Virt.NetworkAdapters {
 		if len(curVirt.NetworkAdapters) <= i {
 			return true
@@ -493,6 +831,10 @@ func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
 		if adapter.Subnet != curVirt.NetworkAdapters[i].Subnet {
 			return true
 		}
+
+		if adapter.StaticIp != curVirt.NetworkAdapters[i].StaticIp {
+			return true
+		}
 	}
 
 	if i.Virt.UsbDevices != nil {
@@ -512,6 +854,41 @@ func (i *Instance) Changed(curVirt *vm.VirtualMachine) bool {
 	return false
 }
 
+func (i *Instance) ResizeChanged(curVirt *vm.VirtualMachine) (
+	live bool, reason string) {
+
+	if i.Virt.Processors == curVirt.Processors &&
+		i.Virt.Memory == curVirt.Memory {
+
+		return
+	}
+
+	if i.Virt.Processors < curVirt.Processors ||
+		i.Virt.Memory < curVirt.Memory {
+
+		reason = "Reducing processors or memory requires restart"
+		return
+	}
+
+	if i.Virt.Processors > curVirt.MaxProcessors ||
+		i.Virt.Memory > curVirt.MaxMemory {
+
+		reason = "Processors or memory exceeds hotplug limit, " +
+			"requires restart"
+		return
+	}
+
+	if (i.Virt.Memory-curVirt.Memory)%MemoryHotplugAlign != 0 {
+		reason = fmt.Sprintf("Memory increase not a multiple of %dMB, "+
+			"requires restart", MemoryHotplugAlign)
+		return
+	}
+
+	live = true
+
+	return
+}
+
 func (i *Instance) DiskChanged(curVirt *vm.VirtualMachine) (
 	addDisks, remDisks []*vm.Disk) {
 
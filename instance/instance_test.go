@@ -0,0 +1,98 @@
+package instance
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateUserData(t *testing.T) {
+	tests := []struct {
+		name     string
+		userData string
+		wantErr  string
+		wantData string
+	}{
+		{
+			name:     "empty",
+			userData: "",
+			wantData: "",
+		},
+		{
+			name:     "cloud config",
+			userData: "#cloud-config\npackages:\n  - nginx\n",
+			wantData: "#cloud-config\npackages:\n  - nginx\n",
+		},
+		{
+			name:     "cloud config padded",
+			userData: "  #cloud-config  \nhostname: test\n",
+			wantData: "  #cloud-config  \nhostname: test\n",
+		},
+		{
+			name:     "shell script",
+			userData: "#!/bin/bash\necho test\n",
+			wantData: "#!/bin/bash\necho test\n",
+		},
+		{
+			name:     "crlf",
+			userData: "#!/bin/sh\r\necho test\r\n",
+			wantData: "#!/bin/sh\necho test\n",
+		},
+		{
+			name:     "crlf cloud config",
+			userData: "#cloud-config\r\nhostname: test\r\n",
+			wantData: "#cloud-config\nhostname: test\n",
+		},
+		{
+			name:     "no header",
+			userData: "hostname: test\n",
+			wantErr:  "user_data_invalid",
+		},
+		{
+			name:     "comment header",
+			userData: "# cloud-config\nhostname: test\n",
+			wantErr:  "user_data_invalid",
+		},
+		{
+			name:     "invalid utf8",
+			userData: "#cloud-config\nhostname: \xff\xfe\n",
+			wantErr:  "user_data_invalid",
+		},
+		{
+			name:     "maximum size",
+			userData: "#!/bin/sh\n" + strings.Repeat("#", MaxUserDataSize-10),
+			wantData: "#!/bin/sh\n" + strings.Repeat("#", MaxUserDataSize-10),
+		},
+		{
+			name:     "oversize",
+			userData: "#!/bin/sh\n" + strings.Repeat("#", MaxUserDataSize),
+			wantErr:  "user_data_size",
+		},
+	}
+
+	for _, test := range tests {
+		inst := &Instance{
+			UserData: test.userData,
+		}
+
+		errData := inst.validateUserData()
+		if test.wantErr != "" {
+			if errData == nil {
+				t.Errorf("%s: expected %s", test.name, test.wantErr)
+			} else if errData.Error != test.wantErr {
+				t.Errorf("%s: error %s, want %s",
+					test.name, errData.Error, test.wantErr)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			continue
+		}
+
+		if inst.UserData != test.wantData {
+			t.Errorf("%s: user data %q, want %q",
+				test.name, inst.UserData, test.wantData)
+		}
+	}
+}
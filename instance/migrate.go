@@ -0,0 +1,209 @@
+package instance
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+func (i *Instance) CanMigrateCold() bool {
+	return i.State == Stop && (i.VmState == vm.Stopped ||
+		i.VmState == vm.Failed || i.VmState == "")
+}
+
+func (i *Instance) CanMigrateLive() bool {
+	return i.State == Start && i.VmState == vm.Running
+}
+
+func (i *Instance) Migrate(db *database.Database, nde *node.Node) (
+	errData *errortypes.ErrorData, err error) {
+
+	if i.IsMigrating() {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_migrating",
+			Message: "Instance is already migrating",
+		}
+		return
+	}
+
+	cold := false
+	if i.CanMigrateCold() {
+		cold = true
+	} else if !i.CanMigrateLive() {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_state_invalid",
+			Message: "Instance must be running or stopped to migrate",
+		}
+		return
+	}
+
+	if len(i.UsbDevices) > 0 {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_usb_devices",
+			Message: "Cannot migrate instance with USB devices",
+		}
+		return
+	}
+
+	if nde == nil || nde.Id == i.Node {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_invalid",
+			Message: "Invalid migration node",
+		}
+		return
+	}
+
+	if !nde.IsHypervisor() || !nde.IsOnline() {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_unavailable",
+			Message: "Migration node is not an online hypervisor",
+		}
+		return
+	}
+
+	if nde.Maintenance {
+		errData = &errortypes.ErrorData{
+			Error:   "migrate_node_maintenance",
+			Message: "Migration node is in maintenance",
+		}
+		return
+	}
+
+	curNde, err := node.Get(db, i.Node)
+	if err != nil {
+		return
+	}
+
+	if cold {
+		curZne, e := zone.Get(db, curNde.Zone)
+		if e != nil {
+			err = e
+			return
+		}
+
+		zne, e := zone.Get(db, nde.Zone)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if zne.Datacenter != curZne.Datacenter {
+			errData = &errortypes.ErrorData{
+				Error:   "migrate_node_datacenter",
+				Message: "Migration node must be in instance datacenter",
+			}
+			return
+		}
+	} else {
+		if nde.Zone != i.Zone {
+			errData = &errortypes.ErrorData{
+				Error:   "migrate_node_zone",
+				Message: "Migration node must be in the instance zone",
+			}
+			return
+		}
+
+		if nde.Hypervisor != curNde.Hypervisor || nde.Vga != curNde.Vga {
+			errData = &errortypes.ErrorData{
+				Error:   "migrate_node_incompatible",
+				Message: "Migration node hypervisor configuration differs",
+			}
+			return
+		}
+	}
+
+	if !i.PlacementGroup.IsZero() {
+		grp, e := placement.Get(db, i.PlacementGroup)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); !ok {
+				err = e
+				return
+			}
+		} else {
+			errData, err = grp.Check(db, i.Id, nde.Id)
+			if err != nil {
+				return
+			}
+
+			if errData != nil {
+				return
+			}
+		}
+	}
+
+	dsks, err := disk.GetInstance(db, i.Id)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range dsks {
+		if dsk.State != disk.Available {
+			errData = &errortypes.ErrorData{
+				Error:   "disk_not_available",
+				Message: "Instance disks must be available to migrate",
+			}
+			return
+		}
+	}
+
+	i.MigrateNode = nde.Id
+	i.MigrateCold = cold
+	i.MigrateState = MigratePending
+	i.MigrateProgress = 0
+	i.MigrateError = ""
+	i.MigrateTimestamp = time.Now()
+
+	err = i.CommitFields(db, set.NewSet(
+		"migrate_node",
+		"migrate_cold",
+		"migrate_state",
+		"migrate_progress",
+		"migrate_error",
+		"migrate_timestamp",
+	))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (i *Instance) MigrateCancel(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if !i.IsMigrating() || i.MigrateState == MigrateFailed {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_not_migrating",
+			Message: "Instance is not migrating",
+		}
+		return
+	}
+
+	if i.MigrateState == MigrateComplete {
+		errData = &errortypes.ErrorData{
+			Error:   "instance_migrate_complete",
+			Message: "Instance migration has already completed",
+		}
+		return
+	}
+
+	i.MigrateState = MigrateFailed
+	i.MigrateError = "Migration cancelled"
+
+	err = i.CommitFields(db, set.NewSet(
+		"migrate_state",
+		"migrate_error",
+	))
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/block"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/elasticip"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vpc"
 )
@@ -323,6 +324,11 @@ func Remove(db *database.Database, instId primitive.ObjectID) (err error) {
 		return
 	}
 
+	err = elasticip.Detach(db, instId)
+	if err != nil {
+		return
+	}
+
 	_, err = coll.DeleteOne(db, &bson.M{
 		"_id": instId,
 	})
@@ -0,0 +1,316 @@
+package launch
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/datacenter"
+	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/placement"
+	"github.com/pritunl/pritunl-cloud/usb"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+type Template struct {
+	Id               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name             string             `bson:"name" json:"name"`
+	Comment          string             `bson:"comment" json:"comment"`
+	Organization     primitive.ObjectID `bson:"organization,omitempty" json:"organization"`
+	Datacenter       primitive.ObjectID `bson:"datacenter,omitempty" json:"datacenter"`
+	Zone             primitive.ObjectID `bson:"zone,omitempty" json:"zone"`
+	Vpc              primitive.ObjectID `bson:"vpc" json:"vpc"`
+	Subnet           primitive.ObjectID `bson:"subnet" json:"subnet"`
+	Node             primitive.ObjectID `bson:"node,omitempty" json:"node"`
+	PlacementGroup   primitive.ObjectID `bson:"placement_group,omitempty" json:"placement_group"`
+	Image            primitive.ObjectID `bson:"image" json:"image"`
+	ImageBacking     bool               `bson:"image_backing" json:"image_backing"`
+	Domain           primitive.ObjectID `bson:"domain,omitempty" json:"domain"`
+	DeleteProtection bool               `bson:"delete_protection" json:"delete_protection"`
+	Ha               bool               `bson:"ha" json:"ha"`
+	InitDiskSize     int                `bson:"init_disk_size" json:"init_disk_size"`
+	Memory           int                `bson:"memory" json:"memory"`
+	Processors       int                `bson:"processors" json:"processors"`
+	NetworkRoles     []string           `bson:"network_roles" json:"network_roles"`
+	UsbDevices       []*usb.Device      `bson:"usb_devices" json:"usb_devices"`
+	Vnc              bool               `bson:"vnc" json:"vnc"`
+	NoPublicAddress  bool               `bson:"no_public_address" json:"no_public_address"`
+	NoHostAddress    bool               `bson:"no_host_address" json:"no_host_address"`
+	Firmware         string             `bson:"firmware" json:"firmware"`
+	Tpm              bool               `bson:"tpm" json:"tpm"`
+}
+
+func (t *Template) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if t.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if t.Zone.IsZero() && t.Datacenter.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "zone_required",
+			Message: "Missing required zone or datacenter",
+		}
+		return
+	}
+
+	if !t.Node.IsZero() && t.Zone.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "node_zone_required",
+			Message: "Node requires zone",
+		}
+		return
+	}
+
+	if t.Image.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "image_required",
+			Message: "Missing required image",
+		}
+		return
+	}
+
+	if t.Vpc.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_required",
+			Message: "Missing required VPC",
+		}
+		return
+	}
+
+	if t.Subnet.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_required",
+			Message: "Missing required VPC subnet",
+		}
+		return
+	}
+
+	if t.InitDiskSize != 0 && t.InitDiskSize < 10 {
+		errData = &errortypes.ErrorData{
+			Error:   "init_disk_size_invalid",
+			Message: "Disk size below minimum",
+		}
+		return
+	}
+
+	if t.Memory < 256 {
+		t.Memory = 256
+	}
+
+	if t.Processors < 1 {
+		t.Processors = 1
+	}
+
+	if t.Firmware == "" {
+		t.Firmware = vm.Bios
+	}
+
+	if !instance.ValidFirmwares.Contains(t.Firmware) {
+		errData = &errortypes.ErrorData{
+			Error:   "firmware_invalid",
+			Message: "Invalid template firmware",
+		}
+		return
+	}
+
+	if t.NetworkRoles == nil {
+		t.NetworkRoles = []string{}
+	}
+
+	if t.UsbDevices == nil {
+		t.UsbDevices = []*usb.Device{}
+	}
+
+	return
+}
+
+// Referenced resources must be available to the organization the
+// template launches instances in
+func (t *Template) ValidateOrg(db *database.Database,
+	orgId primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	dcId := t.Datacenter
+	if !t.Zone.IsZero() {
+		zne, e := zone.Get(db, t.Zone)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "zone_invalid",
+					Message: "Zone does not exist",
+				}
+				return
+			}
+			err = e
+			return
+		}
+
+		if !dcId.IsZero() && zne.Datacenter != dcId {
+			errData = &errortypes.ErrorData{
+				Error:   "zone_invalid",
+				Message: "Zone not in template datacenter",
+			}
+			return
+		}
+		dcId = zne.Datacenter
+	}
+
+	exists, err := datacenter.ExistsOrg(db, orgId, dcId)
+	if err != nil {
+		return
+	}
+	if !exists {
+		errData = &errortypes.ErrorData{
+			Error:   "datacenter_invalid",
+			Message: "Datacenter not available to organization",
+		}
+		return
+	}
+
+	if !t.Node.IsZero() {
+		nde, e := node.Get(db, t.Node)
+		if e != nil {
+			if _, ok := e.(*database.NotFoundError); ok {
+				errData = &errortypes.ErrorData{
+					Error:   "node_invalid",
+					Message: "Node does not exist",
+				}
+				return
+			}
+			err = e
+			return
+		}
+
+		if nde.Zone != t.Zone {
+			errData = &errortypes.ErrorData{
+				Error:   "node_invalid",
+				Message: "Node not in template zone",
+			}
+			return
+		}
+	}
+
+	vc, err := vpc.GetOrg(db, orgId, t.Vpc)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "vpc_invalid",
+				Message: "VPC does not exist",
+			}
+		}
+		return
+	}
+
+	if vc.Datacenter != dcId {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_invalid",
+			Message: "VPC not in template datacenter",
+		}
+		return
+	}
+
+	if vc.GetSubnet(t.Subnet) == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_missing",
+			Message: "VPC subnet does not exist",
+		}
+		return
+	}
+
+	if !t.Domain.IsZero() {
+		exists, err = domain.ExistsOrg(db, orgId, t.Domain)
+		if err != nil {
+			return
+		}
+		if !exists {
+			errData = &errortypes.ErrorData{
+				Error:   "domain_invalid",
+				Message: "Domain does not exist",
+			}
+			return
+		}
+	}
+
+	_, err = image.GetOrgPublic(db, orgId, t.Image)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			errData = &errortypes.ErrorData{
+				Error:   "image_not_found",
+				Message: "Image not found",
+			}
+		}
+		return
+	}
+
+	if !t.PlacementGroup.IsZero() {
+		_, err = placement.GetOrg(db, orgId, t.PlacementGroup)
+		if err != nil {
+			if _, ok := err.(*database.NotFoundError); ok {
+				err = nil
+				errData = &errortypes.ErrorData{
+					Error:   "placement_group_invalid",
+					Message: "Placement group does not exist",
+				}
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (t *Template) Commit(db *database.Database) (err error) {
+	coll := db.LaunchTemplates()
+
+	err = coll.Commit(t.Id, t)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Template) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.LaunchTemplates()
+
+	err = coll.CommitFields(t.Id, t, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (t *Template) Insert(db *database.Database) (err error) {
+	coll := db.LaunchTemplates()
+
+	if !t.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("launch: Template already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, t)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
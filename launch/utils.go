@@ -0,0 +1,212 @@
+package launch
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func Get(db *database.Database, tplId primitive.ObjectID) (
+	tpl *Template, err error) {
+
+	coll := db.LaunchTemplates()
+	tpl = &Template{}
+
+	err = coll.FindOneId(tplId, tpl)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, tplId primitive.ObjectID) (
+	tpl *Template, err error) {
+
+	coll := db.LaunchTemplates()
+	tpl = &Template{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          tplId,
+		"organization": orgId,
+	}).Decode(tpl)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	tpls []*Template, err error) {
+
+	coll := db.LaunchTemplates()
+	tpls = []*Template{}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		tpl := &Template{}
+		err = cursor.Decode(tpl)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		tpls = append(tpls, tpl)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (tpls []*Template, count int64, err error) {
+
+	coll := db.LaunchTemplates()
+	tpls = []*Template{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	page = utils.Min64(page, count/pageCount)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		tpl := &Template{}
+		err = cursor.Decode(tpl)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		tpls = append(tpls, tpl)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Remove(db *database.Database, tplId primitive.ObjectID) (err error) {
+	coll := db.LaunchTemplates()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id": tplId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, tplId primitive.ObjectID) (
+	err error) {
+
+	coll := db.LaunchTemplates()
+
+	_, err = coll.DeleteOne(db, &bson.M{
+		"_id":          tplId,
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, tplIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.LaunchTemplates()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": tplIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	tplIds []primitive.ObjectID) (err error) {
+
+	coll := db.LaunchTemplates()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": tplIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,11 @@
+package metadata
+
+import (
+	"time"
+)
+
+const (
+	Address  = "169.254.169.254"
+	Port     = 80
+	tokenTtl = 15 * time.Minute
+)
@@ -0,0 +1,129 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/authority"
+	"github.com/pritunl/pritunl-cloud/cloudinit"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+type publicKey struct {
+	Name string
+	Key  string
+}
+
+type metaData struct {
+	Id           string
+	Hostname     string
+	PrivateIps   []string
+	PrivateIps6  []string
+	PublicIps    []string
+	PublicIps6   []string
+	NetworkRoles []string
+	PublicKeys   []*publicKey
+}
+
+type openstackData struct {
+	Uuid       string            `json:"uuid"`
+	Name       string            `json:"name"`
+	Hostname   string            `json:"hostname"`
+	PublicKeys map[string]string `json:"public_keys"`
+	Meta       map[string]string `json:"meta"`
+}
+
+func (m *metaData) Openstack() (data *openstackData) {
+	data = &openstackData{
+		Uuid:       m.Id,
+		Name:       m.Hostname,
+		Hostname:   m.Hostname,
+		PublicKeys: map[string]string{},
+		Meta: map[string]string{
+			"network_roles": strings.Join(m.NetworkRoles, ","),
+			"private_ips":   strings.Join(m.PrivateIps, ","),
+			"private_ips6":  strings.Join(m.PrivateIps6, ","),
+			"public_ips":    strings.Join(m.PublicIps, ","),
+			"public_ips6":   strings.Join(m.PublicIps6, ","),
+		},
+	}
+
+	for _, key := range m.PublicKeys {
+		data.PublicKeys[key.Name] = key.Key
+	}
+
+	return
+}
+
+func getMetaData(db *database.Database, instId primitive.ObjectID) (
+	data *metaData, inst *instance.Instance, err error) {
+
+	inst, err = instance.Get(db, instId)
+	if err != nil {
+		return
+	}
+
+	data = &metaData{
+		Id:           inst.Id.Hex(),
+		Hostname:     strings.Replace(inst.Name, " ", "_", -1),
+		PrivateIps:   inst.PrivateIps,
+		PrivateIps6:  inst.PrivateIps6,
+		PublicIps:    inst.PublicIps,
+		PublicIps6:   inst.PublicIps6,
+		NetworkRoles: inst.NetworkRoles,
+		PublicKeys:   []*publicKey{},
+	}
+
+	authrs, err := authority.GetOrgRoles(db, inst.Organization,
+		inst.NetworkRoles)
+	if err != nil {
+		return
+	}
+
+	for _, authr := range authrs {
+		if authr.Type != authority.SshKey {
+			continue
+		}
+
+		keys := []string{}
+		for _, key := range strings.Split(authr.Key, "\n") {
+			key = strings.TrimSpace(key)
+			if key != "" {
+				keys = append(keys, key)
+			}
+		}
+
+		for i, key := range keys {
+			name := strings.Replace(authr.Name, " ", "_", -1)
+			if len(keys) > 1 {
+				name = fmt.Sprintf("%s_%d", name, i)
+			}
+
+			data.PublicKeys = append(data.PublicKeys, &publicKey{
+				Name: name,
+				Key:  key,
+			})
+		}
+	}
+
+	return
+}
+
+func getUserData(db *database.Database, instId primitive.ObjectID,
+	virt *vm.VirtualMachine) (usrData string, err error) {
+
+	inst, err := instance.Get(db, instId)
+	if err != nil {
+		return
+	}
+
+	usrData, err = cloudinit.GetUserData(db, inst, virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
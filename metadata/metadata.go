@@ -0,0 +1,81 @@
+package metadata
+
+import (
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+var (
+	servers     = map[primitive.ObjectID]*Server{}
+	serversLock = sync.Mutex{}
+)
+
+func start(virt *vm.VirtualMachine) (err error) {
+	srv := &Server{
+		Instance:  virt.Id,
+		Namespace: vm.GetNamespace(virt.Id, 0),
+		virt:      virt,
+	}
+
+	err = srv.Start()
+	if err != nil {
+		return
+	}
+
+	servers[virt.Id] = srv
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":   virt.Id.Hex(),
+		"net_namespace": srv.Namespace,
+	}).Info("metadata: Started instance metadata server")
+
+	return
+}
+
+func Start(virt *vm.VirtualMachine) (err error) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	srv := servers[virt.Id]
+	if srv != nil {
+		srv.Close()
+		delete(servers, virt.Id)
+	}
+
+	err = start(virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Ensure(virt *vm.VirtualMachine) (err error) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	if servers[virt.Id] != nil {
+		return
+	}
+
+	err = start(virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Stop(instId primitive.ObjectID) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	srv := servers[instId]
+	if srv != nil {
+		srv.Close()
+		delete(servers, instId)
+	}
+}
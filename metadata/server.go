@@ -0,0 +1,272 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+const metaDataIndex = `instance-id
+hostname
+local-hostname
+local-ipv4
+local-ipv6
+public-ipv4
+public-ipv6
+network-roles
+public-keys/
+credentials`
+
+type Server struct {
+	Instance  primitive.ObjectID
+	Namespace string
+	virt      *vm.VirtualMachine
+	listener  net.Listener
+	server    *http.Server
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": s.Instance.Hex(),
+		"error":       err,
+	}).Error("metadata: Failed to handle metadata request")
+
+	utils.WriteStatus(w, 500)
+}
+
+func (s *Server) writeJson(w http.ResponseWriter, data interface{}) {
+	output, err := json.Marshal(data)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(200)
+	_, _ = w.Write(output)
+}
+
+func (s *Server) serveMetaData(w http.ResponseWriter, pth string) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	data, inst, err := getMetaData(db, s.Instance)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			utils.WriteStatus(w, 404)
+			return
+		}
+		s.writeError(w, err)
+		return
+	}
+
+	switch pth {
+	case "":
+		utils.WriteText(w, 200, metaDataIndex)
+		return
+	case "instance-id":
+		utils.WriteText(w, 200, data.Id)
+		return
+	case "hostname", "local-hostname":
+		utils.WriteText(w, 200, data.Hostname)
+		return
+	case "local-ipv4":
+		utils.WriteText(w, 200, strings.Join(data.PrivateIps, "\n"))
+		return
+	case "local-ipv6":
+		utils.WriteText(w, 200, strings.Join(data.PrivateIps6, "\n"))
+		return
+	case "public-ipv4":
+		utils.WriteText(w, 200, strings.Join(data.PublicIps, "\n"))
+		return
+	case "public-ipv6":
+		utils.WriteText(w, 200, strings.Join(data.PublicIps6, "\n"))
+		return
+	case "network-roles":
+		utils.WriteText(w, 200, strings.Join(data.NetworkRoles, "\n"))
+		return
+	case "public-keys":
+		keys := []string{}
+		for i, key := range data.PublicKeys {
+			keys = append(keys, fmt.Sprintf("%d=%s", i, key.Name))
+		}
+		utils.WriteText(w, 200, strings.Join(keys, "\n"))
+		return
+	case "credentials":
+		tkn, e := NewToken(db, inst.Id, inst.Organization)
+		if e != nil {
+			s.writeError(w, e)
+			return
+		}
+
+		s.writeJson(w, tkn)
+		return
+	}
+
+	if strings.HasPrefix(pth, "public-keys/") {
+		keyPth := strings.Split(strings.TrimPrefix(
+			pth, "public-keys/"), "/")
+
+		index, e := strconv.Atoi(keyPth[0])
+		if e != nil || index < 0 || index >= len(data.PublicKeys) {
+			utils.WriteStatus(w, 404)
+			return
+		}
+
+		if len(keyPth) < 2 || keyPth[1] == "" {
+			utils.WriteText(w, 200, "openssh-key")
+			return
+		}
+
+		if keyPth[1] != "openssh-key" {
+			utils.WriteStatus(w, 404)
+			return
+		}
+
+		utils.WriteText(w, 200, data.PublicKeys[index].Key)
+		return
+	}
+
+	utils.WriteStatus(w, 404)
+}
+
+func (s *Server) serveUserData(w http.ResponseWriter) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	usrData, err := getUserData(db, s.Instance, s.virt)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			utils.WriteStatus(w, 404)
+			return
+		}
+		s.writeError(w, err)
+		return
+	}
+
+	utils.WriteText(w, 200, usrData)
+}
+
+func (s *Server) serveOpenstack(w http.ResponseWriter) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	data, _, err := getMetaData(db, s.Instance)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			utils.WriteStatus(w, 404)
+			return
+		}
+		s.writeError(w, err)
+		return
+	}
+
+	s.writeJson(w, data.Openstack())
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		utils.WriteStatus(w, 405)
+		return
+	}
+
+	pth := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch pth {
+	case "":
+		utils.WriteText(w, 200, "latest")
+		return
+	case "/latest":
+		utils.WriteText(w, 200, "meta-data/\nuser-data")
+		return
+	case "/latest/user-data", "/openstack/latest/user_data":
+		s.serveUserData(w)
+		return
+	case "/openstack":
+		utils.WriteText(w, 200, "latest")
+		return
+	case "/openstack/latest":
+		utils.WriteText(w, 200, "meta_data.json\nuser_data")
+		return
+	case "/openstack/latest/meta_data.json":
+		s.serveOpenstack(w)
+		return
+	case "/latest/meta-data":
+		s.serveMetaData(w, "")
+		return
+	}
+
+	if strings.HasPrefix(pth, "/latest/meta-data/") {
+		s.serveMetaData(w, strings.TrimPrefix(pth, "/latest/meta-data/"))
+		return
+	}
+
+	utils.WriteStatus(w, 404)
+}
+
+func (s *Server) Start() (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", s.Namespace,
+		"ip", "addr",
+		"add", Address+"/32",
+		"dev", "lo",
+	)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExecNamespace(s.Namespace, func() (e error) {
+		s.listener, e = net.Listen("tcp",
+			fmt.Sprintf("%s:%d", Address, Port))
+		if e != nil {
+			e = &errortypes.NetworkError{
+				errors.Wrap(e, "metadata: Failed to listen"),
+			}
+			return
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	s.server = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       1 * time.Minute,
+		MaxHeaderBytes:    4096,
+	}
+
+	go func() {
+		e := s.server.Serve(s.listener)
+		if e != nil && e != http.ErrServerClosed {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": s.Instance.Hex(),
+				"error":       e,
+			}).Error("metadata: Metadata server error")
+		}
+	}()
+
+	return
+}
+
+func (s *Server) Close() {
+	if s.server != nil {
+		_ = s.server.Close()
+	}
+}
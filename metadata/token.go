@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type Token struct {
+	Id           string             `bson:"_id" json:"token"`
+	Instance     primitive.ObjectID `bson:"instance" json:"instance"`
+	Organization primitive.ObjectID `bson:"organization" json:"organization"`
+	Timestamp    time.Time          `bson:"timestamp" json:"-"`
+	Expiration   time.Time          `bson:"-" json:"expiration"`
+}
+
+func NewToken(db *database.Database, instId, orgId primitive.ObjectID) (
+	tkn *Token, err error) {
+
+	coll := db.MetadataTokens()
+
+	tknId, err := utils.RandStr(48)
+	if err != nil {
+		return
+	}
+
+	tkn = &Token{
+		Id:           tknId,
+		Instance:     instId,
+		Organization: orgId,
+		Timestamp:    time.Now(),
+	}
+	tkn.Expiration = tkn.Timestamp.Add(tokenTtl)
+
+	_, err = coll.InsertOne(db, tkn)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetToken(db *database.Database, tknId string) (
+	tkn *Token, err error) {
+
+	coll := db.MetadataTokens()
+	tkn = &Token{}
+
+	err = coll.FindOneId(tknId, tkn)
+	if err != nil {
+		tkn = nil
+		return
+	}
+
+	if time.Since(tkn.Timestamp) > tokenTtl {
+		tkn = nil
+		err = &errortypes.AuthenticationError{
+			errors.New("metadata: Token expired"),
+		}
+		return
+	}
+	tkn.Expiration = tkn.Timestamp.Add(tokenTtl)
+
+	return
+}
@@ -0,0 +1,14 @@
+package metric
+
+import (
+	"time"
+)
+
+const (
+	Minute = "minute"
+	Hour   = "hour"
+
+	SampleInterval = 60 * time.Second
+	clockTicks     = 100
+	maxPoints      = 2000
+)
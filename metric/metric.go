@@ -0,0 +1,98 @@
+package metric
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+)
+
+type Metric struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Instance     primitive.ObjectID `bson:"instance" json:"instance"`
+	Node         primitive.ObjectID `bson:"node" json:"node"`
+	Organization primitive.ObjectID `bson:"organization" json:"organization"`
+	Timestamp    time.Time          `bson:"timestamp" json:"timestamp"`
+	Count        int                `bson:"count" json:"-"`
+	Cpu          float64            `bson:"cpu" json:"cpu"`
+	Memory       float64            `bson:"memory" json:"memory"`
+	DiskRead     float64            `bson:"disk_read" json:"disk_read"`
+	DiskWrite    float64            `bson:"disk_write" json:"disk_write"`
+	DiskReadOps  float64            `bson:"disk_read_ops" json:"disk_read_ops"`
+	DiskWriteOps float64            `bson:"disk_write_ops" json:"disk_write_ops"`
+	NetRx        float64            `bson:"net_rx" json:"net_rx"`
+	NetTx        float64            `bson:"net_tx" json:"net_tx"`
+	NetRxPackets float64            `bson:"net_rx_packets" json:"net_rx_packets"`
+	NetTxPackets float64            `bson:"net_tx_packets" json:"net_tx_packets"`
+}
+
+func (m *Metric) average() {
+	if m.Count <= 1 {
+		return
+	}
+
+	count := float64(m.Count)
+	m.Cpu /= count
+	m.Memory /= count
+	m.DiskRead /= count
+	m.DiskWrite /= count
+	m.DiskReadOps /= count
+	m.DiskWriteOps /= count
+	m.NetRx /= count
+	m.NetTx /= count
+	m.NetRxPackets /= count
+	m.NetTxPackets /= count
+}
+
+func (m *Metric) Insert(db *database.Database) (err error) {
+	coll := db.InstanceMetrics()
+
+	m.Count = 1
+
+	_, err = coll.InsertOne(db, m)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	// Hourly buckets store sums and are averaged when read
+	coll = db.InstanceMetricsHourly()
+
+	opts := &options.UpdateOptions{}
+	opts.SetUpsert(true)
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"instance":  m.Instance,
+			"timestamp": m.Timestamp.Truncate(time.Hour),
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"node":         m.Node,
+				"organization": m.Organization,
+			},
+			"$inc": &bson.M{
+				"count":          1,
+				"cpu":            m.Cpu,
+				"memory":         m.Memory,
+				"disk_read":      m.DiskRead,
+				"disk_write":     m.DiskWrite,
+				"disk_read_ops":  m.DiskReadOps,
+				"disk_write_ops": m.DiskWriteOps,
+				"net_rx":         m.NetRx,
+				"net_tx":         m.NetTx,
+				"net_rx_packets": m.NetRxPackets,
+				"net_tx_packets": m.NetTxPackets,
+			},
+		},
+		opts,
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
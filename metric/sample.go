@@ -0,0 +1,262 @@
+package metric
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+var (
+	samples     = map[primitive.ObjectID]*sample{}
+	samplesLock = sync.Mutex{}
+)
+
+type sample struct {
+	Timestamp    time.Time
+	Pid          int
+	CpuTicks     int64
+	Memory       int64
+	DiskRead     int64
+	DiskWrite    int64
+	DiskReadOps  int64
+	DiskWriteOps int64
+	NetRx        int64
+	NetTx        int64
+	NetRxPackets int64
+	NetTxPackets int64
+}
+
+func readPid(instId primitive.ObjectID) (pid int, err error) {
+	data, err := ioutil.ReadFile(paths.GetPidPath(instId))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "metric: Failed to read pid file"),
+		}
+		return
+	}
+
+	pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "metric: Failed to parse pid file"),
+		}
+		return
+	}
+
+	return
+}
+
+func readProc(smpl *sample) (err error) {
+	procPath := "/proc/" + strconv.Itoa(smpl.Pid)
+
+	data, err := ioutil.ReadFile(procPath + "/stat")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "metric: Failed to read process stat"),
+		}
+		return
+	}
+
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 13 {
+		err = &errortypes.ParseError{
+			errors.New("metric: Invalid process stat"),
+		}
+		return
+	}
+
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	smpl.CpuTicks = utime + stime
+
+	data, err = ioutil.ReadFile(procPath + "/status")
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "metric: Failed to read process status"),
+		}
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+
+		lineSpl := strings.Fields(line)
+		if len(lineSpl) < 2 {
+			break
+		}
+
+		rss, _ := strconv.ParseInt(lineSpl[1], 10, 64)
+		smpl.Memory = rss / 1024
+		break
+	}
+
+	return
+}
+
+func readNetwork(instId primitive.ObjectID, n int, smpl *sample) (
+	err error) {
+
+	// Counters are read from the host side of the tap interface, received
+	// and transmitted are swapped to match the instance view
+	iface := vm.GetIface(instId, n)
+
+	output, err := utils.ExecOutput("",
+		"ip", "netns", "exec", vm.GetNamespace(instId, n),
+		"cat", "/proc/net/dev")
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		lineSpl := strings.SplitN(line, ":", 2)
+		if len(lineSpl) != 2 || strings.TrimSpace(lineSpl[0]) != iface {
+			continue
+		}
+
+		fields := strings.Fields(lineSpl[1])
+		if len(fields) < 10 {
+			continue
+		}
+
+		rxBytes, _ := strconv.ParseInt(fields[0], 10, 64)
+		rxPackets, _ := strconv.ParseInt(fields[1], 10, 64)
+		txBytes, _ := strconv.ParseInt(fields[8], 10, 64)
+		txPackets, _ := strconv.ParseInt(fields[9], 10, 64)
+
+		smpl.NetRx += txBytes
+		smpl.NetRxPackets += txPackets
+		smpl.NetTx += rxBytes
+		smpl.NetTxPackets += rxPackets
+	}
+
+	return
+}
+
+func collect(inst *instance.Instance) (smpl *sample, err error) {
+	smpl = &sample{
+		Timestamp: time.Now(),
+	}
+
+	smpl.Pid, err = readPid(inst.Id)
+	if err != nil {
+		return
+	}
+
+	err = readProc(smpl)
+	if err != nil {
+		return
+	}
+
+	diskStats, err := qms.GetDiskStats(inst.Id)
+	if err != nil {
+		return
+	}
+
+	smpl.DiskRead = diskStats.ReadBytes
+	smpl.DiskWrite = diskStats.WriteBytes
+	smpl.DiskReadOps = diskStats.ReadOps
+	smpl.DiskWriteOps = diskStats.WriteOps
+
+	for i := 0; i <= len(inst.Adapters); i++ {
+		err = readNetwork(inst.Id, i, smpl)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func rate(cur, prev int64, seconds float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return utils.ToFixed(float64(cur-prev)/seconds, 2)
+}
+
+func Collect(db *database.Database) (err error) {
+	insts, err := instance.GetAll(db, &bson.M{
+		"node":     node.Self.Id,
+		"vm_state": vm.Running,
+	})
+	if err != nil {
+		return
+	}
+
+	samplesLock.Lock()
+	defer samplesLock.Unlock()
+
+	curSamples := map[primitive.ObjectID]*sample{}
+
+	for _, inst := range insts {
+		smpl, e := collect(inst)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"error":       e,
+			}).Warn("metric: Failed to collect instance metrics")
+			continue
+		}
+		curSamples[inst.Id] = smpl
+
+		prev := samples[inst.Id]
+		if prev == nil || prev.Pid != smpl.Pid {
+			continue
+		}
+
+		seconds := smpl.Timestamp.Sub(prev.Timestamp).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+
+		processors := inst.Processors
+		if processors < 1 {
+			processors = 1
+		}
+
+		m := &Metric{
+			Instance:     inst.Id,
+			Node:         node.Self.Id,
+			Organization: inst.Organization,
+			Timestamp:    smpl.Timestamp,
+			Cpu: utils.ToFixed(rate(smpl.CpuTicks, prev.CpuTicks,
+				seconds)/clockTicks/float64(processors)*100, 2),
+			Memory:       float64(smpl.Memory),
+			DiskRead:     rate(smpl.DiskRead, prev.DiskRead, seconds),
+			DiskWrite:    rate(smpl.DiskWrite, prev.DiskWrite, seconds),
+			DiskReadOps:  rate(smpl.DiskReadOps, prev.DiskReadOps, seconds),
+			DiskWriteOps: rate(smpl.DiskWriteOps, prev.DiskWriteOps, seconds),
+			NetRx:        rate(smpl.NetRx, prev.NetRx, seconds),
+			NetTx:        rate(smpl.NetTx, prev.NetTx, seconds),
+			NetRxPackets: rate(smpl.NetRxPackets, prev.NetRxPackets, seconds),
+			NetTxPackets: rate(smpl.NetTxPackets, prev.NetTxPackets, seconds),
+		}
+
+		err = m.Insert(db)
+		if err != nil {
+			return
+		}
+	}
+
+	samples = curSamples
+
+	return
+}
@@ -0,0 +1,91 @@
+package metric
+
+import (
+	"time"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+)
+
+func getRange(db *database.Database, query *bson.M, resolution string,
+	start, end time.Time) (metrics []*Metric, err error) {
+
+	var coll *database.Collection
+	if resolution == Hour {
+		coll = db.InstanceMetricsHourly()
+	} else {
+		coll = db.InstanceMetrics()
+	}
+	metrics = []*Metric{}
+	limit := int64(maxPoints)
+
+	(*query)["timestamp"] = &bson.M{
+		"$gte": start,
+		"$lte": end,
+	}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"timestamp", 1},
+			},
+			Limit: &limit,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		m := &Metric{}
+		err = cursor.Decode(m)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		m.average()
+		metrics = append(metrics, m)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetRange(db *database.Database, instId primitive.ObjectID,
+	resolution string, start, end time.Time) (metrics []*Metric, err error) {
+
+	metrics, err = getRange(db, &bson.M{
+		"instance": instId,
+	}, resolution, start, end)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetRangeOrg(db *database.Database, orgId, instId primitive.ObjectID,
+	resolution string, start, end time.Time) (metrics []*Metric, err error) {
+
+	metrics, err = getRange(db, &bson.M{
+		"instance":     instId,
+		"organization": orgId,
+	}, resolution, start, end)
+	if err != nil {
+		return
+	}
+
+	return
+}
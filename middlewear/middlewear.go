@@ -3,6 +3,8 @@ package middlewear
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/dropbox/godropbox/errors"
@@ -13,9 +15,11 @@ import (
 	"github.com/pritunl/pritunl-cloud/csrf"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/metadata"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/session"
+	"github.com/pritunl/pritunl-cloud/telemetry"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/validator"
 )
@@ -30,6 +34,11 @@ func Limiter(c *gin.Context) {
 
 func Counter(c *gin.Context) {
 	node.Self.AddRequest()
+
+	start := time.Now()
+	c.Next()
+	telemetry.ObserveRequest(c.Request.Method, c.Writer.Status(),
+		time.Since(start))
 }
 
 func Database(c *gin.Context) {
@@ -267,7 +276,12 @@ func UserOrg(c *gin.Context) {
 		return
 	}
 
-	orgIdStr := c.GetHeader("Organization")
+	orgIdStr := ""
+	if c.Request.Header.Get("Upgrade") == "websocket" {
+		orgIdStr = c.Query("organization")
+	} else {
+		orgIdStr = c.GetHeader("Organization")
+	}
 	if orgIdStr == "" {
 		utils.AbortWithStatus(c, 401)
 		return
@@ -294,6 +308,31 @@ func UserOrg(c *gin.Context) {
 	c.Set("organization", org.Id)
 }
 
+func MetadataToken(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+
+	tknId := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tknId == "" {
+		utils.AbortWithStatus(c, 401)
+		return
+	}
+
+	tkn, err := metadata.GetToken(db, tknId)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError, *errortypes.AuthenticationError:
+			utils.AbortWithStatus(c, 401)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	c.Set("organization", tkn.Organization)
+	c.Set("instance", tkn.Instance)
+}
+
 func CsrfToken(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
@@ -2,6 +2,7 @@ package node
 
 import (
 	"container/list"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
@@ -21,8 +22,11 @@ import (
 	"github.com/pritunl/pritunl-cloud/certificate"
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/elasticip"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/telemetry"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -65,6 +69,7 @@ type Node struct {
 	HostNatExcludes      []string                   `bson:"host_nat_excludes" json:"host_nat_excludes"`
 	JumboFrames          bool                       `bson:"jumbo_frames" json:"jumbo_frames"`
 	UsbPassthrough       bool                       `bson:"usb_passthrough" json:"usb_passthrough"`
+	Maintenance          bool                       `bson:"maintenance" json:"maintenance"`
 	UsbDevices           []*usb.Device              `bson:"usb_devices" json:"usb_devices"`
 	Firewall             bool                       `bson:"firewall" json:"firewall"`
 	NetworkRoles         []string                   `bson:"network_roles" json:"network_roles"`
@@ -91,6 +96,7 @@ type Node struct {
 	CertificateObjs      []*certificate.Certificate `bson:"-" json:"-"`
 	reqLock              sync.Mutex                 `bson:"-" json:"-"`
 	reqCount             *list.List                 `bson:"-" json:"-"`
+	leaseTimestamp       time.Time                  `bson:"-" json:"-"`
 }
 
 func (n *Node) Copy() *Node {
@@ -127,6 +133,7 @@ func (n *Node) Copy() *Node {
 		HostNat:              n.HostNat,
 		HostNatExcludes:      n.HostNatExcludes,
 		JumboFrames:          n.JumboFrames,
+		Maintenance:          n.Maintenance,
 		Firewall:             n.Firewall,
 		NetworkRoles:         n.NetworkRoles,
 		Memory:               n.Memory,
@@ -178,6 +185,50 @@ func (n *Node) GetCachePath() string {
 	return n.CachePath
 }
 
+func (n *Node) GetInternalAddr() string {
+	if n.PrivateIps != nil {
+		if n.InternalInterface != "" {
+			addr := n.PrivateIps[n.InternalInterface]
+			if addr != "" {
+				return addr
+			}
+		}
+
+		for _, addr := range n.PrivateIps {
+			if addr != "" {
+				return addr
+			}
+		}
+	}
+
+	if len(n.PublicIps) > 0 {
+		return n.PublicIps[0]
+	}
+
+	return ""
+}
+
+func (n *Node) GetWebUrl(scheme string) string {
+	addr := n.GetInternalAddr()
+	if addr == "" {
+		return ""
+	}
+
+	if strings.Contains(addr, ":") {
+		addr = "[" + addr + "]"
+	}
+
+	port := n.Port
+	if port == 0 {
+		port = 443
+	}
+
+	if n.Protocol == "http" {
+		return fmt.Sprintf("%s://%s:%d", scheme, addr, port)
+	}
+	return fmt.Sprintf("%ss://%s:%d", scheme, addr, port)
+}
+
 func (n *Node) IsAdmin() bool {
 	for _, typ := range n.Types {
 		if typ == Admin {
@@ -375,8 +426,12 @@ func (n *Node) Format() {
 	utils.SortObjectIds(n.Certificates)
 }
 
+func (n *Node) IsOnline() bool {
+	return time.Since(n.Timestamp) <= 30*time.Second
+}
+
 func (n *Node) SetActive() {
-	if time.Since(n.Timestamp) > 30*time.Second {
+	if !n.IsOnline() {
 		n.RequestsMin = 0
 		n.Memory = 0
 		n.Load1 = 0
@@ -417,6 +472,39 @@ func (n *Node) GetStaticAddr(db *database.Database,
 	instId primitive.ObjectID) (blck *block.Block, ip net.IP, iface string,
 	err error) {
 
+	eip, err := elasticip.GetInstance(db, instId)
+	if err != nil {
+		return
+	}
+
+	if eip != nil {
+		eipBlck, eipBlckIp, e := block.GetInstanceIp(
+			db, eip.Id, block.Elastic)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if eipBlckIp != nil {
+			for _, blckAttch := range n.Blocks {
+				if blckAttch.Block != eipBlck.Id {
+					continue
+				}
+
+				err = block.RemoveInstanceIpsType(
+					db, instId, block.External)
+				if err != nil {
+					return
+				}
+
+				blck = eipBlck
+				ip = eipBlckIp.GetIp()
+				iface = blckAttch.Interface
+				return
+			}
+		}
+	}
+
 	blck, blckIp, err := block.GetInstanceIp(db, instId, block.External)
 	if err != nil {
 		return
@@ -525,6 +613,17 @@ func (n *Node) GetRemoteAddr(r *http.Request) (addr string) {
 	return
 }
 
+// Lease is renewed by each successful node update, instances on a node
+// with an expired lease may be failed over to other nodes
+func (n *Node) LeaseExpired() bool {
+	if n.leaseTimestamp.IsZero() {
+		return false
+	}
+
+	return time.Since(n.leaseTimestamp) > time.Duration(
+		settings.Hypervisor.HaFenceTimeout)*time.Second
+}
+
 func (n *Node) update(db *database.Database) (err error) {
 	coll := db.Nodes()
 
@@ -593,6 +692,7 @@ func (n *Node) update(db *database.Database) (err error) {
 	n.HostNatExcludes = nde.HostNatExcludes
 	n.JumboFrames = nde.JumboFrames
 	n.UsbPassthrough = nde.UsbPassthrough
+	n.Maintenance = nde.Maintenance
 	n.Firewall = nde.Firewall
 	n.NetworkRoles = nde.NetworkRoles
 	n.VirtPath = nde.VirtPath
@@ -775,8 +875,13 @@ func (n *Node) sync() {
 		logrus.WithFields(logrus.Fields{
 			"error": err,
 		}).Error("node: Failed to update node")
+	} else {
+		n.leaseTimestamp = n.Timestamp
 	}
 
+	telemetry.SetNode(n.Load1, n.Load5, n.Load15, n.Memory, n.CpuUnits,
+		n.MemoryUnits, n.CpuUnitsRes, n.MemoryUnitsRes)
+
 	err = n.loadCerts(db)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
@@ -0,0 +1,291 @@
+package organization
+
+import (
+	"fmt"
+
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type Quota struct {
+	Instances     int `bson:"instances" json:"instances"`
+	Processors    int `bson:"processors" json:"processors"`
+	Memory        int `bson:"memory" json:"memory"`
+	Disk          int `bson:"disk" json:"disk"`
+	Vpcs          int `bson:"vpcs" json:"vpcs"`
+	PublicIps     int `bson:"public_ips" json:"public_ips"`
+	BackupStorage int `bson:"backup_storage" json:"backup_storage"`
+}
+
+func (q *Quota) IsZero() bool {
+	return q.Instances == 0 && q.Processors == 0 && q.Memory == 0 &&
+		q.Disk == 0 && q.Vpcs == 0 && q.PublicIps == 0 &&
+		q.BackupStorage == 0
+}
+
+type Usage struct {
+	Instances     int `json:"instances"`
+	Processors    int `json:"processors"`
+	Memory        int `json:"memory"`
+	Disk          int `json:"disk"`
+	Vpcs          int `json:"vpcs"`
+	PublicIps     int `json:"public_ips"`
+	BackupStorage int `json:"backup_storage"`
+}
+
+type instanceUsage struct {
+	Instances  int `bson:"instances"`
+	Processors int `bson:"processors"`
+	Memory     int `bson:"memory"`
+	PublicIps  int `bson:"public_ips"`
+}
+
+type diskUsage struct {
+	Disk          int `bson:"disk"`
+	BackupStorage int `bson:"backup_storage"`
+}
+
+func GetUsage(db *database.Database, orgId primitive.ObjectID) (
+	usage *Usage, err error) {
+
+	usage = &Usage{}
+
+	coll := db.Instances()
+	cursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"instances": &bson.M{
+					"$sum": 1,
+				},
+				"processors": &bson.M{
+					"$sum": "$processors",
+				},
+				"memory": &bson.M{
+					"$sum": "$memory",
+				},
+				"public_ips": &bson.M{
+					"$sum": &bson.M{
+						"$cond": []interface{}{
+							"$no_public_address", 0, 1,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		doc := &instanceUsage{}
+		err = cursor.Decode(doc)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		usage.Instances = doc.Instances
+		usage.Processors = doc.Processors
+		usage.Memory = doc.Memory
+		usage.PublicIps = doc.PublicIps
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.Disks()
+	cursor, err = coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$match": &bson.M{
+				"organization": orgId,
+			},
+		},
+		&bson.M{
+			"$group": &bson.M{
+				"_id": nil,
+				"disk": &bson.M{
+					"$sum": "$size",
+				},
+				"backup_storage": &bson.M{
+					"$sum": &bson.M{
+						"$cond": []interface{}{
+							"$backup", "$size", 0,
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		doc := &diskUsage{}
+		err = cursor.Decode(doc)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		usage.Disk = doc.Disk
+		usage.BackupStorage = doc.BackupStorage
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	coll = db.Vpcs()
+	count, err := coll.CountDocuments(db, &bson.M{
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	usage.Vpcs = int(count)
+
+	// Attached elastic ips replace the instance public address
+	coll = db.ElasticIps()
+	count, err = coll.CountDocuments(db, &bson.M{
+		"organization": orgId,
+		"instance": &bson.M{
+			"$exists": false,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	usage.PublicIps += int(count)
+
+	return
+}
+
+func CheckQuota(db *database.Database, orgId primitive.ObjectID,
+	req *Usage) (errData *errortypes.ErrorData, err error) {
+
+	if orgId.IsZero() {
+		return
+	}
+
+	org, err := Get(db, orgId)
+	if err != nil {
+		return
+	}
+
+	quota := org.Quota
+	if quota == nil || quota.IsZero() {
+		return
+	}
+
+	usage, err := GetUsage(db, orgId)
+	if err != nil {
+		return
+	}
+
+	if quota.Instances > 0 && req.Instances > 0 &&
+		usage.Instances+req.Instances > quota.Instances {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_instances",
+			Message: fmt.Sprintf(
+				"Organization instance quota of %d exceeded",
+				quota.Instances),
+		}
+		return
+	}
+
+	if quota.Processors > 0 && req.Processors > 0 &&
+		usage.Processors+req.Processors > quota.Processors {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_processors",
+			Message: fmt.Sprintf(
+				"Organization processor quota of %d exceeded",
+				quota.Processors),
+		}
+		return
+	}
+
+	if quota.Memory > 0 && req.Memory > 0 &&
+		usage.Memory+req.Memory > quota.Memory {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_memory",
+			Message: fmt.Sprintf(
+				"Organization memory quota of %dMB exceeded",
+				quota.Memory),
+		}
+		return
+	}
+
+	if quota.Disk > 0 && req.Disk > 0 &&
+		usage.Disk+req.Disk > quota.Disk {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_disk",
+			Message: fmt.Sprintf(
+				"Organization disk quota of %dGB exceeded",
+				quota.Disk),
+		}
+		return
+	}
+
+	if quota.Vpcs > 0 && req.Vpcs > 0 &&
+		usage.Vpcs+req.Vpcs > quota.Vpcs {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_vpcs",
+			Message: fmt.Sprintf(
+				"Organization VPC quota of %d exceeded",
+				quota.Vpcs),
+		}
+		return
+	}
+
+	if quota.PublicIps > 0 && req.PublicIps > 0 &&
+		usage.PublicIps+req.PublicIps > quota.PublicIps {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_public_ips",
+			Message: fmt.Sprintf(
+				"Organization public IP quota of %d exceeded",
+				quota.PublicIps),
+		}
+		return
+	}
+
+	if quota.BackupStorage > 0 && req.BackupStorage > 0 &&
+		usage.BackupStorage+req.BackupStorage > quota.BackupStorage {
+
+		errData = &errortypes.ErrorData{
+			Error: "quota_backup_storage",
+			Message: fmt.Sprintf(
+				"Organization backup storage quota of %dGB exceeded",
+				quota.BackupStorage),
+		}
+		return
+	}
+
+	return
+}
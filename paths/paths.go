@@ -22,6 +22,11 @@ func GetBackingPath() string {
 	return path.Join(node.Self.GetVirtPath(), "backing")
 }
 
+func GetBackingImagePath(backingImage string) string {
+	return path.Join(GetBackingPath(),
+		fmt.Sprintf("image-%s", backingImage))
+}
+
 func GetTempPath() string {
 	return path.Join(node.Self.GetVirtPath(), "temp")
 }
@@ -89,6 +94,51 @@ func GetSockPath(virtId primitive.ObjectID) string {
 		fmt.Sprintf("%s.sock", virtId.Hex()))
 }
 
+func GetQmpSockPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.qmp.sock", virtId.Hex()))
+}
+
+func GetOvmfVarsPath(virtId primitive.ObjectID) string {
+	return path.Join(GetVmPath(virtId), "ovmf_vars.fd")
+}
+
+func GetMigrateTlsPath(virtId primitive.ObjectID) string {
+	return path.Join(GetVmPath(virtId), "migrate_tls")
+}
+
+func GetTpmPath(virtId primitive.ObjectID) string {
+	return path.Join(GetVmPath(virtId), "tpm")
+}
+
+func GetTpmSockPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.tpm.sock", virtId.Hex()))
+}
+
+func GetTpmUnitName(virtId primitive.ObjectID) string {
+	return fmt.Sprintf("pritunl_swtpm_%s.service", virtId.Hex())
+}
+
+func GetTpmUnitPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.SystemdPath,
+		GetTpmUnitName(virtId))
+}
+
+func GetVncSockPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.vnc.sock", virtId.Hex()))
+}
+
+func GetSerialSockPath(virtId primitive.ObjectID) string {
+	return path.Join(settings.Hypervisor.LibPath,
+		fmt.Sprintf("%s.serial.sock", virtId.Hex()))
+}
+
+func GetSerialLogPath(virtId primitive.ObjectID) string {
+	return path.Join(GetVmPath(virtId), "serial.log")
+}
+
 func GetGuestPath(virtId primitive.ObjectID) string {
 	return path.Join(settings.Hypervisor.LibPath,
 		fmt.Sprintf("%s.guest", virtId.Hex()))
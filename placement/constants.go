@@ -0,0 +1,17 @@
+package placement
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+)
+
+const (
+	Spread = "spread"
+	Pack   = "pack"
+)
+
+var (
+	ValidPolicies = set.NewSet(
+		Spread,
+		Pack,
+	)
+)
@@ -0,0 +1,189 @@
+package placement
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type Group struct {
+	Id           primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	Name         string               `bson:"name" json:"name"`
+	Organization primitive.ObjectID   `bson:"organization,omitempty" json:"organization"`
+	Policy       string               `bson:"policy" json:"policy"`
+	Violations   []primitive.ObjectID `bson:"-" json:"violations"`
+}
+
+func (g *Group) Validate(db *database.Database) (
+	errData *errortypes.ErrorData, err error) {
+
+	if g.Organization.IsZero() {
+		errData = &errortypes.ErrorData{
+			Error:   "organization_required",
+			Message: "Missing required organization",
+		}
+		return
+	}
+
+	if g.Policy == "" {
+		g.Policy = Spread
+	}
+
+	if !ValidPolicies.Contains(g.Policy) {
+		errData = &errortypes.ErrorData{
+			Error:   "policy_invalid",
+			Message: "Placement group policy invalid",
+		}
+		return
+	}
+
+	return
+}
+
+func (g *Group) Check(db *database.Database, instId,
+	ndeId primitive.ObjectID) (errData *errortypes.ErrorData, err error) {
+
+	members, err := getMembers(db, g.Id, instId)
+	if err != nil {
+		return
+	}
+
+	switch g.Policy {
+	case Spread:
+		for _, member := range members {
+			if member.Node == ndeId || member.MigrateNode == ndeId {
+				errData = &errortypes.ErrorData{
+					Error: "placement_spread_violation",
+					Message: "Node already has an instance from " +
+						"spread placement group",
+				}
+				return
+			}
+		}
+		break
+	case Pack:
+		for _, member := range members {
+			if member.Node != ndeId {
+				errData = &errortypes.ErrorData{
+					Error: "placement_pack_violation",
+					Message: "Node does not have the instances from " +
+						"pack placement group",
+				}
+				return
+			}
+		}
+		break
+	}
+
+	return
+}
+
+func (g *Group) GetNodes(db *database.Database, instId primitive.ObjectID) (
+	ndeIds set.Set, err error) {
+
+	ndeIds = set.NewSet()
+
+	members, err := getMembers(db, g.Id, instId)
+	if err != nil {
+		return
+	}
+
+	for _, member := range members {
+		ndeIds.Add(member.Node)
+		if !member.MigrateNode.IsZero() {
+			ndeIds.Add(member.MigrateNode)
+		}
+	}
+
+	return
+}
+
+func (g *Group) GetViolations(db *database.Database) (
+	instIds []primitive.ObjectID, err error) {
+
+	instIds = []primitive.ObjectID{}
+
+	members, err := getMembers(db, g.Id, primitive.NilObjectID)
+	if err != nil {
+		return
+	}
+
+	nodeCount := map[primitive.ObjectID]int{}
+	for _, member := range members {
+		nodeCount[member.Node] += 1
+	}
+
+	switch g.Policy {
+	case Spread:
+		for _, member := range members {
+			if nodeCount[member.Node] > 1 {
+				instIds = append(instIds, member.Id)
+			}
+		}
+		break
+	case Pack:
+		packNode := primitive.NilObjectID
+		packCount := 0
+		for _, member := range members {
+			count := nodeCount[member.Node]
+			if count > packCount {
+				packNode = member.Node
+				packCount = count
+			}
+		}
+
+		for _, member := range members {
+			if member.Node != packNode {
+				instIds = append(instIds, member.Id)
+			}
+		}
+		break
+	}
+
+	return
+}
+
+func (g *Group) Commit(db *database.Database) (err error) {
+	coll := db.PlacementGroups()
+
+	err = coll.Commit(g.Id, g)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) CommitFields(db *database.Database, fields set.Set) (
+	err error) {
+
+	coll := db.PlacementGroups()
+
+	err = coll.CommitFields(g.Id, g, fields)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (g *Group) Insert(db *database.Database) (err error) {
+	coll := db.PlacementGroups()
+
+	if !g.Id.IsZero() {
+		err = &errortypes.DatabaseError{
+			errors.New("placement: Placement group already exists"),
+		}
+		return
+	}
+
+	_, err = coll.InsertOne(db, g)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,321 @@
+package placement
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type member struct {
+	Id          primitive.ObjectID `bson:"_id"`
+	Node        primitive.ObjectID `bson:"node"`
+	MigrateNode primitive.ObjectID `bson:"migrate_node"`
+}
+
+func getMembers(db *database.Database, grpId, excludeId primitive.ObjectID) (
+	members []*member, err error) {
+
+	coll := db.Instances()
+	members = []*member{}
+
+	query := bson.M{
+		"placement_group": grpId,
+	}
+	if !excludeId.IsZero() {
+		query["_id"] = &bson.M{
+			"$ne": excludeId,
+		}
+	}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Projection: &bson.D{
+				{"node", 1},
+				{"migrate_node", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		mbr := &member{}
+		err = cursor.Decode(mbr)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		members = append(members, mbr)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func Get(db *database.Database, grpId primitive.ObjectID) (
+	grp *Group, err error) {
+
+	coll := db.PlacementGroups()
+	grp = &Group{}
+
+	err = coll.FindOneId(grpId, grp)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetOrg(db *database.Database, orgId, grpId primitive.ObjectID) (
+	grp *Group, err error) {
+
+	coll := db.PlacementGroups()
+	grp = &Group{}
+
+	err = coll.FindOne(db, &bson.M{
+		"_id":          grpId,
+		"organization": orgId,
+	}).Decode(grp)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAll(db *database.Database, query *bson.M) (
+	grps []*Group, err error) {
+
+	coll := db.PlacementGroups()
+	grps = []*Group{}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		grp := &Group{}
+		err = cursor.Decode(grp)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		grps = append(grps, grp)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetAllPaged(db *database.Database, query *bson.M,
+	page, pageCount int64) (grps []*Group, count int64, err error) {
+
+	coll := db.PlacementGroups()
+	grps = []*Group{}
+
+	count, err = coll.CountDocuments(db, query)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	page = utils.Min64(page, count/pageCount)
+	skip := utils.Min64(page*pageCount, count)
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"name", 1},
+			},
+			Skip:  &skip,
+			Limit: &pageCount,
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		grp := &Group{}
+		err = cursor.Decode(grp)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		grps = append(grps, grp)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func GetViolationsMap(db *database.Database, grpIds []primitive.ObjectID) (
+	violations map[primitive.ObjectID]bool, err error) {
+
+	violations = map[primitive.ObjectID]bool{}
+
+	if len(grpIds) == 0 {
+		return
+	}
+
+	grps, err := GetAll(db, &bson.M{
+		"_id": &bson.M{
+			"$in": grpIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	for _, grp := range grps {
+		instIds, e := grp.GetViolations(db)
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, instId := range instIds {
+			violations[instId] = true
+		}
+	}
+
+	return
+}
+
+func Remove(db *database.Database, grpId primitive.ObjectID) (err error) {
+	err = RemoveMulti(db, []primitive.ObjectID{grpId})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveOrg(db *database.Database, orgId, grpId primitive.ObjectID) (
+	err error) {
+
+	err = RemoveMultiOrg(db, orgId, []primitive.ObjectID{grpId})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveMulti(db *database.Database, grpIds []primitive.ObjectID) (
+	err error) {
+
+	coll := db.PlacementGroups()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": grpIds,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	err = clearInstances(db, &bson.M{
+		"placement_group": &bson.M{
+			"$in": grpIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func RemoveMultiOrg(db *database.Database, orgId primitive.ObjectID,
+	grpIds []primitive.ObjectID) (err error) {
+
+	coll := db.PlacementGroups()
+
+	_, err = coll.DeleteMany(db, &bson.M{
+		"_id": &bson.M{
+			"$in": grpIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	err = clearInstances(db, &bson.M{
+		"placement_group": &bson.M{
+			"$in": grpIds,
+		},
+		"organization": orgId,
+	})
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func clearInstances(db *database.Database, query *bson.M) (err error) {
+	coll := db.Instances()
+
+	_, err = coll.UpdateMany(db, query, &bson.M{
+		"$unset": &bson.M{
+			"placement_group": 1,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		switch err.(type) {
+		case *database.NotFoundError:
+			err = nil
+		default:
+			return
+		}
+	}
+
+	return
+}
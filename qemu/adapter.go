@@ -0,0 +1,315 @@
+package qemu
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/interfaces"
+	"github.com/pritunl/pritunl-cloud/iproute"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+func networkConfAdapter(db *database.Database, virt *vm.VirtualMachine,
+	index int, vxlan bool, updateMtuInternal, updateMtuInstance string) (
+	addr, addr6 net.IP, err error) {
+
+	adapter := virt.NetworkAdapters[index]
+	iface := vm.GetIface(virt.Id, index)
+	ifaceInternalVirt := vm.GetIfaceVirt(virt.Id, 2+index)
+	ifaceInternal := vm.GetIfaceInternal(virt.Id, index)
+	ifaceVlan := vm.GetIfaceVlan(virt.Id, index)
+	namespace := vm.GetNamespace(virt.Id, index)
+
+	vc, err := vpc.Get(db, adapter.Vpc)
+	if err != nil {
+		return
+	}
+
+	vcNet, err := vc.GetNetwork()
+	if err != nil {
+		return
+	}
+
+	addr, gatewayAddr, err := vc.GetInstanceIp(db, adapter.Subnet, virt.Id,
+		adapter.StaticIp)
+	if err != nil {
+		return
+	}
+
+	addr6 = vc.GetIp6(addr)
+	gatewayAddr6 := vc.GetIp6(gatewayAddr)
+
+	cidr, _ := vcNet.Mask.Size()
+	gatewayCidr := fmt.Sprintf("%s/%d", gatewayAddr.String(), cidr)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns",
+		"add", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, _ = utils.ExecCombinedOutput(
+		"", "ip", "link", "set", ifaceInternalVirt, "down")
+	_, _ = utils.ExecCombinedOutput(
+		"", "ip", "link", "del", ifaceInternalVirt)
+
+	interfaces.RemoveVirtIface(ifaceInternalVirt)
+
+	macAddrInternal := vm.GetMacAddrInternal(virt.Id, vc.Id)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"add", ifaceInternalVirt,
+		"type", "veth",
+		"peer", "name", ifaceInternal,
+		"addr", macAddrInternal,
+	)
+	if err != nil {
+		return
+	}
+
+	if updateMtuInternal != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", "dev", ifaceInternalVirt,
+			"mtu", updateMtuInternal,
+		)
+		if err != nil {
+			return
+		}
+
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "link",
+			"set", "dev", ifaceInternal,
+			"mtu", updateMtuInternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link",
+		"set", "dev", ifaceInternalVirt, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	internalIface := interfaces.GetInternal(ifaceInternalVirt, vxlan)
+	if internalIface == "" {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: Failed to get internal interface"),
+		}
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "set",
+		ifaceInternalVirt, "master", internalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", ifaceInternal,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"sysctl", "-w", "net.ipv6.conf.all.accept_ra=0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"sysctl", "-w", "net.ipv6.conf.default.accept_ra=0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "link",
+		"set", "dev", iface,
+		"netns", namespace,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", "lo", "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", ifaceInternal, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	if updateMtuInstance != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", iface,
+			"mtu", updateMtuInstance,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", iface, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"add", "link", ifaceInternal,
+		"name", ifaceVlan,
+		"type", "vlan",
+		"id", strconv.Itoa(vc.VpcId),
+	)
+	if err != nil {
+		return
+	}
+
+	if updateMtuInternal != "" {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"ip", "netns", "exec", namespace,
+			"ip", "link",
+			"set", "dev", ifaceVlan,
+			"mtu", updateMtuInternal,
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", ifaceVlan, "up",
+	)
+	if err != nil {
+		return
+	}
+
+	err = iproute.BridgeAdd(namespace, "br0")
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link", "set",
+		ifaceVlan, "master", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link", "set",
+		iface, "master", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", gatewayCidr,
+		"dev", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "-6", "addr",
+		"add", gatewayAddr6.String()+"/64",
+		"dev", "br0",
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "link",
+		"set", "dev", "br0", "up",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func networkConfAdapterClear(virt *vm.VirtualMachine, index int) {
+	ifaceInternalVirt := vm.GetIfaceVirt(virt.Id, 2+index)
+
+	_, _ = utils.ExecCombinedOutput(
+		"", "ip", "link", "set", ifaceInternalVirt, "down")
+	_, _ = utils.ExecCombinedOutput(
+		"", "ip", "link", "del", ifaceInternalVirt)
+
+	interfaces.RemoveVirtIface(ifaceInternalVirt)
+}
@@ -3,7 +3,7 @@ package qemu
 const systemdTemplate = `[Unit]
 Description=Pritunl Cloud Virtual Machine
 After=network.target
-
+%s
 [Pritunl]
 PritunlData=%s
 
@@ -12,3 +12,14 @@ Type=simple
 User=root
 ExecStart=%s
 `
+
+const tpmSystemdTemplate = `[Unit]
+Description=Pritunl Cloud Virtual Machine TPM
+After=network.target
+PartOf=%s
+
+[Service]
+Type=simple
+User=root
+ExecStart=%s socket --tpm2 --tpmstate dir=%s --ctrl type=unixio,path=%s --log level=1
+`
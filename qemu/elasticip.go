@@ -0,0 +1,155 @@
+package qemu
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/iproute"
+	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+func UpdateStaticAddr(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	if node.Self.NetworkMode != node.Static || virt.NoPublicAddress ||
+		len(virt.NetworkAdapters) == 0 {
+
+		return
+	}
+
+	adapter := virt.NetworkAdapters[0]
+	ifaceExternalVirt := vm.GetIfaceVirt(virt.Id, 0)
+	ifaceExternal := vm.GetIfaceExternal(virt.Id, 0)
+	namespace := vm.GetNamespace(virt.Id, 0)
+
+	blck, staticAddr, externalIface, err := node.Self.GetStaticAddr(
+		db, virt.Id)
+	if err != nil {
+		return
+	}
+
+	curAddr := ""
+	address, _, err := iproute.AddressGetIface(namespace, ifaceExternal)
+	if err != nil {
+		return
+	}
+	if address != nil {
+		curAddr = address.Local
+	}
+
+	if curAddr == staticAddr.String() {
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":   virt.Id.Hex(),
+		"cur_address":   curAddr,
+		"new_address":   staticAddr.String(),
+		"net_namespace": namespace,
+	}).Info("qemu: Updating instance static address")
+
+	vc, err := vpc.Get(db, adapter.Vpc)
+	if err != nil {
+		return
+	}
+
+	addr, _, err := vc.GetInstanceIp(db, adapter.Subnet, virt.Id,
+		adapter.StaticIp)
+	if err != nil {
+		return
+	}
+
+	staticGateway := blck.GetGateway()
+	staticMask := blck.GetMask()
+	if staticGateway == nil || staticMask == nil {
+		err = &errortypes.ParseError{
+			errors.New("qemu: Invalid block gateway cidr"),
+		}
+		return
+	}
+
+	staticSize, _ := staticMask.Size()
+	staticCidr := fmt.Sprintf(
+		"%s/%d", staticAddr.String(), staticSize)
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "link", "set",
+		ifaceExternalVirt, "master", externalIface,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "-4", "addr",
+		"flush", "dev", ifaceExternal,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"add", staticCidr,
+		"dev", ifaceExternal,
+	)
+	if err != nil {
+		return
+	}
+
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"ip", "route",
+		"replace", "default",
+		"via", staticGateway.String(),
+	)
+	if err != nil {
+		return
+	}
+
+	if curAddr != "" {
+		iptables.Lock()
+		_, _ = utils.ExecCombinedOutput(
+			"", "ip", "netns", "exec", namespace,
+			"iptables", "-t", "nat",
+			"-D", "PREROUTING",
+			"-d", curAddr,
+			"-j", "DNAT",
+			"--to-destination", addr.String(),
+		)
+		iptables.Unlock()
+	}
+
+	iptables.Lock()
+	_, err = utils.ExecCombinedOutputLogged(
+		nil,
+		"ip", "netns", "exec", namespace,
+		"iptables", "-t", "nat",
+		"-A", "PREROUTING",
+		"-d", staticAddr.String(),
+		"-j", "DNAT",
+		"--to-destination", addr.String(),
+	)
+	iptables.Unlock()
+	if err != nil {
+		return
+	}
+
+	store.RemAddress(virt.Id)
+
+	return
+}
@@ -0,0 +1,67 @@
+package qemu
+
+import (
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/systemd"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+var (
+	haVirts     = set.NewSet()
+	haVirtsLock = sync.Mutex{}
+)
+
+func Fence(db *database.Database, virt *vm.VirtualMachine) (err error) {
+	disks := []string{}
+	for _, dsk := range virt.Disks {
+		disks = append(disks, dsk.Path)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":    virt.Id.Hex(),
+		"disks": disks,
+	}).Warning("qemu: Fencing virtual machine owned by another node")
+
+	err = removeVirt(db, virt)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func SetHaVirts(virtIds set.Set) {
+	haVirtsLock.Lock()
+	haVirts = virtIds
+	haVirtsLock.Unlock()
+}
+
+// Stop high availability virtual machines without database access, used
+// when the node lease expires before the instances are failed over
+func FenceHa() {
+	haVirtsLock.Lock()
+	virtIds := haVirts.Copy()
+	haVirtsLock.Unlock()
+
+	for virtIdInf := range virtIds.Iter() {
+		virtId := virtIdInf.(primitive.ObjectID)
+
+		logrus.WithFields(logrus.Fields{
+			"id": virtId.Hex(),
+		}).Warning("qemu: Fencing virtual machine on node with expired lease")
+
+		err := systemd.Stop(paths.GetUnitName(virtId))
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"id":    virtId.Hex(),
+				"error": err,
+			}).Error("qemu: Failed to fence virtual machine")
+		}
+	}
+}
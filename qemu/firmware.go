@@ -0,0 +1,176 @@
+package qemu
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/systemd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func getOvmfCodePath(firmware string) string {
+	if firmware == vm.SecureUefi {
+		return settings.Hypervisor.OvmfSecureCodePath
+	}
+	return settings.Hypervisor.OvmfCodePath
+}
+
+func getOvmfVarsPath(firmware string) string {
+	if firmware == vm.SecureUefi {
+		return settings.Hypervisor.OvmfSecureVarsPath
+	}
+	return settings.Hypervisor.OvmfVarsPath
+}
+
+func initFirmware(virt *vm.VirtualMachine) (err error) {
+	if virt.Firmware != vm.Uefi && virt.Firmware != vm.SecureUefi {
+		return
+	}
+
+	varsPath := paths.GetOvmfVarsPath(virt.Id)
+
+	exists, err := utils.ExistsFile(varsPath)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		return
+	}
+
+	exists, err = utils.ExistsFile(getOvmfCodePath(virt.Firmware))
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: OVMF firmware not found on node"),
+		}
+		return
+	}
+
+	exists, err = utils.ExistsFile(getOvmfVarsPath(virt.Firmware))
+	if err != nil {
+		return
+	}
+
+	if !exists {
+		err = &errortypes.NotFoundError{
+			errors.New("qemu: OVMF vars template not found on node"),
+		}
+		return
+	}
+
+	err = utils.Exec("", "cp", getOvmfVarsPath(virt.Firmware), varsPath)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func startTpm(virt *vm.VirtualMachine) (err error) {
+	if !virt.Tpm {
+		err = removeTpm(virt)
+		if err != nil {
+			return
+		}
+
+		return
+	}
+
+	tpmPath := paths.GetTpmPath(virt.Id)
+	sockPath := paths.GetTpmSockPath(virt.Id)
+	unitName := paths.GetTpmUnitName(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Starting virtual machine TPM")
+
+	err = utils.ExistsMkdir(tpmPath, 0700)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(sockPath)
+	if err != nil {
+		return
+	}
+
+	output := fmt.Sprintf(
+		tpmSystemdTemplate,
+		paths.GetUnitName(virt.Id),
+		settings.Hypervisor.SwtpmPath,
+		tpmPath,
+		sockPath,
+	)
+
+	err = utils.CreateWrite(paths.GetTpmUnitPath(virt.Id), output, 0644)
+	if err != nil {
+		return
+	}
+
+	err = systemd.Reload()
+	if err != nil {
+		return
+	}
+
+	err = systemd.Start(unitName)
+	if err != nil {
+		return
+	}
+
+	for i := 0; i < 50; i++ {
+		exists, e := utils.Exists(sockPath)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if exists {
+			return
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	err = &errortypes.TimeoutError{
+		errors.New("qemu: TPM socket timeout"),
+	}
+	return
+}
+
+func removeTpm(virt *vm.VirtualMachine) (err error) {
+	unitPath := paths.GetTpmUnitPath(virt.Id)
+
+	exists, err := utils.Exists(unitPath)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = systemd.Stop(paths.GetTpmUnitName(virt.Id))
+		if err != nil {
+			return
+		}
+	}
+
+	err = utils.RemoveAll(unitPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetTpmSockPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -27,9 +27,11 @@ import (
 	"github.com/pritunl/pritunl-cloud/interfaces"
 	"github.com/pritunl/pritunl-cloud/iproute"
 	"github.com/pritunl/pritunl-cloud/iptables"
+	"github.com/pritunl/pritunl-cloud/metadata"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/resolver"
 	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/store"
 	"github.com/pritunl/pritunl-cloud/systemd"
@@ -268,18 +270,21 @@ func GetVms(db *database.Database,
 
 			if virt != nil {
 				inst := instMap[vmId]
-				if inst != nil && inst.VmState == vm.Running &&
-					(virt.State == vm.Stopped || virt.State == vm.Failed) {
-
-					inst.State = instance.Cleanup
-					e = virt.CommitState(db, instance.Cleanup)
-				} else {
-					e = virt.Commit(db)
-				}
-				if e != nil {
-					logrus.WithFields(logrus.Fields{
-						"error": e,
-					}).Error("qemu: Failed to commit VM state")
+				if inst != nil {
+					if inst.VmState == vm.Running &&
+						(virt.State == vm.Stopped ||
+							virt.State == vm.Failed) {
+
+						inst.State = instance.Cleanup
+						e = virt.CommitState(db, instance.Cleanup)
+					} else {
+						e = virt.Commit(db)
+					}
+					if e != nil {
+						logrus.WithFields(logrus.Fields{
+							"error": e,
+						}).Error("qemu: Failed to commit VM state")
+					}
 				}
 
 				virtsLock.Lock()
@@ -449,7 +454,8 @@ func NetworkConf(db *database.Database,
 		return
 	}
 
-	addr, gatewayAddr, err := vc.GetIp(db, adapter.Subnet, virt.Id)
+	addr, gatewayAddr, err := vc.GetInstanceIp(db, adapter.Subnet, virt.Id,
+		adapter.StaticIp)
 	if err != nil {
 		return
 	}
@@ -1245,6 +1251,21 @@ func NetworkConf(db *database.Database,
 		}
 	}
 
+	privateIps := []string{addr.String()}
+	privateIps6 := []string{addr6.String()}
+
+	for i := 1; i < len(virt.NetworkAdapters); i++ {
+		adapterAddr, adapterAddr6, e := networkConfAdapter(db, virt, i,
+			vxlan, updateMtuInternal, updateMtuInstance)
+		if e != nil {
+			err = e
+			return
+		}
+
+		privateIps = append(privateIps, adapterAddr.String())
+		privateIps6 = append(privateIps6, adapterAddr6.String())
+	}
+
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
 
@@ -1256,8 +1277,8 @@ func NetworkConf(db *database.Database,
 	coll := db.Instances()
 	err = coll.UpdateId(virt.Id, &bson.M{
 		"$set": &bson.M{
-			"private_ips":  []string{addr.String()},
-			"private_ips6": []string{addr6.String()},
+			"private_ips":  privateIps,
+			"private_ips6": privateIps6,
 			"host_ips":     hostIps,
 		},
 	})
@@ -1270,6 +1291,24 @@ func NetworkConf(db *database.Database,
 		}
 	}
 
+	e := metadata.Start(virt)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id":   virt.Id.Hex(),
+			"net_namespace": namespace,
+			"error":         e,
+		}).Error("qemu: Failed to start instance metadata server")
+	}
+
+	e = resolver.Start(virt, vc)
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id":   virt.Id.Hex(),
+			"net_namespace": namespace,
+			"error":         e,
+		}).Error("qemu: Failed to start instance DNS resolver")
+	}
+
 	return
 }
 
@@ -1311,6 +1350,9 @@ func NetworkConfClear(db *database.Database,
 		return
 	}
 
+	metadata.Stop(virt.Id)
+	resolver.Stop(virt.Id)
+
 	err = networkStopDhClient(db, virt)
 	if err != nil {
 		return
@@ -1336,6 +1378,10 @@ func NetworkConfClear(db *database.Database,
 	interfaces.RemoveVirtIface(ifaceExternalVirt)
 	interfaces.RemoveVirtIface(ifaceInternalVirt)
 
+	for i := 1; i < len(virt.NetworkAdapters); i++ {
+		networkConfAdapterClear(virt, i)
+	}
+
 	store.RemAddress(virt.Id)
 	store.RemRoutes(virt.Id)
 
@@ -1394,6 +1440,11 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = initFirmware(virt)
+	if err != nil {
+		return
+	}
+
 	dsk, err := disk.GetInstanceIndex(db, inst.Id, "0")
 	if err != nil {
 		if _, ok := err.(*database.NotFoundError); ok {
@@ -1458,6 +1509,11 @@ func Create(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = startTpm(virt)
+	if err != nil {
+		return
+	}
+
 	err = systemd.Start(unitName)
 	if err != nil {
 		return
@@ -1491,6 +1547,9 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 	unitName := paths.GetUnitName(virt.Id)
 	unitPath := paths.GetUnitPath(virt.Id)
 	sockPath := paths.GetSockPath(virt.Id)
+	qmpSockPath := paths.GetQmpSockPath(virt.Id)
+	serialSockPath := paths.GetSerialSockPath(virt.Id)
+	vncSockPath := paths.GetVncSockPath(virt.Id)
 	guestPath := paths.GetGuestPath(virt.Id)
 	pidPath := paths.GetPidPath(virt.Id)
 
@@ -1513,9 +1572,12 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		if vrt != nil && vrt.State == vm.Running {
 			shutdown := false
 
+			stopTimeout := time.Duration(
+				settings.Hypervisor.StopTimeout) * time.Second
+
 			logged := false
 			for i := 0; i < 10; i++ {
-				err = qms.Shutdown(virt.Id)
+				shutdown, err = qms.Shutdown(virt.Id, stopTimeout)
 				if err == nil {
 					break
 				}
@@ -1538,33 +1600,6 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 					"error": err,
 				}).Error("qemu: Power off virtual machine error")
 				err = nil
-			} else {
-				for i := 0; i < settings.Hypervisor.StopTimeout; i++ {
-					vrt, err = GetVmInfo(virt.Id, false, true)
-					if err != nil {
-						return
-					}
-
-					if vrt == nil || vrt.State == vm.Stopped ||
-						vrt.State == vm.Failed {
-
-						if vrt != nil {
-							err = vrt.Commit(db)
-							if err != nil {
-								return
-							}
-						}
-
-						shutdown = true
-						break
-					}
-
-					time.Sleep(1 * time.Second)
-
-					if (i+1)%15 == 0 {
-						_ = qms.Shutdown(virt.Id)
-					}
-				}
 			}
 
 			if !shutdown {
@@ -1582,6 +1617,11 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 
 	time.Sleep(3 * time.Second)
 
+	err = removeTpm(virt)
+	if err != nil {
+		return
+	}
+
 	err = NetworkConfClear(db, virt)
 	if err != nil {
 		return
@@ -1630,6 +1670,21 @@ func Destroy(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		return
 	}
 
+	err = utils.RemoveAll(qmpSockPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(serialSockPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(vncSockPath)
+	if err != nil {
+		return
+	}
+
 	err = utils.RemoveAll(guestPath)
 	if err != nil {
 		return
@@ -1676,11 +1731,26 @@ func PowerOn(db *database.Database, inst *instance.Instance,
 		return
 	}
 
+	err = utils.ExistsMkdir(paths.GetVmPath(virt.Id), 0755)
+	if err != nil {
+		return
+	}
+
+	err = initFirmware(virt)
+	if err != nil {
+		return
+	}
+
 	err = writeService(virt)
 	if err != nil {
 		return
 	}
 
+	err = startTpm(virt)
+	if err != nil {
+		return
+	}
+
 	err = systemd.Start(unitName)
 	if err != nil {
 		return
@@ -1737,9 +1807,13 @@ func PowerOff(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		"id": virt.Id.Hex(),
 	}).Info("qemu: Stopping virtual machine")
 
+	stopTimeout := time.Duration(
+		settings.Hypervisor.StopTimeout) * time.Second
+
+	shutdown := false
 	logged := false
 	for i := 0; i < 10; i++ {
-		err = qms.Shutdown(virt.Id)
+		shutdown, err = qms.Shutdown(virt.Id, stopTimeout)
 		if err == nil {
 			break
 		}
@@ -1755,49 +1829,33 @@ func PowerOff(db *database.Database, virt *vm.VirtualMachine) (err error) {
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	shutdown := false
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"id":    virt.Id.Hex(),
 			"error": err,
 		}).Error("qemu: Power off virtual machine error")
 		err = nil
-	} else {
-		for i := 0; i < settings.Hypervisor.StopTimeout; i++ {
-			vrt, e := GetVmInfo(virt.Id, false, true)
-			if e != nil {
-				err = e
-				return
-			}
-
-			if vrt == nil || vrt.State == vm.Stopped ||
-				vrt.State == vm.Failed {
-
-				if vrt != nil {
-					err = vrt.Commit(db)
-					if err != nil {
-						return
-					}
-				}
-
-				shutdown = true
-				break
-			}
-
-			time.Sleep(1 * time.Second)
-
-			if (i+1)%15 == 0 {
-				_ = qms.Shutdown(virt.Id)
-			}
-		}
 	}
 
 	if !shutdown {
 		logrus.WithFields(logrus.Fields{
 			"id": virt.Id.Hex(),
 		}).Warning("qemu: Force power off virtual machine")
+	}
 
-		err = systemd.Stop(unitName)
+	// Guest has already shutdown, stop waits for the qemu process to exit
+	err = systemd.Stop(unitName)
+	if err != nil {
+		return
+	}
+
+	vrt, err := GetVmInfo(virt.Id, false, true)
+	if err != nil {
+		return
+	}
+
+	if vrt != nil {
+		err = vrt.Commit(db)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,434 @@
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/cloudinit"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/systemd"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+type diskInfo struct {
+	VirtualSize int64 `json:"virtual-size"`
+}
+
+func GetMigrateDisks(virt *vm.VirtualMachine) (
+	dsks []*instance.MigrateDisk, err error) {
+
+	dsks = []*instance.MigrateDisk{}
+
+	for _, dsk := range virt.Disks {
+		output, e := utils.ExecCombinedOutputLogged(
+			nil,
+			"qemu-img", "info", "-U", "--output=json", dsk.Path,
+		)
+		if e != nil {
+			err = e
+			return
+		}
+
+		info := &diskInfo{}
+		err = json.Unmarshal([]byte(output), info)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrap(err, "qemu: Failed to parse disk info"),
+			}
+			return
+		}
+
+		dsks = append(dsks, &instance.MigrateDisk{
+			Id:    dsk.GetId(),
+			Index: dsk.Index,
+			Size:  info.VirtualSize,
+		})
+	}
+
+	return
+}
+
+func GetMigrateVarsSize(virt *vm.VirtualMachine) (size int64, err error) {
+	if virt.Firmware != vm.Uefi && virt.Firmware != vm.SecureUefi {
+		return
+	}
+
+	info, err := os.Stat(paths.GetOvmfVarsPath(virt.Id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to stat OVMF vars"),
+		}
+		return
+	}
+
+	size = info.Size()
+	return
+}
+
+// Block devices mirrored during live migration, the OVMF vars pflash
+// drive is mirrored with the disks to preserve the guest NVRAM
+func getMigrateDevices(inst *instance.Instance) (devices []string) {
+	devices = []string{}
+	for _, dsk := range inst.MigrateDisks {
+		devices = append(devices, fmt.Sprintf("virtio%d", dsk.Index))
+	}
+	if inst.MigrateVarsSize > 0 {
+		devices = append(devices, "pflash1")
+	}
+	return
+}
+
+func writeMigrateTls(virt *vm.VirtualMachine, token string) (err error) {
+	tlsPath := paths.GetMigrateTlsPath(virt.Id)
+
+	err = utils.ExistsMkdir(tlsPath, 0700)
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(path.Join(tlsPath, "keys.psk"),
+		fmt.Sprintf("qemu:%s\n", token), 0600)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateIncoming(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine, addr string, port, nbdPort int) (err error) {
+
+	vmPath := paths.GetVmPath(virt.Id)
+	unitName := paths.GetUnitName(virt.Id)
+	unitPath := paths.GetUnitPath(virt.Id)
+
+	logrus.WithFields(logrus.Fields{
+		"id":       virt.Id.Hex(),
+		"address":  addr,
+		"port":     port,
+		"nbd_port": nbdPort,
+	}).Info("qemu: Starting incoming virtual machine migration")
+
+	err = utils.ExistsMkdir(settings.Hypervisor.LibPath, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(vmPath, 0755)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExistsMkdir(paths.GetDisksPath(), 0755)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range inst.MigrateDisks {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"qemu-img", "create",
+			"-f", "qcow2",
+			paths.GetDiskPath(dsk.Id),
+			strconv.FormatInt(dsk.Size, 10),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	err = writeMigrateTls(virt, inst.MigrateToken)
+	if err != nil {
+		return
+	}
+
+	// Vars file is filled by the source mirror, sized to match the
+	// source file which may differ from the local template
+	if inst.MigrateVarsSize > 0 {
+		_, err = utils.ExecCombinedOutputLogged(
+			nil,
+			"qemu-img", "create",
+			"-f", "raw",
+			paths.GetOvmfVarsPath(virt.Id),
+			strconv.FormatInt(inst.MigrateVarsSize, 10),
+		)
+		if err != nil {
+			return
+		}
+	}
+
+	err = cloudinit.Write(db, inst, virt, false)
+	if err != nil {
+		return
+	}
+
+	err = initFirmware(virt)
+	if err != nil {
+		return
+	}
+
+	err = startTpm(virt)
+	if err != nil {
+		return
+	}
+
+	qm, err := NewQemu(virt)
+	if err != nil {
+		return
+	}
+	// Incoming migration is started from the monitor once TLS is
+	// configured, the listener only binds to the internal address
+	qm.Incoming = "defer"
+
+	output, err := qm.Marshal()
+	if err != nil {
+		return
+	}
+
+	err = utils.CreateWrite(unitPath, output, 0644)
+	if err != nil {
+		return
+	}
+
+	err = systemd.Reload()
+	if err != nil {
+		return
+	}
+
+	err = systemd.Start(unitName)
+	if err != nil {
+		return
+	}
+
+	err = Wait(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = qms.MigrateIncoming(virt.Id, addr, port, nbdPort,
+		paths.GetMigrateTlsPath(virt.Id), getMigrateDevices(inst))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateStart(inst *instance.Instance, virt *vm.VirtualMachine) (
+	err error) {
+
+	err = writeMigrateTls(virt, inst.MigrateToken)
+	if err != nil {
+		return
+	}
+
+	err = qms.Migrate(virt.Id, inst.MigrateAddress, inst.MigratePort,
+		inst.MigrateNbdPort, paths.GetMigrateTlsPath(virt.Id),
+		getMigrateDevices(inst), time.Duration(
+			settings.Hypervisor.MigrateTimeout)*time.Second)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateStop(inst *instance.Instance, virt *vm.VirtualMachine,
+	force bool) (err error) {
+
+	if force {
+		e := qms.MigrateCancel(virt.Id)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"id":    virt.Id.Hex(),
+				"error": e,
+			}).Warn("qemu: Failed to cancel virtual machine migration")
+		}
+	}
+
+	err = qms.MigrateRelease(virt.Id, getMigrateDevices(inst), force)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetMigrateTlsPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func MigrateComplete(db *database.Database, inst *instance.Instance,
+	virt *vm.VirtualMachine) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Completing virtual machine migration")
+
+	e := qms.MigrateIncomingComplete(virt.Id, getMigrateDevices(inst))
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"id":    virt.Id.Hex(),
+			"error": e,
+		}).Warn("qemu: Failed to stop migration disk exports")
+	}
+
+	err = utils.RemoveAll(paths.GetMigrateTlsPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = writeService(virt)
+	if err != nil {
+		return
+	}
+
+	if virt.Vnc {
+		err = qms.VncPassword(virt.Id, inst.VncPassword)
+		if err != nil {
+			return
+		}
+	}
+
+	err = NetworkConf(db, virt)
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+
+	return
+}
+
+func MigrateRemove(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"id": virt.Id.Hex(),
+	}).Info("qemu: Removing migrated virtual machine")
+
+	err = removeVirt(db, virt)
+	if err != nil {
+		return
+	}
+
+	for _, dsk := range virt.Disks {
+		err = utils.RemoveAll(dsk.Path)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func removeVirt(db *database.Database, virt *vm.VirtualMachine) (
+	err error) {
+
+	unitName := paths.GetUnitName(virt.Id)
+	unitPath := paths.GetUnitPath(virt.Id)
+
+	exists, err := utils.Exists(unitPath)
+	if err != nil {
+		return
+	}
+
+	if exists {
+		err = systemd.Stop(unitName)
+		if err != nil {
+			return
+		}
+	}
+
+	err = removeTpm(virt)
+	if err != nil {
+		return
+	}
+
+	err = NetworkConfClear(db, virt)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetVmPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(unitPath)
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetSockPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetQmpSockPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetSerialSockPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetVncSockPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetGuestPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetPidPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetInitPath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = utils.RemoveAll(paths.GetLeasePath(virt.Id))
+	if err != nil {
+		return
+	}
+
+	err = systemd.Reload()
+	if err != nil {
+		return
+	}
+
+	store.RemVirt(virt.Id)
+	store.RemDisks(virt.Id)
+	store.RemAddress(virt.Id)
+	store.RemRoutes(virt.Id)
+
+	return
+}
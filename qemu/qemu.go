@@ -8,6 +8,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/paths"
 	"github.com/pritunl/pritunl-cloud/usb"
+	"github.com/pritunl/pritunl-cloud/vm"
 )
 
 type Disk struct {
@@ -29,21 +30,27 @@ type UsbDevice struct {
 }
 
 type Qemu struct {
-	Id         primitive.ObjectID
-	Data       string
-	Kvm        bool
-	Machine    string
-	Cpu        string
-	Cpus       int
-	Cores      int
-	Threads    int
-	Boot       string
-	Memory     int
-	Vnc        bool
-	VncDisplay int
-	Disks      []*Disk
-	Networks   []*Network
-	UsbDevices []*UsbDevice
+	Id          primitive.ObjectID
+	Data        string
+	Kvm         bool
+	Machine     string
+	Cpu         string
+	Cpus        int
+	MaxCpus     int
+	Cores       int
+	Threads     int
+	Boot        string
+	Memory      int
+	MaxMemory   int
+	MemorySlots int
+	Vnc         bool
+	VncDisplay  int
+	Disks       []*Disk
+	Networks    []*Network
+	UsbDevices  []*UsbDevice
+	Firmware    string
+	Tpm         bool
+	Incoming    string
 }
 
 func (q *Qemu) Marshal() (output string, err error) {
@@ -62,7 +69,9 @@ func (q *Qemu) Marshal() (output string, err error) {
 		cmd = append(cmd, nodeVga)
 		cmd = append(cmd, "-vnc")
 		cmd = append(cmd, fmt.Sprintf(
-			":%d,password,share=allow-exclusive", q.VncDisplay))
+			"unix:%s,password,share=allow-exclusive",
+			paths.GetVncSockPath(q.Id),
+		))
 	}
 
 	if q.Kvm {
@@ -80,26 +89,67 @@ func (q *Qemu) Marshal() (output string, err error) {
 	if nodeVga == node.Virtio {
 		options += ",gfx_passthru=on"
 	}
+	if q.Firmware == vm.SecureUefi {
+		options += ",smm=on"
+	}
 	cmd = append(cmd, fmt.Sprintf("type=%s%s", q.Machine, options))
 
+	if q.Firmware == vm.Uefi || q.Firmware == vm.SecureUefi {
+		if q.Firmware == vm.SecureUefi {
+			cmd = append(cmd, "-global")
+			cmd = append(cmd, "driver=cfi.pflash01,property=secure,value=on")
+		}
+
+		cmd = append(cmd, "-drive")
+		cmd = append(cmd, fmt.Sprintf(
+			"if=pflash,format=raw,unit=0,file=%s,readonly=on",
+			getOvmfCodePath(q.Firmware),
+		))
+
+		cmd = append(cmd, "-drive")
+		cmd = append(cmd, fmt.Sprintf(
+			"if=pflash,format=raw,unit=1,file=%s",
+			paths.GetOvmfVarsPath(q.Id),
+		))
+	}
+
 	if q.Kvm {
 		cmd = append(cmd, "-cpu")
 		cmd = append(cmd, q.Cpu)
 	}
 
 	cmd = append(cmd, "-smp")
-	cmd = append(cmd, fmt.Sprintf(
-		"cpus=%d,cores=%d,threads=%d",
-		q.Cpus,
-		q.Cores,
-		q.Threads,
-	))
+	if q.MaxCpus > q.Cpus {
+		cmd = append(cmd, fmt.Sprintf(
+			"cpus=%d,maxcpus=%d,cores=%d,threads=%d",
+			q.Cpus,
+			q.MaxCpus,
+			q.Cores,
+			q.Threads,
+		))
+	} else {
+		cmd = append(cmd, fmt.Sprintf(
+			"cpus=%d,cores=%d,threads=%d",
+			q.Cpus,
+			q.Cores,
+			q.Threads,
+		))
+	}
 
 	cmd = append(cmd, "-boot")
 	cmd = append(cmd, q.Boot)
 
 	cmd = append(cmd, "-m")
-	cmd = append(cmd, fmt.Sprintf("%dM", q.Memory))
+	if q.MaxMemory > q.Memory && q.MemorySlots > 0 {
+		cmd = append(cmd, fmt.Sprintf(
+			"%dM,slots=%d,maxmem=%dM",
+			q.Memory,
+			q.MemorySlots,
+			q.MaxMemory,
+		))
+	} else {
+		cmd = append(cmd, fmt.Sprintf("%dM", q.Memory))
+	}
 
 	for _, disk := range q.Disks {
 		additional := ""
@@ -138,6 +188,8 @@ func (q *Qemu) Marshal() (output string, err error) {
 			count,
 			network.Iface,
 		))
+
+		count += 1
 	}
 
 	cmd = append(cmd, "-cdrom")
@@ -149,9 +201,46 @@ func (q *Qemu) Marshal() (output string, err error) {
 		paths.GetSockPath(q.Id),
 	))
 
+	cmd = append(cmd, "-qmp")
+	cmd = append(cmd, fmt.Sprintf(
+		"unix:%s,server,nowait",
+		paths.GetQmpSockPath(q.Id),
+	))
+
+	unitDeps := ""
+	if q.Tpm {
+		tpmUnitName := paths.GetTpmUnitName(q.Id)
+		unitDeps = fmt.Sprintf("Wants=%s\nAfter=%s\n",
+			tpmUnitName, tpmUnitName)
+
+		cmd = append(cmd, "-chardev")
+		cmd = append(cmd, fmt.Sprintf(
+			"socket,id=chrtpm,path=%s",
+			paths.GetTpmSockPath(q.Id),
+		))
+		cmd = append(cmd, "-tpmdev")
+		cmd = append(cmd, "emulator,id=tpm0,chardev=chrtpm")
+		cmd = append(cmd, "-device")
+		cmd = append(cmd, "tpm-tis,tpmdev=tpm0")
+	}
+
+	if q.Incoming != "" {
+		cmd = append(cmd, "-incoming")
+		cmd = append(cmd, q.Incoming)
+	}
+
 	cmd = append(cmd, "-pidfile")
 	cmd = append(cmd, paths.GetPidPath(q.Id))
 
+	cmd = append(cmd, "-chardev")
+	cmd = append(cmd, fmt.Sprintf(
+		"socket,id=serial0,path=%s,server,nowait,logfile=%s,logappend=on",
+		paths.GetSerialSockPath(q.Id),
+		paths.GetSerialLogPath(q.Id),
+	))
+	cmd = append(cmd, "-serial")
+	cmd = append(cmd, "chardev:serial0")
+
 	guestPath := paths.GetGuestPath(q.Id)
 	cmd = append(cmd, "-chardev")
 	cmd = append(cmd, fmt.Sprintf(
@@ -187,6 +276,7 @@ func (q *Qemu) Marshal() (output string, err error) {
 
 	output = fmt.Sprintf(
 		systemdTemplate,
+		unitDeps,
 		q.Data,
 		strings.Join(cmd, " "),
 	)
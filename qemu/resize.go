@@ -0,0 +1,49 @@
+package qemu
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/store"
+	"github.com/pritunl/pritunl-cloud/vm"
+)
+
+func Resize(virt *vm.VirtualMachine, processors, memory int) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"id":         virt.Id.Hex(),
+		"processors": processors,
+		"memory":     memory,
+	}).Info("qemu: Resizing virtual machine")
+
+	newVirt := *virt
+	newVirt.Resized = true
+
+	if processors > virt.Processors {
+		err = qms.SetProcessors(virt.Id, processors)
+		if err != nil {
+			return
+		}
+		newVirt.Processors = processors
+
+		err = writeService(&newVirt)
+		if err != nil {
+			return
+		}
+		store.RemVirt(virt.Id)
+	}
+
+	if memory > virt.Memory {
+		err = qms.SetMemory(virt.Id, memory)
+		if err != nil {
+			return
+		}
+		newVirt.Memory = memory
+
+		err = writeService(&newVirt)
+		if err != nil {
+			return
+		}
+		store.RemVirt(virt.Id)
+	}
+
+	return
+}
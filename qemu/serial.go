@@ -0,0 +1,51 @@
+package qemu
+
+import (
+	"os"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/paths"
+	"github.com/pritunl/pritunl-cloud/qms"
+	"github.com/pritunl/pritunl-cloud/settings"
+)
+
+func RotateSerialLog(virtId primitive.ObjectID) (err error) {
+	logPath := paths.GetSerialLogPath(virtId)
+
+	stat, err := os.Stat(logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+			return
+		}
+
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qemu: Failed to stat serial log"),
+		}
+		return
+	}
+
+	if stat.Size() < int64(settings.Hypervisor.SerialLogSize)*1024 {
+		return
+	}
+
+	// Qemu continues writing to the renamed log until the serial
+	// chardev is replaced, no output is lost during the rotation
+	err = os.Rename(logPath, logPath+".1")
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qemu: Failed to rename serial log"),
+		}
+		return
+	}
+
+	err = qms.ReopenSerialLog(virtId,
+		paths.GetSerialSockPath(virtId), logPath)
+	if err != nil {
+		return
+	}
+
+	return
+}
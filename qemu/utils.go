@@ -6,6 +6,7 @@ import (
 	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/vm"
 )
 
@@ -19,21 +20,30 @@ func NewQemu(virt *vm.VirtualMachine) (qm *Qemu, err error) {
 	}
 
 	qm = &Qemu{
-		Id:         virt.Id,
-		Data:       string(data),
-		Kvm:        node.Self.Hypervisor == node.Kvm,
-		Machine:    "pc",
-		Cpu:        "host",
-		Cpus:       virt.Processors,
-		Cores:      1,
-		Threads:    1,
-		Boot:       "c",
-		Memory:     virt.Memory,
-		Vnc:        virt.Vnc,
-		VncDisplay: virt.VncDisplay,
-		Disks:      []*Disk{},
-		Networks:   []*Network{},
-		UsbDevices: []*UsbDevice{},
+		Id:          virt.Id,
+		Data:        string(data),
+		Kvm:         node.Self.Hypervisor == node.Kvm,
+		Machine:     "pc",
+		Cpu:         "host",
+		Cpus:        virt.Processors,
+		MaxCpus:     virt.MaxProcessors,
+		Cores:       1,
+		Threads:     1,
+		Boot:        "c",
+		Memory:      virt.Memory,
+		MaxMemory:   virt.MaxMemory,
+		MemorySlots: settings.Hypervisor.MemorySlots,
+		Vnc:         virt.Vnc,
+		VncDisplay:  virt.VncDisplay,
+		Disks:       []*Disk{},
+		Networks:    []*Network{},
+		UsbDevices:  []*UsbDevice{},
+		Firmware:    virt.Firmware,
+		Tpm:         virt.Tpm,
+	}
+
+	if virt.Firmware == vm.SecureUefi {
+		qm.Machine = "q35"
 	}
 
 	for _, disk := range virt.Disks {
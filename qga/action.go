@@ -0,0 +1,244 @@
+package qga
+
+import (
+	"encoding/base64"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type Action struct {
+	Action   string   `json:"action"`
+	Path     string   `json:"path,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	Input    string   `json:"input,omitempty"`
+	Pid      int      `json:"pid,omitempty"`
+	Data     string   `json:"data,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+}
+
+type ExecStatusResult struct {
+	Exited       bool   `json:"exited"`
+	ExitCode     int    `json:"exit_code"`
+	Signal       int    `json:"signal"`
+	Output       string `json:"output"`
+	Error        string `json:"error"`
+	OutTruncated bool   `json:"out_truncated"`
+	ErrTruncated bool   `json:"err_truncated"`
+}
+
+type FsFreezeResult struct {
+	Count  int    `json:"count,omitempty"`
+	Status string `json:"status"`
+}
+
+func (a *Action) Validate() (errData *errortypes.ErrorData) {
+	switch a.Action {
+	case Exec:
+		if a.Path == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "path_required",
+				Message: "Missing required executable path",
+			}
+			return
+		}
+
+		if a.Args == nil {
+			a.Args = []string{}
+		}
+		break
+	case ExecStatus:
+		if a.Pid == 0 {
+			errData = &errortypes.ErrorData{
+				Error:   "pid_required",
+				Message: "Missing required process id",
+			}
+			return
+		}
+		break
+	case FileWrite:
+		if a.Path == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "path_required",
+				Message: "Missing required file path",
+			}
+			return
+		}
+
+		data, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			errData = &errortypes.ErrorData{
+				Error:   "data_invalid",
+				Message: "File data must be base64 encoded",
+			}
+			return
+		}
+
+		if len(data) > maxFileSize {
+			errData = &errortypes.ErrorData{
+				Error:   "data_too_large",
+				Message: "File data exceeds maximum size",
+			}
+			return
+		}
+		break
+	case SetPassword:
+		if a.Username == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "username_required",
+				Message: "Missing required username",
+			}
+			return
+		}
+
+		if a.Password == "" {
+			errData = &errortypes.ErrorData{
+				Error:   "password_required",
+				Message: "Missing required password",
+			}
+			return
+		}
+		break
+	case FsFreeze, FsThaw, FsFreezeStatus, OsInfo, FsInfo:
+		break
+	default:
+		errData = &errortypes.ErrorData{
+			Error:   "action_invalid",
+			Message: "Invalid guest agent action",
+		}
+		return
+	}
+
+	return
+}
+
+func (a *Action) Run(sockPath string) (result interface{}, err error) {
+	lockId := socketsLock.Lock(sockPath)
+	defer socketsLock.Unlock(sockPath, lockId)
+
+	conn, err := connect(sockPath)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	switch a.Action {
+	case Exec:
+		var input []byte
+		if a.Input != "" {
+			input = []byte(a.Input)
+		}
+
+		result, err = conn.Exec(a.Path, a.Args, input)
+		break
+	case ExecStatus:
+		status, e := conn.ExecStatus(a.Pid)
+		if e != nil {
+			err = e
+			break
+		}
+
+		output, _ := base64.StdEncoding.DecodeString(status.OutData)
+		errOutput, _ := base64.StdEncoding.DecodeString(status.ErrData)
+
+		result = &ExecStatusResult{
+			Exited:       status.Exited,
+			ExitCode:     status.ExitCode,
+			Signal:       status.Signal,
+			Output:       string(output),
+			Error:        string(errOutput),
+			OutTruncated: status.OutTruncated,
+			ErrTruncated: status.ErrTruncated,
+		}
+		break
+	case FileWrite:
+		data, _ := base64.StdEncoding.DecodeString(a.Data)
+		err = writeFile(conn, a.Path, data)
+		break
+	case SetPassword:
+		err = conn.SetUserPassword(a.Username, a.Password)
+		break
+	case FsFreeze:
+		count, e := conn.FsfreezeFreeze()
+		if e != nil {
+			err = e
+			break
+		}
+
+		result = &FsFreezeResult{
+			Count:  count,
+			Status: Frozen,
+		}
+		break
+	case FsThaw:
+		count, e := conn.FsfreezeThaw()
+		if e != nil {
+			err = e
+			break
+		}
+
+		result = &FsFreezeResult{
+			Count:  count,
+			Status: Thawed,
+		}
+		break
+	case FsFreezeStatus:
+		status, e := conn.FsfreezeStatus()
+		if e != nil {
+			err = e
+			break
+		}
+
+		result = &FsFreezeResult{
+			Status: status,
+		}
+		break
+	case OsInfo:
+		result, err = conn.GetOsinfo()
+		break
+	case FsInfo:
+		result, err = conn.GetFsinfo()
+		break
+	}
+
+	return
+}
+
+func writeFile(conn *Connection, path string, data []byte) (err error) {
+	handle, err := conn.FileOpen(path, "w")
+	if err != nil {
+		return
+	}
+
+	for len(data) > 0 {
+		n := fileChunkSize
+		if n > len(data) {
+			n = len(data)
+		}
+
+		info, e := conn.FileWrite(handle, data[:n])
+		if e != nil {
+			err = e
+			_ = conn.FileClose(handle)
+			return
+		}
+
+		if info.Count <= 0 {
+			err = &errortypes.WriteError{
+				errors.New("qga: Guest agent file write stalled"),
+			}
+			_ = conn.FileClose(handle)
+			return
+		}
+
+		data = data[info.Count:]
+	}
+
+	err = conn.FileClose(handle)
+	if err != nil {
+		return
+	}
+
+	return
+}
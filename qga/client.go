@@ -0,0 +1,220 @@
+package qga
+
+import (
+	"bufio"
+	"encoding/json"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type command struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+}
+
+type Error struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+type response struct {
+	Return json.RawMessage `json:"return"`
+	Error  *Error          `json:"error"`
+}
+
+type Connection struct {
+	Timeout  time.Duration
+	sockPath string
+	conn     net.Conn
+	reader   *bufio.Reader
+}
+
+func (c *Connection) Connect() (err error) {
+	conn, err := net.DialTimeout("unix", c.sockPath, connectTimeout)
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "qga: Failed to connect to guest agent"),
+		}
+		return
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	err = c.sync()
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	return
+}
+
+func (c *Connection) Close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
+
+func (c *Connection) write(data []byte) (err error) {
+	err = c.conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qga: Failed set deadline"),
+		}
+		return
+	}
+
+	_, err = c.conn.Write(data)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qga: Failed to write to guest agent"),
+		}
+		return
+	}
+
+	return
+}
+
+// The guest agent socket is persistent and may contain a stale
+// response from an earlier client, guest-sync-delimited flushes it
+func (c *Connection) sync() (err error) {
+	syncId := rand.Int63n(1 << 31)
+
+	data, err := json.Marshal(&command{
+		Execute: "guest-sync-delimited",
+		Arguments: map[string]interface{}{
+			"id": syncId,
+		},
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qga: Failed to marshal command"),
+		}
+		return
+	}
+
+	err = c.write(append([]byte{0xff}, append(data, '\n')...))
+	if err != nil {
+		return
+	}
+
+	err = c.conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qga: Failed set deadline"),
+		}
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err = c.reader.ReadBytes(0xff)
+		if err != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(err, "qga: Failed to read from guest agent"),
+			}
+			return
+		}
+
+		line, e := c.reader.ReadBytes('\n')
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "qga: Failed to read from guest agent"),
+			}
+			return
+		}
+
+		resp := &response{}
+		e = json.Unmarshal(line, resp)
+		if e != nil {
+			continue
+		}
+
+		var respId int64
+		e = json.Unmarshal(resp.Return, &respId)
+		if e == nil && respId == syncId {
+			return
+		}
+	}
+
+	err = &errortypes.ReadError{
+		errors.New("qga: Failed to sync guest agent"),
+	}
+	return
+}
+
+func (c *Connection) Command(execute string, args interface{},
+	ret interface{}) (err error) {
+
+	data, err := json.Marshal(&command{
+		Execute:   execute,
+		Arguments: args,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qga: Failed to marshal command"),
+		}
+		return
+	}
+
+	err = c.write(append(data, '\n'))
+	if err != nil {
+		return
+	}
+
+	err = c.conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qga: Failed set deadline"),
+		}
+		return
+	}
+
+	line, err := c.reader.ReadBytes('\n')
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qga: Failed to read from guest agent"),
+		}
+		return
+	}
+
+	resp := &response{}
+	err = json.Unmarshal(line, resp)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qga: Failed to parse guest agent response"),
+		}
+		return
+	}
+
+	if resp.Error != nil {
+		err = &errortypes.ExecError{
+			errors.Newf("qga: Command '%s' failed (%s) '%s'",
+				execute, resp.Error.Class, resp.Error.Desc),
+		}
+		return
+	}
+
+	if ret != nil && len(resp.Return) != 0 {
+		err = json.Unmarshal(resp.Return, ret)
+		if err != nil {
+			err = &errortypes.ParseError{
+				errors.Wrapf(err,
+					"qga: Failed to parse '%s' response", execute),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func NewConnection(sockPath string) *Connection {
+	return &Connection{
+		Timeout:  commandTimeout,
+		sockPath: sockPath,
+	}
+}
@@ -0,0 +1,198 @@
+package qga
+
+import (
+	"encoding/base64"
+)
+
+type ExecInfo struct {
+	Pid int `json:"pid"`
+}
+
+type ExecStatusInfo struct {
+	Exited       bool   `json:"exited"`
+	ExitCode     int    `json:"exitcode"`
+	Signal       int    `json:"signal"`
+	OutData      string `json:"out-data"`
+	ErrData      string `json:"err-data"`
+	OutTruncated bool   `json:"out-truncated"`
+	ErrTruncated bool   `json:"err-truncated"`
+}
+
+type FileWriteInfo struct {
+	Count int  `json:"count"`
+	Eof   bool `json:"eof"`
+}
+
+type OsInfoData struct {
+	Id            string `json:"id"`
+	Name          string `json:"name"`
+	PrettyName    string `json:"pretty-name"`
+	Version       string `json:"version"`
+	VersionId     string `json:"version-id"`
+	KernelRelease string `json:"kernel-release"`
+	KernelVersion string `json:"kernel-version"`
+	Machine       string `json:"machine"`
+}
+
+type FsInfoData struct {
+	Name       string `json:"name"`
+	Mountpoint string `json:"mountpoint"`
+	Type       string `json:"type"`
+	UsedBytes  int64  `json:"used-bytes"`
+	TotalBytes int64  `json:"total-bytes"`
+}
+
+func (c *Connection) NetworkGetInterfaces() (
+	ifaces []*Interface, err error) {
+
+	ifaces = []*Interface{}
+
+	err = c.Command("guest-network-get-interfaces", nil, &ifaces)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) Exec(path string, args []string, input []byte) (
+	info *ExecInfo, err error) {
+
+	info = &ExecInfo{}
+
+	cmdArgs := map[string]interface{}{
+		"path":           path,
+		"arg":            args,
+		"capture-output": true,
+	}
+	if len(input) > 0 {
+		cmdArgs["input-data"] = base64.StdEncoding.EncodeToString(input)
+	}
+
+	err = c.Command("guest-exec", cmdArgs, info)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) ExecStatus(pid int) (
+	status *ExecStatusInfo, err error) {
+
+	status = &ExecStatusInfo{}
+
+	err = c.Command("guest-exec-status", map[string]interface{}{
+		"pid": pid,
+	}, status)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) FileOpen(path, mode string) (
+	handle int, err error) {
+
+	err = c.Command("guest-file-open", map[string]interface{}{
+		"path": path,
+		"mode": mode,
+	}, &handle)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) FileWrite(handle int, data []byte) (
+	info *FileWriteInfo, err error) {
+
+	info = &FileWriteInfo{}
+
+	err = c.Command("guest-file-write", map[string]interface{}{
+		"handle":  handle,
+		"buf-b64": base64.StdEncoding.EncodeToString(data),
+	}, info)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) FileClose(handle int) (err error) {
+	err = c.Command("guest-file-close", map[string]interface{}{
+		"handle": handle,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) SetUserPassword(username, password string) (
+	err error) {
+
+	err = c.Command("guest-set-user-password", map[string]interface{}{
+		"username": username,
+		"password": base64.StdEncoding.EncodeToString([]byte(password)),
+		"crypted":  false,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) FsfreezeFreeze() (count int, err error) {
+	err = c.Command("guest-fsfreeze-freeze", nil, &count)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) FsfreezeThaw() (count int, err error) {
+	err = c.Command("guest-fsfreeze-thaw", nil, &count)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) FsfreezeStatus() (status string, err error) {
+	err = c.Command("guest-fsfreeze-status", nil, &status)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) GetOsinfo() (info *OsInfoData, err error) {
+	info = &OsInfoData{}
+
+	err = c.Command("guest-get-osinfo", nil, info)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) GetFsinfo() (infos []*FsInfoData, err error) {
+	infos = []*FsInfoData{}
+
+	err = c.Command("guest-get-fsinfo", nil, &infos)
+	if err != nil {
+		return
+	}
+
+	return
+}
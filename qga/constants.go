@@ -0,0 +1,25 @@
+package qga
+
+import (
+	"time"
+)
+
+const (
+	Exec           = "exec"
+	ExecStatus     = "exec_status"
+	FileWrite      = "file_write"
+	SetPassword    = "set_password"
+	FsFreeze       = "fs_freeze"
+	FsThaw         = "fs_thaw"
+	FsFreezeStatus = "fs_freeze_status"
+	OsInfo         = "os_info"
+	FsInfo         = "fs_info"
+
+	Frozen = "frozen"
+	Thawed = "thawed"
+
+	connectTimeout = 1 * time.Second
+	commandTimeout = 10 * time.Second
+	fileChunkSize  = 48 * 1024
+	maxFileSize    = 8 * 1024 * 1024
+)
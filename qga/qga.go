@@ -1,19 +1,15 @@
 package qga
 
 import (
-	"bytes"
-	"encoding/json"
-	"net"
 	"strings"
 	"time"
 
-	"github.com/dropbox/godropbox/errors"
-	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
 )
 
-type Command struct {
-	Execute string `json:"execute"`
-}
+var (
+	socketsLock = utils.NewMultiTimeoutLock(1 * time.Minute)
+)
 
 type Address struct {
 	Type    string `json:"ip-address-type"`
@@ -60,63 +56,70 @@ func (i *Interfaces) GetAddr(macAddr string) (guestAddr, guestAddr6 string) {
 	return
 }
 
+func connect(sockPath string) (conn *Connection, err error) {
+	conn = NewConnection(sockPath)
+
+	err = conn.Connect()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
 func GetInterfaces(sockPath string) (ifaces *Interfaces, err error) {
-	conn, err := net.DialTimeout(
-		"unix",
-		sockPath,
-		1*time.Second,
-	)
+	lockId := socketsLock.Lock(sockPath)
+	defer socketsLock.Unlock(sockPath, lockId)
+
+	conn, err := connect(sockPath)
 	if err != nil {
-		err = &errortypes.ConnectionError{
-			errors.Wrap(err, "qga: Failed to connect to guest agent"),
-		}
 		return
 	}
 	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(1 * time.Second))
+	conn.Timeout = 1 * time.Second
+
+	interfaces, err := conn.NetworkGetInterfaces()
 	if err != nil {
 		return
 	}
 
-	cmd := &Command{
-		Execute: "guest-network-get-interfaces",
+	ifaces = &Interfaces{
+		Interfaces: interfaces,
 	}
+	return
+}
+
+func Freeze(sockPath string) (count int, err error) {
+	lockId := socketsLock.Lock(sockPath)
+	defer socketsLock.Unlock(sockPath, lockId)
 
-	cmdByte, err := json.Marshal(cmd)
+	conn, err := connect(sockPath)
 	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "qga: Failed to parse guest agent command"),
-		}
 		return
 	}
+	defer conn.Close()
 
-	_, err = conn.Write(cmdByte)
+	count, err = conn.FsfreezeFreeze()
 	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "qga: Failed to write to guest agent"),
-		}
 		return
 	}
 
-	buff := make([]byte, 100000)
-	_, err = conn.Read(buff)
+	return
+}
+
+func Thaw(sockPath string) (count int, err error) {
+	lockId := socketsLock.Lock(sockPath)
+	defer socketsLock.Unlock(sockPath, lockId)
+
+	conn, err := connect(sockPath)
 	if err != nil {
-		err = &errortypes.WriteError{
-			errors.Wrap(err, "qga: Failed to read from guest agent"),
-		}
 		return
 	}
+	defer conn.Close()
 
-	respByt := bytes.Trim(buff, "\x00")
-	respByt = bytes.TrimSpace(respByt)
-
-	ifaces = &Interfaces{}
-	err = json.Unmarshal(respByt, ifaces)
+	count, err = conn.FsfreezeThaw()
 	if err != nil {
-		err = &errortypes.ParseError{
-			errors.Wrap(err, "qga: Failed to parse guest agent response"),
-		}
 		return
 	}
 
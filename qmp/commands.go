@@ -0,0 +1,547 @@
+package qmp
+
+import (
+	"strconv"
+)
+
+type StatusInfo struct {
+	Running bool   `json:"running"`
+	Status  string `json:"status"`
+}
+
+type BlockInserted struct {
+	File     string `json:"file"`
+	NodeName string `json:"node-name"`
+	Driver   string `json:"drv"`
+}
+
+type BlockInfo struct {
+	Device    string         `json:"device"`
+	Qdev      string         `json:"qdev"`
+	Removable bool           `json:"removable"`
+	Inserted  *BlockInserted `json:"inserted"`
+}
+
+type BlockJobInfo struct {
+	Type   string `json:"type"`
+	Device string `json:"device"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+	Busy   bool   `json:"busy"`
+	Paused bool   `json:"paused"`
+	Ready  bool   `json:"ready"`
+}
+
+type MigrateStats struct {
+	Transferred int64 `json:"transferred"`
+	Remaining   int64 `json:"remaining"`
+	Total       int64 `json:"total"`
+}
+
+type MigrateInfo struct {
+	Status string        `json:"status"`
+	Ram    *MigrateStats `json:"ram"`
+	Disk   *MigrateStats `json:"disk"`
+}
+
+type HotpluggableCpu struct {
+	Type       string                 `json:"type"`
+	VcpusCount int                    `json:"vcpus-count"`
+	Props      map[string]interface{} `json:"props"`
+	QomPath    string                 `json:"qom-path"`
+}
+
+type MemorySizeSummary struct {
+	BaseMemory    int64 `json:"base-memory"`
+	PluggedMemory int64 `json:"plugged-memory"`
+}
+
+type BlockStats struct {
+	ReadBytes       int64 `json:"rd_bytes"`
+	WriteBytes      int64 `json:"wr_bytes"`
+	ReadOperations  int64 `json:"rd_operations"`
+	WriteOperations int64 `json:"wr_operations"`
+}
+
+type BlockStatsInfo struct {
+	Device   string      `json:"device"`
+	Qdev     string      `json:"qdev"`
+	NodeName string      `json:"node-name"`
+	Stats    *BlockStats `json:"stats"`
+}
+
+type JobInfo struct {
+	Id              string `json:"id"`
+	Type            string `json:"type"`
+	Status          string `json:"status"`
+	CurrentProgress int64  `json:"current-progress"`
+	TotalProgress   int64  `json:"total-progress"`
+	Error           string `json:"error"`
+}
+
+type ShutdownData struct {
+	Guest  bool   `json:"guest"`
+	Reason string `json:"reason"`
+}
+
+type BlockJobCompletedData struct {
+	Type   string `json:"type"`
+	Device string `json:"device"`
+	Len    int64  `json:"len"`
+	Offset int64  `json:"offset"`
+	Error  string `json:"error"`
+}
+
+type DeviceDeletedData struct {
+	Device string `json:"device"`
+	Path   string `json:"path"`
+}
+
+type blockdevFile struct {
+	Driver   string `json:"driver"`
+	Filename string `json:"filename"`
+}
+
+type blockdevAddArgs struct {
+	Driver   string        `json:"driver"`
+	NodeName string        `json:"node-name"`
+	Discard  string        `json:"discard,omitempty"`
+	File     *blockdevFile `json:"file"`
+}
+
+func (c *Connection) QueryStatus() (status *StatusInfo, err error) {
+	status = &StatusInfo{}
+
+	err = c.Command("query-status", nil, status)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryBlock() (blocks []*BlockInfo, err error) {
+	blocks = []*BlockInfo{}
+
+	err = c.Command("query-block", nil, &blocks)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryBlockJobs() (jobs []*BlockJobInfo, err error) {
+	jobs = []*BlockJobInfo{}
+
+	err = c.Command("query-block-jobs", nil, &jobs)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryHotpluggableCpus() (
+	cpus []*HotpluggableCpu, err error) {
+
+	cpus = []*HotpluggableCpu{}
+
+	err = c.Command("query-hotpluggable-cpus", nil, &cpus)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryMemorySizeSummary() (
+	summary *MemorySizeSummary, err error) {
+
+	summary = &MemorySizeSummary{}
+
+	err = c.Command("query-memory-size-summary", nil, summary)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryBlockstats() (stats []*BlockStatsInfo, err error) {
+	stats = []*BlockStatsInfo{}
+
+	err = c.Command("query-blockstats", nil, &stats)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryJobs() (jobs []*JobInfo, err error) {
+	jobs = []*JobInfo{}
+
+	err = c.Command("query-jobs", nil, &jobs)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) QueryMigrate() (info *MigrateInfo, err error) {
+	info = &MigrateInfo{}
+
+	err = c.Command("query-migrate", nil, info)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockdevAdd(nodeName, path, format string,
+	discard bool) (err error) {
+
+	args := &blockdevAddArgs{
+		Driver:   format,
+		NodeName: nodeName,
+		File: &blockdevFile{
+			Driver:   "file",
+			Filename: path,
+		},
+	}
+	if discard {
+		args.Discard = "unmap"
+	} else {
+		args.Discard = "ignore"
+	}
+
+	err = c.Command("blockdev-add", args, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockdevDel(nodeName string) (err error) {
+	err = c.Command("blockdev-del", map[string]interface{}{
+		"node-name": nodeName,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockdevBackup(jobId, device, target string) (
+	err error) {
+
+	err = c.Command("blockdev-backup", map[string]interface{}{
+		"job-id":       jobId,
+		"device":       device,
+		"target":       target,
+		"sync":         "full",
+		"auto-dismiss": false,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) JobCancel(id string) (err error) {
+	err = c.Command("job-cancel", map[string]interface{}{
+		"id": id,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) JobDismiss(id string) (err error) {
+	err = c.Command("job-dismiss", map[string]interface{}{
+		"id": id,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) DeviceAdd(driver, id string,
+	props map[string]interface{}) (err error) {
+
+	args := map[string]interface{}{}
+	for key, val := range props {
+		args[key] = val
+	}
+	args["driver"] = driver
+	args["id"] = id
+
+	err = c.Command("device_add", args, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) ObjectAdd(qomType, id string,
+	props map[string]interface{}) (err error) {
+
+	args := map[string]interface{}{}
+	for key, val := range props {
+		args[key] = val
+	}
+	args["qom-type"] = qomType
+	args["id"] = id
+
+	err = c.Command("object-add", args, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) ObjectDel(id string) (err error) {
+	err = c.Command("object-del", map[string]interface{}{
+		"id": id,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) DeviceDel(id string) (err error) {
+	err = c.Command("device_del", map[string]interface{}{
+		"id": id,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) SystemPowerdown() (err error) {
+	err = c.Command("system_powerdown", nil, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) SetPassword(protocol, passwd string) (err error) {
+	err = c.Command("set_password", map[string]interface{}{
+		"protocol": protocol,
+		"password": passwd,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) Migrate(uri string) (err error) {
+	err = c.Command("migrate", map[string]interface{}{
+		"uri": uri,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) MigrateIncoming(uri string) (err error) {
+	err = c.Command("migrate-incoming", map[string]interface{}{
+		"uri": uri,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) MigrateSetTlsCreds(tlsCreds string) (err error) {
+	err = c.Command("migrate-set-parameters", map[string]interface{}{
+		"tls-creds": tlsCreds,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) NbdServerStart(host string, port int,
+	tlsCreds string) (err error) {
+
+	err = c.Command("nbd-server-start", map[string]interface{}{
+		"addr": map[string]interface{}{
+			"type": "inet",
+			"data": map[string]interface{}{
+				"host": host,
+				"port": strconv.Itoa(port),
+			},
+		},
+		"tls-creds": tlsCreds,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) NbdServerStop() (err error) {
+	err = c.Command("nbd-server-stop", nil, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockExportAdd(id, nodeName string) (err error) {
+	err = c.Command("block-export-add", map[string]interface{}{
+		"type":      "nbd",
+		"id":        id,
+		"node-name": nodeName,
+		"name":      id,
+		"writable":  true,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockExportDel(id string) (err error) {
+	err = c.Command("block-export-del", map[string]interface{}{
+		"id": id,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockdevAddNbd(nodeName, host string, port int,
+	export, tlsCreds string) (err error) {
+
+	err = c.Command("blockdev-add", map[string]interface{}{
+		"driver":    "nbd",
+		"node-name": nodeName,
+		"server": map[string]interface{}{
+			"type": "inet",
+			"host": host,
+			"port": strconv.Itoa(port),
+		},
+		"export":    export,
+		"tls-creds": tlsCreds,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockdevMirror(jobId, device, target string) (
+	err error) {
+
+	err = c.Command("blockdev-mirror", map[string]interface{}{
+		"job-id": jobId,
+		"device": device,
+		"target": target,
+		"sync":   "full",
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) BlockJobCancel(device string, force bool) (err error) {
+	err = c.Command("block-job-cancel", map[string]interface{}{
+		"device": device,
+		"force":  force,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) MigrateCancel() (err error) {
+	err = c.Command("migrate_cancel", nil, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) ChardevChangeSocket(id, path, logfile string) (
+	err error) {
+
+	data := map[string]interface{}{
+		"addr": map[string]interface{}{
+			"type": "unix",
+			"data": map[string]interface{}{
+				"path": path,
+			},
+		},
+		"server": true,
+		"wait":   false,
+	}
+	if logfile != "" {
+		data["logfile"] = logfile
+		data["logappend"] = true
+	}
+
+	err = c.Command("chardev-change", map[string]interface{}{
+		"id": id,
+		"backend": map[string]interface{}{
+			"type": "socket",
+			"data": data,
+		},
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (c *Connection) HumanMonitorCommand(cmd string) (
+	output string, err error) {
+
+	err = c.Command("human-monitor-command", map[string]interface{}{
+		"command-line": cmd,
+	}, &output)
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,17 @@
+package qmp
+
+import (
+	"time"
+)
+
+const (
+	Shutdown          = "SHUTDOWN"
+	BlockJobCompleted = "BLOCK_JOB_COMPLETED"
+	BlockJobCancelled = "BLOCK_JOB_CANCELLED"
+	BlockJobReady     = "BLOCK_JOB_READY"
+	BlockJobError     = "BLOCK_JOB_ERROR"
+	DeviceDeleted     = "DEVICE_DELETED"
+
+	connectTimeout = 1 * time.Second
+	commandTimeout = 10 * time.Second
+)
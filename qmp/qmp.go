@@ -0,0 +1,381 @@
+package qmp
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+type command struct {
+	Execute   string      `json:"execute"`
+	Arguments interface{} `json:"arguments,omitempty"`
+	Id        int         `json:"id"`
+}
+
+type Error struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+type Timestamp struct {
+	Seconds      int64 `json:"seconds"`
+	Microseconds int64 `json:"microseconds"`
+}
+
+type Event struct {
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp *Timestamp      `json:"timestamp"`
+}
+
+func (e *Event) DecodeData(data interface{}) (err error) {
+	if len(e.Data) == 0 {
+		return
+	}
+
+	err = json.Unmarshal(e.Data, data)
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrapf(err, "qmp: Failed to parse '%s' event data",
+				e.Event),
+		}
+		return
+	}
+
+	return
+}
+
+type message struct {
+	Qmp       json.RawMessage `json:"QMP"`
+	Id        *int            `json:"id"`
+	Return    json.RawMessage `json:"return"`
+	Error     *Error          `json:"error"`
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp *Timestamp      `json:"timestamp"`
+}
+
+type Listener struct {
+	conn   *Connection
+	names  set.Set
+	Events chan *Event
+}
+
+func (l *Listener) Wait(timeout time.Duration, match func(*Event) bool) (
+	evt *Event, err error) {
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case e, ok := <-l.Events:
+			if !ok {
+				err = &errortypes.ReadError{
+					errors.New("qmp: Connection closed waiting for event"),
+				}
+				return
+			}
+
+			if match != nil && !match(e) {
+				continue
+			}
+
+			evt = e
+			return
+		case <-timer.C:
+			err = &errortypes.TimeoutError{
+				errors.New("qmp: Timeout waiting for event"),
+			}
+			return
+		}
+	}
+}
+
+func (l *Listener) Close() {
+	l.conn.lock.Lock()
+	defer l.conn.lock.Unlock()
+
+	if _, ok := l.conn.listeners[l]; ok {
+		delete(l.conn.listeners, l)
+		close(l.Events)
+	}
+}
+
+type Connection struct {
+	Timeout   time.Duration
+	sockPath  string
+	conn      net.Conn
+	lock      sync.Mutex
+	writeLock sync.Mutex
+	cmdId     int
+	waiters   map[int]chan *message
+	listeners map[*Listener]struct{}
+	err       error
+}
+
+func (c *Connection) Connect() (err error) {
+	conn, err := net.DialTimeout("unix", c.sockPath, connectTimeout)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qmp: Failed to open socket"),
+		}
+		return
+	}
+	c.conn = conn
+
+	err = conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qmp: Failed set deadline"),
+		}
+		conn.Close()
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qmp: Failed to read greeting"),
+		}
+		conn.Close()
+		return
+	}
+
+	greeting := &message{}
+	err = json.Unmarshal(line, greeting)
+	if err != nil || len(greeting.Qmp) == 0 {
+		err = &errortypes.ParseError{
+			errors.New("qmp: Invalid greeting from socket"),
+		}
+		conn.Close()
+		return
+	}
+
+	err = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "qmp: Failed clear deadline"),
+		}
+		conn.Close()
+		return
+	}
+
+	go c.reader(reader)
+
+	err = c.Command("qmp_capabilities", nil, nil)
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	return
+}
+
+func (c *Connection) Close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+}
+
+func (c *Connection) Listen(names ...string) (lstn *Listener) {
+	lstn = &Listener{
+		conn:   c,
+		names:  set.NewSet(),
+		Events: make(chan *Event, 32),
+	}
+
+	for _, name := range names {
+		lstn.names.Add(name)
+	}
+
+	c.lock.Lock()
+	if c.err != nil {
+		close(lstn.Events)
+	} else {
+		c.listeners[lstn] = struct{}{}
+	}
+	c.lock.Unlock()
+
+	return
+}
+
+func (c *Connection) Command(execute string, args interface{},
+	ret interface{}) (err error) {
+
+	waiter := make(chan *message, 1)
+
+	c.lock.Lock()
+	if c.err != nil {
+		err = c.err
+		c.lock.Unlock()
+		return
+	}
+	c.cmdId += 1
+	id := c.cmdId
+	c.waiters[id] = waiter
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.waiters, id)
+		c.lock.Unlock()
+	}()
+
+	data, err := json.Marshal(&command{
+		Execute:   execute,
+		Arguments: args,
+		Id:        id,
+	})
+	if err != nil {
+		err = &errortypes.ParseError{
+			errors.Wrap(err, "qmp: Failed to marshal command"),
+		}
+		return
+	}
+
+	c.writeLock.Lock()
+	err = c.conn.SetWriteDeadline(time.Now().Add(c.Timeout))
+	if err == nil {
+		_, err = c.conn.Write(append(data, '\n'))
+	}
+	c.writeLock.Unlock()
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "qmp: Failed to write socket"),
+		}
+		return
+	}
+
+	timer := time.NewTimer(c.Timeout)
+	defer timer.Stop()
+
+	select {
+	case msg, ok := <-waiter:
+		if !ok {
+			c.lock.Lock()
+			err = c.err
+			c.lock.Unlock()
+			return
+		}
+
+		if msg.Error != nil {
+			err = &errortypes.ExecError{
+				errors.Newf("qmp: Command '%s' failed (%s) '%s'",
+					execute, msg.Error.Class, msg.Error.Desc),
+			}
+			return
+		}
+
+		if ret != nil && len(msg.Return) != 0 {
+			err = json.Unmarshal(msg.Return, ret)
+			if err != nil {
+				err = &errortypes.ParseError{
+					errors.Wrapf(err,
+						"qmp: Failed to parse '%s' response", execute),
+				}
+				return
+			}
+		}
+	case <-timer.C:
+		err = &errortypes.TimeoutError{
+			errors.Newf("qmp: Command '%s' timed out", execute),
+		}
+		return
+	}
+
+	return
+}
+
+func (c *Connection) reader(reader *bufio.Reader) {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			c.shutdown(&errortypes.ReadError{
+				errors.Wrap(err, "qmp: Failed to read socket"),
+			})
+			return
+		}
+
+		msg := &message{}
+		err = json.Unmarshal(line, msg)
+		if err != nil {
+			continue
+		}
+
+		if msg.Event != "" {
+			c.dispatch(&Event{
+				Event:     msg.Event,
+				Data:      msg.Data,
+				Timestamp: msg.Timestamp,
+			})
+			continue
+		}
+
+		if msg.Id == nil {
+			continue
+		}
+
+		c.lock.Lock()
+		waiter := c.waiters[*msg.Id]
+		delete(c.waiters, *msg.Id)
+		c.lock.Unlock()
+
+		if waiter != nil {
+			waiter <- msg
+		}
+	}
+}
+
+func (c *Connection) dispatch(evt *Event) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for lstn := range c.listeners {
+		if lstn.names.Len() != 0 && !lstn.names.Contains(evt.Event) {
+			continue
+		}
+
+		select {
+		case lstn.Events <- evt:
+		default:
+		}
+	}
+}
+
+func (c *Connection) shutdown(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = err
+
+	for id, waiter := range c.waiters {
+		delete(c.waiters, id)
+		close(waiter)
+	}
+
+	for lstn := range c.listeners {
+		delete(c.listeners, lstn)
+		close(lstn.Events)
+	}
+}
+
+func NewConnection(sockPath string) *Connection {
+	return &Connection{
+		Timeout:   commandTimeout,
+		sockPath:  sockPath,
+		waiters:   map[int]chan *message{},
+		listeners: map[*Listener]struct{}{},
+	}
+}
@@ -0,0 +1,226 @@
+package qmp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pritunl/pritunl-cloud/errortypes"
+)
+
+func drain(lstn *Listener) (names []string) {
+	for {
+		select {
+		case evt := <-lstn.Events:
+			names = append(names, evt.Event)
+		default:
+			return
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		events []string
+		want   []string
+	}{
+		{
+			name:   "all events",
+			names:  nil,
+			events: []string{Shutdown, BlockJobCompleted, DeviceDeleted},
+			want:   []string{Shutdown, BlockJobCompleted, DeviceDeleted},
+		},
+		{
+			name:   "single event",
+			names:  []string{Shutdown},
+			events: []string{BlockJobCompleted, Shutdown, DeviceDeleted},
+			want:   []string{Shutdown},
+		},
+		{
+			name:  "block job events",
+			names: []string{BlockJobCompleted, BlockJobCancelled, BlockJobError},
+			events: []string{
+				BlockJobReady,
+				BlockJobError,
+				Shutdown,
+				BlockJobCompleted,
+			},
+			want: []string{BlockJobError, BlockJobCompleted},
+		},
+		{
+			name:   "no match",
+			names:  []string{DeviceDeleted},
+			events: []string{Shutdown, BlockJobReady},
+			want:   nil,
+		},
+	}
+
+	for _, test := range tests {
+		conn := NewConnection("")
+		lstn := conn.Listen(test.names...)
+
+		for _, name := range test.events {
+			conn.dispatch(&Event{
+				Event: name,
+			})
+		}
+
+		got := drain(lstn)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: events %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: events %v, want %v",
+					test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDispatchMultiple(t *testing.T) {
+	conn := NewConnection("")
+	all := conn.Listen()
+	shutdown := conn.Listen(Shutdown)
+	deleted := conn.Listen(DeviceDeleted)
+
+	conn.dispatch(&Event{
+		Event: Shutdown,
+	})
+
+	if n := len(drain(all)); n != 1 {
+		t.Errorf("all listener received %d events, want 1", n)
+	}
+	if n := len(drain(shutdown)); n != 1 {
+		t.Errorf("shutdown listener received %d events, want 1", n)
+	}
+	if n := len(drain(deleted)); n != 0 {
+		t.Errorf("deleted listener received %d events, want 0", n)
+	}
+}
+
+func TestDispatchFull(t *testing.T) {
+	conn := NewConnection("")
+	lstn := conn.Listen()
+	size := cap(lstn.Events)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < size*2; i++ {
+			conn.dispatch(&Event{
+				Event: Shutdown,
+			})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dispatch blocked on full listener")
+	}
+
+	if n := len(drain(lstn)); n != size {
+		t.Errorf("listener received %d events, want %d", n, size)
+	}
+}
+
+func TestListenerWait(t *testing.T) {
+	conn := NewConnection("")
+	lstn := conn.Listen(DeviceDeleted)
+
+	conn.dispatch(&Event{
+		Event: DeviceDeleted,
+		Data:  json.RawMessage(`{"device":"virtio0"}`),
+	})
+	conn.dispatch(&Event{
+		Event: DeviceDeleted,
+		Data:  json.RawMessage(`{"device":"virtio1"}`),
+	})
+
+	evt, err := lstn.Wait(time.Second, func(evt *Event) bool {
+		data := &struct {
+			Device string `json:"device"`
+		}{}
+
+		err := evt.DecodeData(data)
+		if err != nil {
+			t.Error(err)
+			return false
+		}
+
+		return data.Device == "virtio1"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(evt.Data) != `{"device":"virtio1"}` {
+		t.Errorf("unexpected event data %s", evt.Data)
+	}
+
+	if n := len(drain(lstn)); n != 0 {
+		t.Errorf("skipped events not consumed, %d remaining", n)
+	}
+}
+
+func TestListenerWaitTimeout(t *testing.T) {
+	conn := NewConnection("")
+	lstn := conn.Listen(Shutdown)
+
+	conn.dispatch(&Event{
+		Event: Shutdown,
+	})
+
+	_, err := lstn.Wait(50*time.Millisecond, func(evt *Event) bool {
+		return false
+	})
+	if _, ok := err.(*errortypes.TimeoutError); !ok {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+}
+
+func TestListenerShutdown(t *testing.T) {
+	conn := NewConnection("")
+	lstn := conn.Listen(Shutdown)
+
+	go conn.shutdown(&errortypes.ReadError{})
+
+	_, err := lstn.Wait(5*time.Second, nil)
+	if _, ok := err.(*errortypes.ReadError); !ok {
+		t.Errorf("expected read error, got %v", err)
+	}
+
+	lstn = conn.Listen(Shutdown)
+	_, err = lstn.Wait(5*time.Second, nil)
+	if _, ok := err.(*errortypes.ReadError); !ok {
+		t.Errorf("expected read error after shutdown, got %v", err)
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	conn := NewConnection("")
+	lstn := conn.Listen()
+
+	lstn.Close()
+	lstn.Close()
+
+	conn.dispatch(&Event{
+		Event: Shutdown,
+	})
+
+	if len(conn.listeners) != 0 {
+		t.Errorf("listener not removed")
+	}
+
+	_, err := lstn.Wait(time.Second, nil)
+	if _, ok := err.(*errortypes.ReadError); !ok {
+		t.Errorf("expected read error, got %v", err)
+	}
+
+	conn.shutdown(&errortypes.ReadError{})
+}
@@ -0,0 +1,195 @@
+package qms
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/qmp"
+)
+
+const (
+	JobConcluded = "concluded"
+)
+
+func IsRunning(vmId primitive.ObjectID) (running bool, err error) {
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	status, err := conn.QueryStatus()
+	if err != nil {
+		return
+	}
+
+	running = status.Running
+	return
+}
+
+// Start a full backup of the disk into the pre-created qcow2 target, the
+// target is attached as a block node named after the job
+func StartBackup(vmId primitive.ObjectID, index int, outPath string) (
+	jobId string, err error) {
+
+	jobId = fmt.Sprintf("backup-%s", primitive.NewObjectID().Hex())
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"index":       index,
+		"out_path":    outPath,
+	}).Info("qemu: Starting virtual machine disk backup")
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	err = conn.BlockdevAdd(jobId, outPath, "qcow2", false)
+	if err != nil {
+		return
+	}
+
+	err = conn.BlockdevBackup(jobId, fmt.Sprintf("virtio%d", index), jobId)
+	if err != nil {
+		_ = conn.BlockdevDel(jobId)
+		return
+	}
+
+	return
+}
+
+// Wait for the backup job to conclude, the job state is checked after the
+// listener is registered to catch jobs that concluded before the wait.
+// Jobs that exceed the timeout are cancelled.
+func WaitBackup(vmId primitive.ObjectID, jobId string,
+	timeout time.Duration) (err error) {
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	lstn := conn.Listen(qmp.BlockJobCompleted, qmp.BlockJobCancelled,
+		qmp.BlockJobError)
+	defer lstn.Close()
+
+	defer func() {
+		e := conn.BlockdevDel(jobId)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": vmId.Hex(),
+				"job":         jobId,
+				"error":       e,
+			}).Warn("qemu: Failed to remove disk backup target")
+		}
+	}()
+
+	match := func(evt *qmp.Event) bool {
+		data := &qmp.BlockJobCompletedData{}
+		e := evt.DecodeData(data)
+		return e == nil && data.Device == jobId
+	}
+
+	deadline := time.Now().Add(timeout)
+	cancelled := false
+	for {
+		job, e := getJob(conn, jobId)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if job.Status == JobConcluded {
+			err = conn.JobDismiss(jobId)
+			if err != nil {
+				return
+			}
+
+			if cancelled {
+				err = &errortypes.TimeoutError{
+					errors.New("qemu: Disk backup job timed out"),
+				}
+				return
+			}
+
+			if job.Error != "" {
+				err = &errortypes.ExecError{
+					errors.Newf("qemu: Disk backup job failed '%s'",
+						job.Error),
+				}
+				return
+			}
+
+			return
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if cancelled {
+				err = &errortypes.TimeoutError{
+					errors.New("qemu: Disk backup job cancel timed out"),
+				}
+				return
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"instance_id": vmId.Hex(),
+				"job":         jobId,
+			}).Error("qemu: Disk backup job timed out, cancelling")
+
+			err = conn.JobCancel(jobId)
+			if err != nil {
+				return
+			}
+
+			cancelled = true
+			deadline = time.Now().Add(30 * time.Second)
+			continue
+		}
+
+		_, e = lstn.Wait(remaining, match)
+		if e != nil {
+			if _, ok := e.(*errortypes.TimeoutError); !ok {
+				err = e
+				return
+			}
+		}
+	}
+}
+
+func getJob(conn *qmp.Connection, jobId string) (
+	job *qmp.JobInfo, err error) {
+
+	jobs, err := conn.QueryJobs()
+	if err != nil {
+		return
+	}
+
+	for _, jb := range jobs {
+		if jb.Id == jobId {
+			job = jb
+			return
+		}
+	}
+
+	err = &errortypes.NotFoundError{
+		errors.New("qemu: Disk backup job not found"),
+	}
+	return
+}
@@ -0,0 +1,419 @@
+package qms
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/qmp"
+)
+
+const (
+	MigrateNone      = "none"
+	MigrateSetup     = "setup"
+	MigrateActive    = "active"
+	MigrateCompleted = "completed"
+	MigrateFailed    = "failed"
+	MigrateCancelled = "cancelled"
+)
+
+type MigrateStatus struct {
+	Status    string
+	Remaining int64
+	Total     int64
+}
+
+func (m *MigrateStatus) Progress() int {
+	if m.Status == MigrateCompleted {
+		return 100
+	}
+
+	if m.Total <= 0 {
+		return 0
+	}
+
+	progress := int((m.Total - m.Remaining) * 100 / m.Total)
+	if progress < 0 {
+		progress = 0
+	} else if progress > 99 {
+		progress = 99
+	}
+
+	return progress
+}
+
+const (
+	migrateTlsId = "migrate_tls"
+)
+
+func getMigrateNode(device string) string {
+	return "migrate_" + device
+}
+
+func formatMigrateHost(addr string) string {
+	if strings.Contains(addr, ":") {
+		return "[" + addr + "]"
+	}
+	return addr
+}
+
+func connectRetry(vmId primitive.ObjectID) (
+	conn *qmp.Connection, err error) {
+
+	for i := 0; i < 20; i++ {
+		conn, err = connect(vmId)
+		if err == nil {
+			return
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return
+}
+
+// Prepare deferred incoming migration on the destination, disks are
+// exported over TLS NBD to receive the source block mirrors
+func MigrateIncoming(vmId primitive.ObjectID, addr string, port,
+	nbdPort int, tlsPath string, devices []string) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"address":     addr,
+		"port":        port,
+		"nbd_port":    nbdPort,
+	}).Info("qemu: Preparing incoming virtual machine migration")
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connectRetry(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	err = conn.ObjectAdd("tls-creds-psk", migrateTlsId,
+		map[string]interface{}{
+			"endpoint": "server",
+			"dir":      tlsPath,
+		})
+	if err != nil {
+		return
+	}
+
+	err = conn.MigrateSetTlsCreds(migrateTlsId)
+	if err != nil {
+		return
+	}
+
+	blocks, err := conn.QueryBlock()
+	if err != nil {
+		return
+	}
+
+	nodes := map[string]string{}
+	for _, blk := range blocks {
+		if blk.Inserted == nil {
+			continue
+		}
+		nodes[getDeviceName(blk)] = blk.Inserted.NodeName
+	}
+
+	err = conn.NbdServerStart(addr, nbdPort, migrateTlsId)
+	if err != nil {
+		return
+	}
+
+	for _, device := range devices {
+		nodeName := nodes[device]
+		if nodeName == "" {
+			err = &errortypes.NotFoundError{
+				errors.Newf("qemu: Missing migration disk '%s'", device),
+			}
+			return
+		}
+
+		err = conn.BlockExportAdd(device, nodeName)
+		if err != nil {
+			return
+		}
+	}
+
+	err = conn.MigrateIncoming(fmt.Sprintf(
+		"tcp:%s:%d", formatMigrateHost(addr), port))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Stop the NBD exports after the incoming migration has completed
+func MigrateIncomingComplete(vmId primitive.ObjectID, devices []string) (
+	err error) {
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	for _, device := range devices {
+		err = conn.BlockExportDel(device)
+		if err != nil {
+			return
+		}
+	}
+
+	err = conn.NbdServerStop()
+	if err != nil {
+		return
+	}
+
+	_ = conn.ObjectDel(migrateTlsId)
+
+	return
+}
+
+// Mirror disks to the destination NBD exports and start the memory
+// migration once all mirrors are synchronized
+func Migrate(vmId primitive.ObjectID, addr string, port, nbdPort int,
+	tlsPath string, devices []string, timeout time.Duration) (err error) {
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"address":     addr,
+		"port":        port,
+		"nbd_port":    nbdPort,
+	}).Info("qemu: Starting virtual machine migration")
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	err = conn.ObjectAdd("tls-creds-psk", migrateTlsId,
+		map[string]interface{}{
+			"endpoint": "client",
+			"dir":      tlsPath,
+		})
+	if err != nil {
+		return
+	}
+
+	lstn := conn.Listen(qmp.BlockJobReady, qmp.BlockJobError,
+		qmp.BlockJobCompleted, qmp.BlockJobCancelled)
+	defer lstn.Close()
+
+	pending := map[string]bool{}
+	for _, device := range devices {
+		nodeName := getMigrateNode(device)
+
+		err = conn.BlockdevAddNbd(nodeName, addr, nbdPort,
+			device, migrateTlsId)
+		if err != nil {
+			return
+		}
+
+		err = conn.BlockdevMirror(nodeName, device, nodeName)
+		if err != nil {
+			return
+		}
+
+		pending[nodeName] = true
+	}
+
+	deadline := time.Now().Add(timeout)
+	for len(pending) > 0 {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			err = &errortypes.TimeoutError{
+				errors.New("qemu: Timeout waiting for disk mirror"),
+			}
+			return
+		}
+
+		evt, e := lstn.Wait(remaining, nil)
+		if e != nil {
+			err = e
+			return
+		}
+
+		data := &qmp.BlockJobCompletedData{}
+		err = evt.DecodeData(data)
+		if err != nil {
+			return
+		}
+
+		if !pending[data.Device] {
+			continue
+		}
+
+		if evt.Event != qmp.BlockJobReady {
+			err = &errortypes.ExecError{
+				errors.Newf("qemu: Disk mirror '%s' failed '%s'",
+					data.Device, data.Error),
+			}
+			return
+		}
+
+		delete(pending, data.Device)
+	}
+
+	err = conn.MigrateSetTlsCreds(migrateTlsId)
+	if err != nil {
+		return
+	}
+
+	err = conn.Migrate(fmt.Sprintf(
+		"tcp:%s:%d", formatMigrateHost(addr), port))
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+// Release the disk mirrors after the migration has stopped, completed
+// mirrors are synchronized before release unless the migration failed
+func MigrateRelease(vmId primitive.ObjectID, devices []string,
+	force bool) (err error) {
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	lstn := conn.Listen(qmp.BlockJobCompleted, qmp.BlockJobCancelled)
+	defer lstn.Close()
+
+	jobs, err := conn.QueryBlockJobs()
+	if err != nil {
+		return
+	}
+
+	pending := map[string]bool{}
+	for _, job := range jobs {
+		pending[job.Device] = true
+	}
+
+	for _, device := range devices {
+		nodeName := getMigrateNode(device)
+		if !pending[nodeName] {
+			continue
+		}
+
+		e := conn.BlockJobCancel(nodeName, force)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": vmId.Hex(),
+				"job":         nodeName,
+				"error":       e,
+			}).Warn("qemu: Failed to cancel disk mirror")
+			delete(pending, nodeName)
+		}
+	}
+
+	deadline := time.Now().Add(30 * time.Second)
+	for _, device := range devices {
+		nodeName := getMigrateNode(device)
+
+		for pending[nodeName] {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				break
+			}
+
+			evt, e := lstn.Wait(remaining, nil)
+			if e != nil {
+				break
+			}
+
+			data := &qmp.BlockJobCompletedData{}
+			if evt.DecodeData(data) == nil {
+				delete(pending, data.Device)
+			}
+		}
+
+		_ = conn.BlockdevDel(nodeName)
+	}
+
+	_ = conn.ObjectDel(migrateTlsId)
+
+	return
+}
+
+func MigrateCancel(vmId primitive.ObjectID) (err error) {
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+	}).Info("qemu: Cancelling virtual machine migration")
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	err = conn.MigrateCancel()
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func GetMigrateStatus(vmId primitive.ObjectID) (
+	status *MigrateStatus, err error) {
+
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	info, err := conn.QueryMigrate()
+	if err != nil {
+		return
+	}
+
+	status = &MigrateStatus{
+		Status: info.Status,
+	}
+
+	if status.Status == "" {
+		status.Status = MigrateNone
+	}
+
+	for _, stats := range []*qmp.MigrateStats{info.Ram, info.Disk} {
+		if stats == nil {
+			continue
+		}
+
+		status.Remaining += stats.Remaining
+		status.Total += stats.Total
+	}
+
+	return
+}
@@ -1,17 +1,15 @@
 package qms
 
 import (
-	"bytes"
 	"fmt"
-	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/dropbox/godropbox/errors"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
 	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/qmp"
 	"github.com/pritunl/pritunl-cloud/utils"
 	"github.com/pritunl/pritunl-cloud/vm"
 )
@@ -20,106 +18,61 @@ var (
 	socketsLock = utils.NewMultiTimeoutLock(1 * time.Minute)
 )
 
-func GetDisks(vmId primitive.ObjectID) (disks []*vm.Disk, err error) {
-	sockPath := GetSockPath(vmId)
-	disks = []*vm.Disk{}
+func connect(vmId primitive.ObjectID) (conn *qmp.Connection, err error) {
+	conn = qmp.NewConnection(GetQmpSockPath(vmId))
 
-	lockId := socketsLock.Lock(vmId.Hex())
-	defer socketsLock.Unlock(vmId.Hex(), lockId)
-
-	conn, err := net.DialTimeout(
-		"unix",
-		sockPath,
-		1*time.Second,
-	)
+	err = conn.Connect()
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to open socket"),
-		}
 		return
 	}
-	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(3 * time.Second))
-	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed set deadline"),
-		}
-		return
+	return
+}
+
+func getDeviceName(blk *qmp.BlockInfo) string {
+	if blk.Device != "" {
+		return blk.Device
 	}
 
-	buffer := make([]byte, 100000)
-	for {
-		buf := make([]byte, 10000)
-		n, e := conn.Read(buf)
-		if e != nil {
-			err = &errortypes.ReadError{
-				errors.Wrap(e, "qemu: Failed to read socket"),
-			}
-			return
-		}
-		buffer = append(buffer, buf[:n]...)
+	qdev := strings.TrimPrefix(blk.Qdev, "/machine/peripheral/")
+	return strings.SplitN(qdev, "/", 2)[0]
+}
 
-		if bytes.Contains(bytes.TrimSpace(buffer), []byte("(qemu)")) {
-			break
-		}
-	}
+func GetDisks(vmId primitive.ObjectID) (disks []*vm.Disk, err error) {
+	disks = []*vm.Disk{}
 
-	_, err = conn.Write([]byte("info block\n"))
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to write socket"),
-		}
 		return
 	}
+	defer conn.Close()
 
-	buffer = make([]byte, 100000)
-	for {
-		buf := make([]byte, 10000)
-		n, e := conn.Read(buf)
-		if e != nil {
-			err = &errortypes.ReadError{
-				errors.Wrap(e, "qemu: Failed to read socket"),
-			}
-			return
-		}
-		buffer = append(buffer, buf[:n]...)
-
-		if bytes.Contains(bytes.TrimSpace(buffer), []byte("(qemu)")) {
-			break
-		}
+	blocks, err := conn.QueryBlock()
+	if err != nil {
+		return
 	}
 
-	for _, line := range strings.Split(string(buffer), "\n") {
-		if !strings.HasPrefix(line, "virtio") || len(line) < 10 {
-			continue
-		}
-		line = strings.Replace(line, "\r", "", -1)
-
-		lineSpl := strings.SplitN(line[6:], ":", 2)
-		if len(lineSpl) != 2 {
-			logrus.WithFields(logrus.Fields{
-				"instance_id": vmId.Hex(),
-				"line":        line,
-			}).Error("qemu: Unexpected qemu disk path")
+	for _, blk := range blocks {
+		name := getDeviceName(blk)
+		if !strings.HasPrefix(name, "virtio") || blk.Inserted == nil {
 			continue
 		}
 
-		indexStr := strings.Fields(strings.TrimSpace(lineSpl[0]))[0]
-		index, e := strconv.Atoi(indexStr)
+		index, e := strconv.Atoi(name[6:])
 		if e != nil {
 			logrus.WithFields(logrus.Fields{
 				"instance_id": vmId.Hex(),
-				"line":        line,
-			}).Error("qemu: Unexpected qemu disk path index")
+				"device":      name,
+			}).Error("qemu: Unexpected qemu disk device index")
 			continue
 		}
 
-		diskPath := strings.Fields(strings.TrimSpace(lineSpl[1]))[0]
-
 		dsk := &vm.Disk{
 			Index: index,
-			Path:  diskPath,
+			Path:  blk.Inserted.File,
 		}
 		disks = append(disks, dsk)
 	}
@@ -128,7 +81,7 @@ func GetDisks(vmId primitive.ObjectID) (disks []*vm.Disk, err error) {
 }
 
 func AddDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
-	sockPath := GetSockPath(vmId)
+	devId := fmt.Sprintf("virtio%d", dsk.Index)
 
 	logrus.WithFields(logrus.Fields{
 		"instance_id": vmId.Hex(),
@@ -138,48 +91,30 @@ func AddDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 	lockId := socketsLock.Lock(vmId.Hex())
 	defer socketsLock.Unlock(vmId.Hex(), lockId)
 
-	conn, err := net.DialTimeout(
-		"unix",
-		sockPath,
-		1*time.Second,
-	)
+	conn, err := connect(vmId)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to open socket"),
-		}
 		return
 	}
 	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	err = conn.BlockdevAdd(devId, dsk.Path, "qcow2", true)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed set deadline"),
-		}
 		return
 	}
 
-	drive := fmt.Sprintf(
-		"file=%s,index=%d,media=disk,format=qcow2,discard=on,if=virtio\n",
-		dsk.Path,
-		dsk.Index,
-	)
-
-	_, err = conn.Write([]byte("drive_add virtio " + drive))
+	err = conn.DeviceAdd("virtio-blk-pci", devId, map[string]interface{}{
+		"drive": devId,
+	})
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to write socket"),
-		}
+		_ = conn.BlockdevDel(devId)
 		return
 	}
 
-	time.Sleep(1 * time.Second)
-
 	return
 }
 
 func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
-	sockPath := GetSockPath(vmId)
+	devId := fmt.Sprintf("virtio%d", dsk.Index)
 
 	logrus.WithFields(logrus.Fields{
 		"instance_id": vmId.Hex(),
@@ -189,118 +124,185 @@ func RemoveDisk(vmId primitive.ObjectID, dsk *vm.Disk) (err error) {
 	lockId := socketsLock.Lock(vmId.Hex())
 	defer socketsLock.Unlock(vmId.Hex(), lockId)
 
-	conn, err := net.DialTimeout(
-		"unix",
-		sockPath,
-		1*time.Second,
-	)
+	conn, err := connect(vmId)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to open socket"),
-		}
 		return
 	}
 	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	blocks, err := conn.QueryBlock()
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed set deadline"),
+		return
+	}
+
+	found := false
+	hotplug := false
+	for _, blk := range blocks {
+		if getDeviceName(blk) == devId {
+			found = true
+			hotplug = blk.Device == ""
+			break
 		}
+	}
+
+	if !found {
 		return
 	}
 
-	_, err = conn.Write([]byte(
-		fmt.Sprintf("drive_del virtio%d\n", dsk.Index)))
-	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to write socket"),
+	// Disks attached from the command line have anonymous devices
+	// that cannot be unplugged, only the drive can be deleted
+	if !hotplug {
+		_, err = conn.HumanMonitorCommand("drive_del " + devId)
+		if err != nil {
+			return
 		}
+
+		return
+	}
+
+	lstn := conn.Listen(qmp.DeviceDeleted)
+	defer lstn.Close()
+
+	err = conn.DeviceDel(devId)
+	if err != nil {
 		return
 	}
 
-	time.Sleep(1 * time.Second)
+	_, err = lstn.Wait(10*time.Second, func(evt *qmp.Event) bool {
+		data := &qmp.DeviceDeletedData{}
+		e := evt.DecodeData(data)
+		return e == nil && data.Device == devId
+	})
+	if err != nil {
+		return
+	}
+
+	err = conn.BlockdevDel(devId)
+	if err != nil {
+		return
+	}
 
 	return
 }
 
-func Shutdown(vmId primitive.ObjectID) (err error) {
-	sockPath := GetSockPath(vmId)
+// Request a guest powerdown and wait for the shutdown event, the request is
+// resent periodically for guests that miss the first acpi event
+func Shutdown(vmId primitive.ObjectID, timeout time.Duration) (
+	shutdown bool, err error) {
 
 	lockId := socketsLock.Lock(vmId.Hex())
 	defer socketsLock.Unlock(vmId.Hex(), lockId)
 
-	conn, err := net.DialTimeout(
-		"unix",
-		sockPath,
-		1*time.Second,
-	)
+	conn, err := connect(vmId)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to open socket"),
-		}
 		return
 	}
 	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	lstn := conn.Listen(qmp.Shutdown)
+	defer lstn.Close()
+
+	err = conn.SystemPowerdown()
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed set deadline"),
-		}
 		return
 	}
 
-	_, err = conn.Write([]byte("system_powerdown\n"))
-	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to write socket"),
+	deadline := time.Now().Add(timeout)
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return
+		}
+		if remaining > 15*time.Second {
+			remaining = 15 * time.Second
 		}
-		return
-	}
 
-	time.Sleep(800 * time.Millisecond)
+		_, e := lstn.Wait(remaining, nil)
+		if e == nil {
+			shutdown = true
+			return
+		}
 
-	return
+		// Connection is closed when the qemu process exits
+		if _, ok := e.(*errortypes.TimeoutError); !ok {
+			shutdown = true
+			return
+		}
+
+		_ = conn.SystemPowerdown()
+	}
 }
 
-func VncPassword(vmId primitive.ObjectID, passwd string) (err error) {
-	sockPath := GetSockPath(vmId)
+// Replace the serial chardev to reopen the serial log file, the socket
+// is moved to a temporary path first as qemu removes the socket path of
+// the replaced chardev. Connected console clients are dropped on every
+// rotation and must reconnect.
+func ReopenSerialLog(vmId primitive.ObjectID, sockPath, logPath string) (
+	err error) {
 
 	lockId := socketsLock.Lock(vmId.Hex())
 	defer socketsLock.Unlock(vmId.Hex(), lockId)
 
-	conn, err := net.DialTimeout(
-		"unix",
-		sockPath,
-		1*time.Second,
-	)
+	conn, err := connect(vmId)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to open socket"),
-		}
 		return
 	}
 	defer conn.Close()
 
-	err = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	err = conn.ChardevChangeSocket("serial0", sockPath+".tmp", logPath)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed set deadline"),
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			time.Sleep(500 * time.Millisecond)
 		}
+
+		err = conn.ChardevChangeSocket("serial0", sockPath, logPath)
+		if err == nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id": vmId.Hex(),
+			"error":       err,
+		}).Warn("qemu: Failed to restore serial socket, retrying")
+	}
+
+	// Restore the socket without the log to keep the console reachable
+	e := conn.ChardevChangeSocket("serial0", sockPath, "")
+	if e != nil {
+		logrus.WithFields(logrus.Fields{
+			"instance_id": vmId.Hex(),
+			"error":       e,
+		}).Error("qemu: Failed to restore serial socket, " +
+			"console unavailable until restart")
 		return
 	}
 
-	_, err = conn.Write(
-		[]byte(fmt.Sprintf("change vnc password\n%s\n", passwd)))
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"error":       err,
+	}).Error("qemu: Serial log disabled after failed rotation")
+
+	return
+}
+
+func VncPassword(vmId primitive.ObjectID, passwd string) (err error) {
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
 	if err != nil {
-		err = &errortypes.ReadError{
-			errors.Wrap(err, "qemu: Failed to write socket"),
-		}
 		return
 	}
+	defer conn.Close()
 
-	time.Sleep(800 * time.Millisecond)
+	err = conn.SetPassword("vnc", passwd)
+	if err != nil {
+		return
+	}
 
 	return
 }
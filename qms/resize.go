@@ -0,0 +1,133 @@
+package qms
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/qmp"
+)
+
+func getSocketId(cpu *qmp.HotpluggableCpu) int {
+	socketId, ok := cpu.Props["socket-id"].(float64)
+	if !ok {
+		return 0
+	}
+	return int(socketId)
+}
+
+func SetProcessors(vmId primitive.ObjectID, processors int) (err error) {
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	cpus, err := conn.QueryHotpluggableCpus()
+	if err != nil {
+		return
+	}
+
+	current := 0
+	available := []*qmp.HotpluggableCpu{}
+	for _, cpu := range cpus {
+		if cpu.QomPath != "" {
+			current += cpu.VcpusCount
+		} else {
+			available = append(available, cpu)
+		}
+	}
+
+	if processors <= current {
+		return
+	}
+
+	sort.Slice(available, func(i, j int) bool {
+		return getSocketId(available[i]) < getSocketId(available[j])
+	})
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"current":     current,
+		"processors":  processors,
+	}).Info("qemu: Adding virtual machine processors")
+
+	for _, cpu := range available {
+		if current >= processors {
+			break
+		}
+
+		err = conn.DeviceAdd(cpu.Type,
+			fmt.Sprintf("cpu%d", getSocketId(cpu)), cpu.Props)
+		if err != nil {
+			return
+		}
+
+		current += cpu.VcpusCount
+	}
+
+	if current < processors {
+		err = &errortypes.ExecError{
+			errors.New("qemu: Not enough hotpluggable processors"),
+		}
+		return
+	}
+
+	return
+}
+
+func SetMemory(vmId primitive.ObjectID, memory int) (err error) {
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	summary, err := conn.QueryMemorySizeSummary()
+	if err != nil {
+		return
+	}
+
+	current := int((summary.BaseMemory + summary.PluggedMemory) /
+		1024 / 1024)
+	if memory <= current {
+		return
+	}
+
+	size := memory - current
+	dimmId := fmt.Sprintf("dimm%d", summary.PluggedMemory/1024/1024)
+	memId := fmt.Sprintf("mem%d", summary.PluggedMemory/1024/1024)
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id": vmId.Hex(),
+		"current":     current,
+		"memory":      memory,
+	}).Info("qemu: Adding virtual machine memory")
+
+	err = conn.ObjectAdd("memory-backend-ram", memId,
+		map[string]interface{}{
+			"size": int64(size) * 1024 * 1024,
+		})
+	if err != nil {
+		return
+	}
+
+	err = conn.DeviceAdd("pc-dimm", dimmId, map[string]interface{}{
+		"memdev": memId,
+	})
+	if err != nil {
+		_ = conn.ObjectDel(memId)
+		return
+	}
+
+	return
+}
@@ -0,0 +1,42 @@
+package qms
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+type DiskStats struct {
+	ReadBytes  int64
+	WriteBytes int64
+	ReadOps    int64
+	WriteOps   int64
+}
+
+func GetDiskStats(vmId primitive.ObjectID) (stats *DiskStats, err error) {
+	lockId := socketsLock.Lock(vmId.Hex())
+	defer socketsLock.Unlock(vmId.Hex(), lockId)
+
+	conn, err := connect(vmId)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	blocks, err := conn.QueryBlockstats()
+	if err != nil {
+		return
+	}
+
+	stats = &DiskStats{}
+	for _, blk := range blocks {
+		if blk.Stats == nil {
+			continue
+		}
+
+		stats.ReadBytes += blk.Stats.ReadBytes
+		stats.WriteBytes += blk.Stats.WriteBytes
+		stats.ReadOps += blk.Stats.ReadOperations
+		stats.WriteOps += blk.Stats.WriteOperations
+	}
+
+	return
+}
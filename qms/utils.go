@@ -8,7 +8,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/settings"
 )
 
-func GetSockPath(virtId primitive.ObjectID) string {
+func GetQmpSockPath(virtId primitive.ObjectID) string {
 	return path.Join(settings.Hypervisor.LibPath,
-		fmt.Sprintf("%s.sock", virtId.Hex()))
+		fmt.Sprintf("%s.qmp.sock", virtId.Hex()))
 }
@@ -0,0 +1,24 @@
+package resolver
+
+import (
+	"time"
+)
+
+const (
+	Address         = "169.254.169.253"
+	Port            = 53
+	Domain          = "internal"
+	ttl             = 60
+	maxMessageSize  = 4096
+	maxRequests     = 32
+	forwardTimeout  = 3 * time.Second
+	refreshInterval = 1 * time.Minute
+	refreshDelay    = 1 * time.Second
+)
+
+var (
+	DefaultNameservers = []string{
+		"8.8.8.8",
+		"8.8.4.4",
+	}
+)
@@ -0,0 +1,217 @@
+package resolver
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+const (
+	typeA    = 1
+	typeAAAA = 28
+	classIn  = 1
+
+	rcodeFormErr  = 1
+	rcodeServFail = 2
+	rcodeNxDomain = 3
+	rcodeNotImp   = 4
+	rcodeRefused  = 5
+)
+
+type question struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	raw   []byte
+}
+
+func isQuery(msg []byte) bool {
+	return len(msg) >= 12 && msg[2]&0x80 == 0
+}
+
+func getOpcode(msg []byte) byte {
+	return (msg[2] >> 3) & 0x0f
+}
+
+func parseQuestion(msg []byte) (ques *question, err error) {
+	if binary.BigEndian.Uint16(msg[4:6]) != 1 {
+		err = &errortypes.ParseError{
+			errors.New("resolver: Invalid question count"),
+		}
+		return
+	}
+
+	labels := []string{}
+	pos := 12
+
+	for {
+		if pos >= len(msg) {
+			err = &errortypes.ParseError{
+				errors.New("resolver: Truncated question name"),
+			}
+			return
+		}
+
+		n := int(msg[pos])
+		pos += 1
+
+		if n == 0 {
+			break
+		}
+
+		// Compression is not used in the question of a query
+		if n&0xc0 != 0 || pos+n > len(msg) {
+			err = &errortypes.ParseError{
+				errors.New("resolver: Invalid question label"),
+			}
+			return
+		}
+
+		labels = append(labels, strings.ToLower(string(msg[pos:pos+n])))
+		pos += n
+	}
+
+	if pos+4 > len(msg) {
+		err = &errortypes.ParseError{
+			errors.New("resolver: Truncated question"),
+		}
+		return
+	}
+
+	ques = &question{
+		Name:  strings.Join(labels, "."),
+		Type:  binary.BigEndian.Uint16(msg[pos : pos+2]),
+		Class: binary.BigEndian.Uint16(msg[pos+2 : pos+4]),
+		raw:   msg[12 : pos+4],
+	}
+
+	return
+}
+
+func buildResponse(msg []byte, ques *question, rcode byte,
+	addrs []net.IP) (resp []byte) {
+
+	resp = make([]byte, 12, 512)
+	copy(resp[0:2], msg[0:2])
+
+	// Keep opcode and recursion desired from the query
+	resp[2] = 0x80 | (msg[2] & 0x79)
+	if ques != nil && rcode != rcodeServFail && rcode != rcodeRefused {
+		resp[2] |= 0x04
+	}
+	resp[3] = 0x80 | rcode
+
+	if ques == nil {
+		return
+	}
+
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	binary.BigEndian.PutUint16(resp[6:8], uint16(len(addrs)))
+	resp = append(resp, ques.raw...)
+
+	for _, addr := range addrs {
+		typ := uint16(typeAAAA)
+		data := addr.To16()
+		if addr4 := addr.To4(); addr4 != nil {
+			typ = typeA
+			data = addr4
+		}
+
+		rr := make([]byte, 12)
+		// Name pointer to the question name
+		rr[0] = 0xc0
+		rr[1] = 0x0c
+		binary.BigEndian.PutUint16(rr[2:4], typ)
+		binary.BigEndian.PutUint16(rr[4:6], classIn)
+		binary.BigEndian.PutUint32(rr[6:10], ttl)
+		binary.BigEndian.PutUint16(rr[10:12], uint16(len(data)))
+
+		resp = append(resp, rr...)
+		resp = append(resp, data...)
+	}
+
+	return
+}
+
+// Upstream socket is created in the instance namespace to send queries
+// from the instance network
+func exchange(namespace string, msg []byte, upstream string) (
+	resp []byte, err error) {
+
+	var conn net.Conn
+	err = utils.ExecNamespace(namespace, func() (e error) {
+		conn, e = net.DialTimeout("udp",
+			net.JoinHostPort(upstream, "53"), forwardTimeout)
+		if e != nil {
+			e = &errortypes.ConnectionError{
+				errors.Wrap(e, "resolver: Failed to connect to upstream"),
+			}
+			return
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(forwardTimeout))
+	if err != nil {
+		err = &errortypes.ConnectionError{
+			errors.Wrap(err, "resolver: Failed to set upstream deadline"),
+		}
+		return
+	}
+
+	_, err = conn.Write(msg)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "resolver: Failed to write upstream query"),
+		}
+		return
+	}
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, e := conn.Read(buf)
+		if e != nil {
+			err = &errortypes.ReadError{
+				errors.Wrap(e, "resolver: Failed to read upstream response"),
+			}
+			return
+		}
+
+		// Discard responses that do not match the query id
+		if n < 12 || buf[0] != msg[0] || buf[1] != msg[1] {
+			continue
+		}
+
+		resp = buf[:n]
+		return
+	}
+}
+
+func forward(namespace string, msg []byte, upstreams []string) (
+	resp []byte, err error) {
+
+	for _, upstream := range upstreams {
+		resp, err = exchange(namespace, msg, upstream)
+		if err == nil {
+			return
+		}
+	}
+
+	if err == nil {
+		err = &errortypes.NotFoundError{
+			errors.New("resolver: No upstream servers"),
+		}
+	}
+
+	return
+}
@@ -0,0 +1,306 @@
+package resolver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+)
+
+func buildQuery(id uint16, flags uint16, name string, typ uint16) []byte {
+	msg := make([]byte, 12)
+	binary.BigEndian.PutUint16(msg[0:2], id)
+	binary.BigEndian.PutUint16(msg[2:4], flags)
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+
+	tail := make([]byte, 4)
+	binary.BigEndian.PutUint16(tail[0:2], typ)
+	binary.BigEndian.PutUint16(tail[2:4], classIn)
+
+	return append(msg, tail...)
+}
+
+func TestIsQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+		want bool
+	}{
+		{"query", buildQuery(1, 0x0100, "a.test", typeA), true},
+		{"response", buildQuery(1, 0x8100, "a.test", typeA), false},
+		{"short", make([]byte, 11), false},
+		{"empty", []byte{}, false},
+	}
+
+	for _, test := range tests {
+		if got := isQuery(test.msg); got != test.want {
+			t.Errorf("%s: isQuery = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestGetOpcode(t *testing.T) {
+	tests := []struct {
+		flags uint16
+		want  byte
+	}{
+		{0x0100, 0},
+		{0x0900, 1},
+		{0x1000, 2},
+		{0x2800, 5},
+		{0x7900, 15},
+	}
+
+	for _, test := range tests {
+		msg := buildQuery(1, test.flags, "a.test", typeA)
+		if got := getOpcode(msg); got != test.want {
+			t.Errorf("flags %#04x: getOpcode = %d, want %d",
+				test.flags, got, test.want)
+		}
+	}
+}
+
+func TestParseQuestion(t *testing.T) {
+	valid := buildQuery(1, 0x0100, "Web.Example.TEST", typeAAAA)
+
+	noQuestion := buildQuery(1, 0x0100, "a.test", typeA)
+	binary.BigEndian.PutUint16(noQuestion[4:6], 0)
+
+	twoQuestions := buildQuery(1, 0x0100, "a.test", typeA)
+	binary.BigEndian.PutUint16(twoQuestions[4:6], 2)
+
+	pointer := buildQuery(1, 0x0100, "a.test", typeA)
+	pointer[12] = 0xc0
+
+	longLabel := buildQuery(1, 0x0100, "a.test", typeA)
+	longLabel[12] = 0x30
+
+	tests := []struct {
+		name      string
+		msg       []byte
+		wantErr   string
+		wantName  string
+		wantType  uint16
+		wantClass uint16
+	}{
+		{
+			name:      "valid",
+			msg:       valid,
+			wantName:  "web.example.test",
+			wantType:  typeAAAA,
+			wantClass: classIn,
+		},
+		{
+			name:      "root",
+			msg:       append(append(make([]byte, 0), valid[:12]...), 0, 0, 1, 0, 1),
+			wantName:  "",
+			wantType:  typeA,
+			wantClass: classIn,
+		},
+		{
+			name:    "no question",
+			msg:     noQuestion,
+			wantErr: "Invalid question count",
+		},
+		{
+			name:    "two questions",
+			msg:     twoQuestions,
+			wantErr: "Invalid question count",
+		},
+		{
+			name:    "missing name",
+			msg:     valid[:12],
+			wantErr: "Truncated question name",
+		},
+		{
+			name:    "unterminated name",
+			msg:     valid[:len(valid)-5],
+			wantErr: "Truncated question name",
+		},
+		{
+			name:    "compression pointer",
+			msg:     pointer,
+			wantErr: "Invalid question label",
+		},
+		{
+			name:    "label overflow",
+			msg:     longLabel,
+			wantErr: "Invalid question label",
+		},
+		{
+			name:    "missing type",
+			msg:     valid[:len(valid)-2],
+			wantErr: "Truncated question",
+		},
+	}
+
+	for _, test := range tests {
+		ques, err := parseQuestion(test.msg)
+		if test.wantErr != "" {
+			if err == nil {
+				t.Errorf("%s: expected error", test.name)
+			} else if !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: error %q, want %q",
+					test.name, err.Error(), test.wantErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+
+		if ques.Name != test.wantName {
+			t.Errorf("%s: name %q, want %q",
+				test.name, ques.Name, test.wantName)
+		}
+		if ques.Type != test.wantType {
+			t.Errorf("%s: type %d, want %d",
+				test.name, ques.Type, test.wantType)
+		}
+		if ques.Class != test.wantClass {
+			t.Errorf("%s: class %d, want %d",
+				test.name, ques.Class, test.wantClass)
+		}
+		if !bytes.Equal(ques.raw, test.msg[12:]) {
+			t.Errorf("%s: raw question mismatch", test.name)
+		}
+	}
+}
+
+func TestBuildResponse(t *testing.T) {
+	msg := buildQuery(0xbeef, 0x0100, "web.example.test", typeA)
+	ques, err := parseQuestion(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		ques      *question
+		rcode     byte
+		addrs     []net.IP
+		wantAa    bool
+		wantTypes []uint16
+	}{
+		{
+			name:   "no question",
+			ques:   nil,
+			rcode:  rcodeFormErr,
+			wantAa: false,
+		},
+		{
+			name:   "nxdomain",
+			ques:   ques,
+			rcode:  rcodeNxDomain,
+			wantAa: true,
+		},
+		{
+			name:   "servfail",
+			ques:   ques,
+			rcode:  rcodeServFail,
+			wantAa: false,
+		},
+		{
+			name:   "refused",
+			ques:   ques,
+			rcode:  rcodeRefused,
+			wantAa: false,
+		},
+		{
+			name:  "answers",
+			ques:  ques,
+			rcode: 0,
+			addrs: []net.IP{
+				net.ParseIP("10.0.0.2"),
+				net.ParseIP("fd00::2"),
+			},
+			wantAa:    true,
+			wantTypes: []uint16{typeA, typeAAAA},
+		},
+	}
+
+	for _, test := range tests {
+		resp := buildResponse(msg, test.ques, test.rcode, test.addrs)
+
+		if binary.BigEndian.Uint16(resp[0:2]) != 0xbeef {
+			t.Errorf("%s: response id mismatch", test.name)
+		}
+		if resp[2]&0x80 == 0 {
+			t.Errorf("%s: response bit not set", test.name)
+		}
+		if resp[2]&0x01 == 0 {
+			t.Errorf("%s: recursion desired not kept", test.name)
+		}
+		if (resp[2]&0x04 != 0) != test.wantAa {
+			t.Errorf("%s: authoritative %t, want %t",
+				test.name, resp[2]&0x04 != 0, test.wantAa)
+		}
+		if resp[3]&0x0f != test.rcode {
+			t.Errorf("%s: rcode %d, want %d",
+				test.name, resp[3]&0x0f, test.rcode)
+		}
+
+		if test.ques == nil {
+			if len(resp) != 12 {
+				t.Errorf("%s: length %d, want 12", test.name, len(resp))
+			}
+			if binary.BigEndian.Uint16(resp[4:6]) != 0 {
+				t.Errorf("%s: unexpected question count", test.name)
+			}
+			continue
+		}
+
+		if binary.BigEndian.Uint16(resp[4:6]) != 1 {
+			t.Errorf("%s: question count mismatch", test.name)
+		}
+		count := binary.BigEndian.Uint16(resp[6:8])
+		if int(count) != len(test.addrs) {
+			t.Errorf("%s: answer count %d, want %d",
+				test.name, count, len(test.addrs))
+		}
+		if !bytes.Equal(resp[12:12+len(ques.raw)], ques.raw) {
+			t.Errorf("%s: question not copied", test.name)
+		}
+
+		pos := 12 + len(ques.raw)
+		for i, typ := range test.wantTypes {
+			if pos+12 > len(resp) {
+				t.Errorf("%s: answer %d truncated", test.name, i)
+				break
+			}
+
+			rr := resp[pos : pos+12]
+			if rr[0] != 0xc0 || rr[1] != 0x0c {
+				t.Errorf("%s: answer %d name pointer mismatch", test.name, i)
+			}
+			if binary.BigEndian.Uint16(rr[2:4]) != typ {
+				t.Errorf("%s: answer %d type %d, want %d", test.name, i,
+					binary.BigEndian.Uint16(rr[2:4]), typ)
+			}
+			if binary.BigEndian.Uint32(rr[6:10]) != ttl {
+				t.Errorf("%s: answer %d ttl mismatch", test.name, i)
+			}
+
+			size := int(binary.BigEndian.Uint16(rr[10:12]))
+			data := resp[pos+12 : pos+12+size]
+			if !net.IP(data).Equal(test.addrs[i]) {
+				t.Errorf("%s: answer %d address %s, want %s", test.name, i,
+					net.IP(data), test.addrs[i])
+			}
+			pos += 12 + size
+		}
+
+		if pos != len(resp) {
+			t.Errorf("%s: trailing response data", test.name)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package resolver
+
+import (
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/mongo-go-driver/mongo/options"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/vpc"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+var (
+	curTable     *table
+	curTableLock = sync.RWMutex{}
+)
+
+type member struct {
+	Id           primitive.ObjectID `bson:"_id"`
+	Name         string             `bson:"name"`
+	Organization primitive.ObjectID `bson:"organization"`
+}
+
+type record struct {
+	Organization primitive.ObjectID
+	Addr         net.IP
+	Addr6        net.IP
+}
+
+type table struct {
+	Records     map[string][]*record
+	Instances   map[primitive.ObjectID]primitive.ObjectID
+	Nameservers map[primitive.ObjectID][]string
+	PrivateDns  set.Set
+}
+
+func formatLabel(name string) string {
+	label := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, name)
+
+	if len(label) > 63 {
+		label = label[:63]
+	}
+
+	return strings.Trim(label, "-")
+}
+
+func GetDomain(vpcName string) string {
+	label := formatLabel(vpcName)
+	if label == "" {
+		return ""
+	}
+
+	return label + "." + Domain
+}
+
+func getMembers(db *database.Database, instIds []primitive.ObjectID) (
+	members map[primitive.ObjectID]*member, err error) {
+
+	coll := db.Instances()
+	members = map[primitive.ObjectID]*member{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"_id": &bson.M{
+				"$in": instIds,
+			},
+		},
+		&options.FindOptions{
+			Projection: &bson.D{
+				{"name", 1},
+				{"organization", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		mem := &member{}
+		err = cursor.Decode(mem)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		members[mem.Id] = mem
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func getVpcIps(db *database.Database, vcIds []primitive.ObjectID) (
+	vcIps []*vpc.VpcIp, err error) {
+
+	coll := db.VpcsIp()
+	vcIps = []*vpc.VpcIp{}
+
+	cursor, err := coll.Find(
+		db,
+		&bson.M{
+			"vpc": &bson.M{
+				"$in": vcIds,
+			},
+			"instance": &bson.M{
+				"$ne": nil,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		vcIp := &vpc.VpcIp{}
+		err = cursor.Decode(vcIp)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		vcIps = append(vcIps, vcIp)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func loadTable(db *database.Database) (tbl *table, err error) {
+	tbl = &table{
+		Records:     map[string][]*record{},
+		Instances:   map[primitive.ObjectID]primitive.ObjectID{},
+		Nameservers: map[primitive.ObjectID][]string{},
+		PrivateDns:  set.NewSet(),
+	}
+
+	if node.Self == nil || node.Self.Zone.IsZero() {
+		return
+	}
+
+	zne, err := zone.Get(db, node.Self.Zone)
+	if err != nil {
+		return
+	}
+
+	vcs, err := vpc.GetDatacenter(db, zne.Datacenter)
+	if err != nil {
+		return
+	}
+
+	if len(vcs) == 0 {
+		return
+	}
+
+	vcsMap := map[primitive.ObjectID]*vpc.Vpc{}
+	vcIds := []primitive.ObjectID{}
+	for _, vc := range vcs {
+		vcsMap[vc.Id] = vc
+		vcIds = append(vcIds, vc.Id)
+
+		nameservers := []string{}
+		for _, nameserver := range vc.Nameservers {
+			if nameserver != Address {
+				nameservers = append(nameservers, nameserver)
+			}
+		}
+		if len(nameservers) == 0 {
+			nameservers = DefaultNameservers
+		}
+		tbl.Nameservers[vc.Id] = nameservers
+
+		if vc.PrivateDns {
+			tbl.PrivateDns.Add(vc.Id)
+		}
+	}
+
+	vcIps, err := getVpcIps(db, vcIds)
+	if err != nil {
+		return
+	}
+
+	instIds := []primitive.ObjectID{}
+	for _, vcIp := range vcIps {
+		instIds = append(instIds, vcIp.Instance)
+	}
+
+	if len(instIds) == 0 {
+		return
+	}
+
+	members, err := getMembers(db, instIds)
+	if err != nil {
+		return
+	}
+
+	for _, vcIp := range vcIps {
+		vc := vcsMap[vcIp.Vpc]
+		mem := members[vcIp.Instance]
+		if vc == nil || mem == nil {
+			continue
+		}
+
+		tbl.Instances[mem.Id] = mem.Organization
+
+		// Only instances in private DNS VPCs are resolvable
+		if !vc.PrivateDns {
+			continue
+		}
+
+		label := formatLabel(mem.Name)
+		domain := GetDomain(vc.Name)
+		if label == "" || domain == "" {
+			continue
+		}
+
+		addr, _ := vcIp.GetIps()
+		name := label + "." + domain
+
+		tbl.Records[name] = append(tbl.Records[name], &record{
+			Organization: vc.Organization,
+			Addr:         addr,
+			Addr6:        vc.GetIp6(addr),
+		})
+	}
+
+	return
+}
+
+func getTable() *table {
+	curTableLock.RLock()
+	tbl := curTable
+	curTableLock.RUnlock()
+	return tbl
+}
+
+func update(db *database.Database) (err error) {
+	tbl, err := loadTable(db)
+	if err != nil {
+		return
+	}
+
+	curTableLock.Lock()
+	curTable = tbl
+	curTableLock.Unlock()
+
+	return
+}
+
+func lookup(instId, vcId primitive.ObjectID, ques *question) (
+	addrs []net.IP, rcode byte) {
+
+	tbl := getTable()
+	if tbl == nil {
+		rcode = rcodeServFail
+		return
+	}
+
+	if !tbl.PrivateDns.Contains(vcId) {
+		rcode = rcodeRefused
+		return
+	}
+
+	orgId, ok := tbl.Instances[instId]
+	if !ok {
+		rcode = rcodeServFail
+		return
+	}
+
+	found := false
+	for _, rec := range tbl.Records[ques.Name] {
+		// Only resolve instances in the same organization
+		if rec.Organization != orgId {
+			continue
+		}
+		found = true
+
+		if ques.Class != classIn {
+			continue
+		}
+
+		switch ques.Type {
+		case typeA:
+			addrs = append(addrs, rec.Addr)
+			break
+		case typeAAAA:
+			addrs = append(addrs, rec.Addr6)
+			break
+		}
+	}
+
+	if !found {
+		rcode = rcodeNxDomain
+	}
+
+	return
+}
+
+func getUpstreams(vcId primitive.ObjectID) []string {
+	tbl := getTable()
+	if tbl == nil {
+		return nil
+	}
+
+	return tbl.Nameservers[vcId]
+}
@@ -0,0 +1,194 @@
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/requires"
+	"github.com/pritunl/pritunl-cloud/vm"
+	"github.com/pritunl/pritunl-cloud/vpc"
+)
+
+var (
+	servers      = map[primitive.ObjectID]*Server{}
+	serversLock  = sync.Mutex{}
+	updateNotify = make(chan bool, 1)
+)
+
+func notify() {
+	select {
+	case updateNotify <- true:
+	default:
+	}
+}
+
+func hasServers() bool {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+	return len(servers) > 0
+}
+
+func start(virt *vm.VirtualMachine, vc *vpc.Vpc) (err error) {
+	srv := &Server{
+		Instance:  virt.Id,
+		Vpc:       vc.Id,
+		Namespace: vm.GetNamespace(virt.Id, 0),
+	}
+
+	err = srv.Start()
+	if err != nil {
+		return
+	}
+
+	servers[virt.Id] = srv
+	notify()
+
+	logrus.WithFields(logrus.Fields{
+		"instance_id":   virt.Id.Hex(),
+		"net_namespace": srv.Namespace,
+	}).Info("resolver: Started instance DNS resolver")
+
+	return
+}
+
+func stop(instId primitive.ObjectID) {
+	srv := servers[instId]
+	if srv != nil {
+		srv.Close()
+		delete(servers, instId)
+	}
+}
+
+// Start the resolver for the primary VPC of the instance, the resolver
+// only runs when private DNS is enabled on the VPC
+func Start(virt *vm.VirtualMachine, vc *vpc.Vpc) (err error) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	stop(virt.Id)
+
+	if vc == nil || !vc.PrivateDns {
+		return
+	}
+
+	err = start(virt, vc)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Ensure(virt *vm.VirtualMachine, vc *vpc.Vpc) (err error) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	srv := servers[virt.Id]
+
+	if vc == nil || !vc.PrivateDns {
+		if srv == nil {
+			return
+		}
+
+		stop(virt.Id)
+
+		err = removeAddress(srv.Namespace)
+		if err != nil {
+			return
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id":   virt.Id.Hex(),
+			"net_namespace": srv.Namespace,
+		}).Info("resolver: Stopped instance DNS resolver")
+
+		return
+	}
+
+	if srv != nil {
+		if srv.Vpc == vc.Id {
+			return
+		}
+		stop(virt.Id)
+	}
+
+	err = start(virt, vc)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func Stop(instId primitive.ObjectID) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	stop(instId)
+}
+
+func getDispatchType(evt *event.EventPublish) string {
+	switch data := evt.Data.(type) {
+	case primitive.M:
+		typ, _ := data["type"].(string)
+		return typ
+	case primitive.D:
+		for _, elem := range data {
+			if elem.Key == "type" {
+				typ, _ := elem.Value.(string)
+				return typ
+			}
+		}
+	}
+
+	return ""
+}
+
+func runUpdate() {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-updateNotify:
+			// Wait for related changes to be committed
+			time.Sleep(refreshDelay)
+		case <-ticker.C:
+		}
+
+		if !hasServers() {
+			continue
+		}
+
+		db := database.GetDatabase()
+		err := update(db)
+		db.Close()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("resolver: Failed to update records")
+		}
+	}
+}
+
+func init() {
+	event.Register("dispatch", func(evt *event.EventPublish) {
+		switch getDispatchType(evt) {
+		case "instance.change", "vpc.change":
+			notify()
+			break
+		}
+	})
+
+	module := requires.New("resolver")
+	module.After("settings")
+
+	module.Handler = func() (err error) {
+		go runUpdate()
+		return
+	}
+}
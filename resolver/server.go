@@ -0,0 +1,152 @@
+package resolver
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type Server struct {
+	Instance  primitive.ObjectID
+	Vpc       primitive.ObjectID
+	Namespace string
+	conn      net.PacketConn
+	limiter   *utils.Limiter
+	done      chan struct{}
+}
+
+func (s *Server) handle(msg []byte) (resp []byte) {
+	if !isQuery(msg) {
+		return
+	}
+
+	if getOpcode(msg) != 0 {
+		resp = buildResponse(msg, nil, rcodeNotImp, nil)
+		return
+	}
+
+	ques, err := parseQuestion(msg)
+	if err != nil {
+		resp = buildResponse(msg, nil, rcodeFormErr, nil)
+		return
+	}
+
+	if ques.Name == Domain || strings.HasSuffix(ques.Name, "."+Domain) {
+		addrs, rcode := lookup(s.Instance, s.Vpc, ques)
+		resp = buildResponse(msg, ques, rcode, addrs)
+		return
+	}
+
+	upstreams := getUpstreams(s.Vpc)
+	if len(upstreams) == 0 {
+		resp = buildResponse(msg, ques, rcodeRefused, nil)
+		return
+	}
+
+	resp, err = forward(s.Namespace, msg, upstreams)
+	if err != nil {
+		resp = buildResponse(msg, ques, rcodeServFail, nil)
+		return
+	}
+
+	return
+}
+
+func (s *Server) serve() {
+	buf := make([]byte, maxMessageSize)
+
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				logrus.WithFields(logrus.Fields{
+					"instance_id": s.Instance.Hex(),
+					"error":       err,
+				}).Error("resolver: Resolver server error")
+			}
+			return
+		}
+
+		if !s.limiter.Acquire() {
+			continue
+		}
+
+		msg := make([]byte, n)
+		copy(msg, buf[:n])
+
+		go func() {
+			defer s.limiter.Release()
+
+			resp := s.handle(msg)
+			if resp != nil {
+				_, _ = s.conn.WriteTo(resp, addr)
+			}
+		}()
+	}
+}
+
+func (s *Server) Start() (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"File exists"},
+		"ip", "netns", "exec", s.Namespace,
+		"ip", "addr",
+		"add", Address+"/32",
+		"dev", "lo",
+	)
+	if err != nil {
+		return
+	}
+
+	err = utils.ExecNamespace(s.Namespace, func() (e error) {
+		s.conn, e = net.ListenPacket("udp",
+			fmt.Sprintf("%s:%d", Address, Port))
+		if e != nil {
+			e = &errortypes.NetworkError{
+				errors.Wrap(e, "resolver: Failed to listen"),
+			}
+			return
+		}
+
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	s.limiter = utils.NewLimiter(maxRequests)
+	s.done = make(chan struct{})
+
+	go s.serve()
+
+	return
+}
+
+func removeAddress(namespace string) (err error) {
+	_, err = utils.ExecCombinedOutputLogged(
+		[]string{"Cannot assign", "Cannot open network namespace"},
+		"ip", "netns", "exec", namespace,
+		"ip", "addr",
+		"del", Address+"/32",
+		"dev", "lo",
+	)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func (s *Server) Close() {
+	if s.conn != nil {
+		close(s.done)
+		_ = s.conn.Close()
+	}
+}
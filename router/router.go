@@ -20,11 +20,13 @@ import (
 	"github.com/pritunl/pritunl-cloud/acme"
 	"github.com/pritunl/pritunl-cloud/ahandlers"
 	"github.com/pritunl/pritunl-cloud/certificate"
+	"github.com/pritunl/pritunl-cloud/console"
 	"github.com/pritunl/pritunl-cloud/constants"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/event"
 	"github.com/pritunl/pritunl-cloud/node"
 	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/telemetry"
 	"github.com/pritunl/pritunl-cloud/uhandlers"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
@@ -33,6 +35,7 @@ type Router struct {
 	nodeHash         []byte
 	adminType        bool
 	userType         bool
+	consoleType      bool
 	port             int
 	noRedirectServer bool
 	protocol         string
@@ -61,6 +64,23 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, re *http.Request) {
 		return
 	}
 
+	if strings.HasPrefix(re.URL.Path, "/console/") &&
+		node.Self.IsHypervisor() {
+
+		console.Handle(w, re)
+		return
+	}
+
+	if re.URL.Path == "/metrics" {
+		telemetry.Handle(w, re)
+		return
+	}
+
+	if r.consoleType {
+		utils.WriteStatus(w, 404)
+		return
+	}
+
 	hst := utils.StripPort(re.Host)
 	if r.adminType && !r.userType {
 		r.aRouter.ServeHTTP(w, re)
@@ -133,7 +153,7 @@ func (r *Router) initRedirect() (err error) {
 func (r *Router) startRedirect() {
 	defer r.waiter.Done()
 
-	if r.port == 80 || r.noRedirectServer {
+	if r.port == 80 || r.noRedirectServer || r.consoleType {
 		return
 	}
 
@@ -161,6 +181,8 @@ func (r *Router) startRedirect() {
 func (r *Router) initWeb() (err error) {
 	r.adminType = node.Self.IsAdmin()
 	r.userType = node.Self.IsUser()
+	// Hypervisor only nodes serve the console proxy without web handlers
+	r.consoleType = !r.adminType && !r.userType && node.Self.IsHypervisor()
 	r.adminDomain = node.Self.AdminDomain
 	r.userDomain = node.Self.UserDomain
 	r.certificates = node.Self.CertificateObjs
@@ -480,7 +502,9 @@ func (r *Router) Run() (err error) {
 	go r.watchNode()
 
 	for {
-		if !node.Self.IsAdmin() && !node.Self.IsUser() {
+		if !node.Self.IsAdmin() && !node.Self.IsUser() &&
+			!node.Self.IsHypervisor() {
+
 			time.Sleep(500 * time.Millisecond)
 			continue
 		}
@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+func Evacuate(db *database.Database, nde *node.Node) (
+	insts []*instance.Instance, err error) {
+
+	insts, err = instance.GetAll(db, &bson.M{
+		"node": nde.Id,
+	})
+	if err != nil {
+		return
+	}
+
+	zne, err := zone.Get(db, nde.Zone)
+	if err != nil {
+		return
+	}
+
+	zoneSchd, err := NewZone(db, nde.Zone)
+	if err != nil {
+		return
+	}
+
+	dcSchd, err := NewDatacenter(db, zne.Datacenter)
+	if err != nil {
+		return
+	}
+
+	for _, inst := range insts {
+		if inst.IsMigrating() {
+			continue
+		}
+
+		var schd *Scheduler
+		if inst.CanMigrateLive() {
+			schd = zoneSchd
+		} else if inst.CanMigrateCold() {
+			schd = dcSchd
+		} else {
+			err = setMigrateError(db, inst,
+				"Instance must be running or stopped to migrate")
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		target, errData, e := schd.ScheduleInstance(db, inst)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			err = setMigrateError(db, inst, errData.Message)
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		errData, err = inst.Migrate(db, target)
+		if err != nil {
+			return
+		}
+
+		if errData != nil {
+			err = setMigrateError(db, inst, errData.Message)
+			if err != nil {
+				return
+			}
+			continue
+		}
+	}
+
+	return
+}
+
+func setMigrateError(db *database.Database, inst *instance.Instance,
+	msg string) (err error) {
+
+	inst.MigrateError = msg
+	err = inst.CommitFields(db, set.NewSet("migrate_error"))
+	if err != nil {
+		return
+	}
+
+	return
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/disk"
+	"github.com/pritunl/pritunl-cloud/image"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/settings"
+)
+
+func Failover(db *database.Database, nde *node.Node) (
+	insts []*instance.Instance, err error) {
+
+	insts, err = instance.GetAll(db, &bson.M{
+		"node":  nde.Id,
+		"ha":    true,
+		"state": instance.Start,
+	})
+	if err != nil {
+		return
+	}
+
+	if len(insts) == 0 {
+		return
+	}
+
+	schd, err := NewZone(db, nde.Zone)
+	if err != nil {
+		return
+	}
+
+	maxAge := time.Duration(
+		settings.Hypervisor.HaBackupMaxAge) * time.Second
+
+	for _, inst := range insts {
+		if inst.IsMigrating() {
+			continue
+		}
+
+		dsks, e := disk.GetInstance(db, inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		imgs := map[primitive.ObjectID]*image.Image{}
+		valid := true
+		for _, dsk := range dsks {
+			if dsk.State != disk.Available {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"disk_id":     dsk.Id.Hex(),
+				}).Error("scheduler: Cannot failover instance with " +
+					"unavailable disk")
+				valid = false
+				break
+			}
+
+			img, e := image.GetDiskLast(db, dsk.Id)
+			if e != nil {
+				if _, ok := e.(*database.NotFoundError); ok {
+					logrus.WithFields(logrus.Fields{
+						"instance_id": inst.Id.Hex(),
+						"disk_id":     dsk.Id.Hex(),
+					}).Error("scheduler: Cannot failover instance " +
+						"without disk backup")
+					valid = false
+					break
+				}
+				err = e
+				return
+			}
+
+			backupTime := img.LastModified
+			if backupTime.IsZero() {
+				backupTime = img.Id.Timestamp()
+			}
+
+			if maxAge > 0 && time.Since(backupTime) > maxAge {
+				logrus.WithFields(logrus.Fields{
+					"instance_id": inst.Id.Hex(),
+					"disk_id":     dsk.Id.Hex(),
+					"image_id":    img.Id.Hex(),
+					"backup_age":  time.Since(backupTime).String(),
+				}).Error("scheduler: Cannot failover instance " +
+					"with stale disk backup")
+				valid = false
+				break
+			}
+
+			imgs[dsk.Id] = img
+		}
+
+		if !valid {
+			continue
+		}
+
+		target, errData, e := schd.ScheduleInstance(db, inst)
+		if e != nil {
+			err = e
+			return
+		}
+
+		if errData != nil {
+			logrus.WithFields(logrus.Fields{
+				"instance_id": inst.Id.Hex(),
+				"node_id":     nde.Id.Hex(),
+				"error":       errData.Message,
+			}).Error("scheduler: Failed to schedule instance failover")
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"instance_id": inst.Id.Hex(),
+			"node_id":     nde.Id.Hex(),
+			"target_id":   target.Id.Hex(),
+		}).Warning("scheduler: Failing over instance from offline node")
+
+		for _, dsk := range dsks {
+			dsk.Node = target.Id
+			dsk.State = disk.Restore
+			dsk.RestoreImage = imgs[dsk.Id].Id
+
+			err = dsk.CommitFields(db, set.NewSet(
+				"node", "state", "restore_image"))
+			if err != nil {
+				return
+			}
+		}
+
+		err = block.RemoveInstanceIps(db, inst.Id)
+		if err != nil {
+			return
+		}
+
+		inst.Node = target.Id
+		inst.Zone = target.Zone
+		inst.State = instance.Stop
+		inst.HaPending = true
+
+		err = inst.CommitFields(db, set.NewSet(
+			"node", "zone", "state", "ha_pending"))
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
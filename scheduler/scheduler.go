@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/placement"
+)
+
+type Scheduler struct {
+	nodes []*node.Node
+}
+
+func (s *Scheduler) Schedule(memory, processors int) (
+	nde *node.Node, errData *errortypes.ErrorData) {
+
+	nde, errData = s.schedule(memory, processors, nil)
+	return
+}
+
+func (s *Scheduler) ScheduleInstance(db *database.Database,
+	inst *instance.Instance) (nde *node.Node,
+	errData *errortypes.ErrorData, err error) {
+
+	if inst.PlacementGroup.IsZero() {
+		nde, errData = s.schedule(inst.Memory, inst.Processors, nil)
+		return
+	}
+
+	grp, err := placement.Get(db, inst.PlacementGroup)
+	if err != nil {
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+			nde, errData = s.schedule(inst.Memory, inst.Processors, nil)
+		}
+		return
+	}
+
+	ndeIds, err := grp.GetNodes(db, inst.Id)
+	if err != nil {
+		return
+	}
+
+	var filter func(*node.Node) bool
+	switch grp.Policy {
+	case placement.Spread:
+		filter = func(n *node.Node) bool {
+			return !ndeIds.Contains(n.Id)
+		}
+		break
+	case placement.Pack:
+		if ndeIds.Len() > 0 {
+			filter = func(n *node.Node) bool {
+				return ndeIds.Contains(n.Id)
+			}
+		}
+		break
+	}
+
+	nde, errData = s.schedule(inst.Memory, inst.Processors, filter)
+	if errData != nil && filter != nil {
+		errData = &errortypes.ErrorData{
+			Error: "placement_node_unavailable",
+			Message: "No node available with sufficient resources " +
+				"that satisfies placement group",
+		}
+	}
+
+	return
+}
+
+func (s *Scheduler) schedule(memory, processors int,
+	filter func(*node.Node) bool) (
+	nde *node.Node, errData *errortypes.ErrorData) {
+
+	if memory < 256 {
+		memory = 256
+	}
+	if processors < 1 {
+		processors = 1
+	}
+
+	memoryUnits := float64(memory) / float64(1024)
+	score := 0.0
+
+	for _, curNde := range s.nodes {
+		if curNde.Maintenance {
+			continue
+		}
+
+		if filter != nil && !filter(curNde) {
+			continue
+		}
+
+		if curNde.CpuUnits <= 0 || curNde.MemoryUnits <= 0 {
+			continue
+		}
+
+		memoryRes := curNde.MemoryUnitsRes + memoryUnits
+		if memoryRes > curNde.MemoryUnits {
+			continue
+		}
+
+		cpuRatio := float64(curNde.CpuUnitsRes+processors) /
+			float64(curNde.CpuUnits)
+		memoryRatio := memoryRes / curNde.MemoryUnits
+
+		curScore := cpuRatio
+		if memoryRatio > curScore {
+			curScore = memoryRatio
+		}
+
+		if nde == nil || curScore < score {
+			nde = curNde
+			score = curScore
+		}
+	}
+
+	if nde == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "node_unavailable",
+			Message: "No node available with sufficient resources",
+		}
+		return
+	}
+
+	nde.CpuUnitsRes += processors
+	nde.MemoryUnitsRes += memoryUnits
+
+	return
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/zone"
+)
+
+func NewZone(db *database.Database, zoneId primitive.ObjectID) (
+	schd *Scheduler, err error) {
+
+	nodes, err := node.GetAllActiveHypervisors(db, &bson.M{
+		"zone": zoneId,
+	})
+	if err != nil {
+		return
+	}
+
+	schd = &Scheduler{
+		nodes: nodes,
+	}
+
+	return
+}
+
+func NewDatacenter(db *database.Database, dcId primitive.ObjectID) (
+	schd *Scheduler, err error) {
+
+	zones, err := zone.GetAllDatacenter(db, dcId)
+	if err != nil {
+		return
+	}
+
+	zoneIds := []primitive.ObjectID{}
+	for _, zne := range zones {
+		zoneIds = append(zoneIds, zne.Id)
+	}
+
+	nodes, err := node.GetAllActiveHypervisors(db, &bson.M{
+		"zone": &bson.M{
+			"$in": zoneIds,
+		},
+	})
+	if err != nil {
+		return
+	}
+
+	schd = &Scheduler{
+		nodes: nodes,
+	}
+
+	return
+}
@@ -3,17 +3,33 @@ package settings
 var Hypervisor *hypervisor
 
 type hypervisor struct {
-	Id              string `bson:"_id"`
-	SystemdPath     string `bson:"systemd_path" default:"/etc/systemd/system"`
-	LibPath         string `bson:"systemd_path" default:"/var/lib/pritunl-cloud"`
-	NormalMtu       int    `bson:"normal_mtu" default:"1500"`
-	JumboMtu        int    `bson:"jumbo_mtu" default:"9000"`
-	VxlanId         int    `bson:"vxlan_id" default:"9417"`
-	VxlanDestPort   int    `bson:"vxlan_dest_port" default:"4789"`
-	HostNetworkName string `bson:"host_network_name" default:"pritunlhost0"`
-	StartTimeout    int    `bson:"start_timeout" default:"45"`
-	StopTimeout     int    `bson:"stop_timeout" default:"120"`
-	RefreshRate     int    `bson:"refresh_rate" default:"90"`
+	Id                 string `bson:"_id"`
+	SystemdPath        string `bson:"systemd_path" default:"/etc/systemd/system"`
+	LibPath            string `bson:"systemd_path" default:"/var/lib/pritunl-cloud"`
+	NormalMtu          int    `bson:"normal_mtu" default:"1500"`
+	JumboMtu           int    `bson:"jumbo_mtu" default:"9000"`
+	VxlanId            int    `bson:"vxlan_id" default:"9417"`
+	VxlanDestPort      int    `bson:"vxlan_dest_port" default:"4789"`
+	HostNetworkName    string `bson:"host_network_name" default:"pritunlhost0"`
+	StartTimeout       int    `bson:"start_timeout" default:"45"`
+	StopTimeout        int    `bson:"stop_timeout" default:"120"`
+	RefreshRate        int    `bson:"refresh_rate" default:"90"`
+	MigratePort        int    `bson:"migrate_port" default:"49152"`
+	MigratePorts       int    `bson:"migrate_ports" default:"64"`
+	MigrateTimeout     int    `bson:"migrate_timeout" default:"3600"`
+	BackupTimeout      int    `bson:"backup_timeout" default:"14400"`
+	HaTimeout          int    `bson:"ha_timeout" default:"120"`
+	HaFenceTimeout     int    `bson:"ha_fence_timeout" default:"60"`
+	HaBackupMaxAge     int    `bson:"ha_backup_max_age" default:"86400"`
+	MaxProcessors      int    `bson:"max_processors" default:"32"`
+	MaxMemory          int    `bson:"max_memory" default:"131072"`
+	MemorySlots        int    `bson:"memory_slots" default:"16"`
+	OvmfCodePath       string `bson:"ovmf_code_path" default:"/usr/share/edk2/ovmf/OVMF_CODE.fd"`
+	OvmfVarsPath       string `bson:"ovmf_vars_path" default:"/usr/share/edk2/ovmf/OVMF_VARS.fd"`
+	OvmfSecureCodePath string `bson:"ovmf_secure_code_path" default:"/usr/share/edk2/ovmf/OVMF_CODE.secboot.fd"`
+	OvmfSecureVarsPath string `bson:"ovmf_secure_vars_path" default:"/usr/share/edk2/ovmf/OVMF_VARS.secboot.fd"`
+	SwtpmPath          string `bson:"swtpm_path" default:"/usr/bin/swtpm"`
+	SerialLogSize      int    `bson:"serial_log_size" default:"1024"`
 }
 
 func newHypervisor() interface{} {
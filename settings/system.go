@@ -21,6 +21,7 @@ type system struct {
 	AcmeKeyAlgorithm     string `bson:"acme_key_algorithm" default:"rsa"`
 	DiskBackupWindow     int    `bson:"disk_backup_window" default:"6"`
 	DiskBackupTime       int    `bson:"disk_backup_time" default:"10"`
+	MetricsToken         string `bson:"metrics_token"`
 }
 
 func newSystem() interface{} {
@@ -12,6 +12,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/disk"
 	"github.com/pritunl/pritunl-cloud/domain"
+	"github.com/pritunl/pritunl-cloud/elasticip"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/firewall"
 	"github.com/pritunl/pritunl-cloud/instance"
@@ -37,13 +38,17 @@ type State struct {
 	nodeFirewall     []*firewall.Rule
 	firewalls        map[string][]*firewall.Rule
 	disks            []*disk.Disk
+	virts            []*vm.VirtualMachine
 	virtsMap         map[primitive.ObjectID]*vm.VirtualMachine
 	instances        []*instance.Instance
 	instancesMap     map[primitive.ObjectID]*instance.Instance
 	instanceDisks    map[primitive.ObjectID][]*disk.Disk
+	migrations       []*instance.Instance
 	domainRecordsMap map[primitive.ObjectID][]*domain.Record
 	vpcs             []*vpc.Vpc
 	vpcsMap          map[primitive.ObjectID]*vpc.Vpc
+	elasticIpsMap    map[primitive.ObjectID]*elasticip.ElasticIp
+	elasticIpAddrs   set.Set
 	addInstances     set.Set
 	remInstances     set.Set
 	running          []string
@@ -89,6 +94,10 @@ func (s *State) Instances() []*instance.Instance {
 	return s.instances
 }
 
+func (s *State) Migrations() []*instance.Instance {
+	return s.migrations
+}
+
 func (s *State) NodeFirewall() []*firewall.Rule {
 	return s.nodeFirewall
 }
@@ -121,6 +130,14 @@ func (s *State) Vpcs() []*vpc.Vpc {
 	return s.vpcs
 }
 
+func (s *State) ElasticIp(instId primitive.ObjectID) *elasticip.ElasticIp {
+	return s.elasticIpsMap[instId]
+}
+
+func (s *State) IsElasticIp(addr string) bool {
+	return s.elasticIpAddrs.Contains(addr)
+}
+
 func (s *State) DiskInUse(instId, dskId primitive.ObjectID) bool {
 	curVirt := s.virtsMap[instId]
 
@@ -137,6 +154,10 @@ func (s *State) DiskInUse(instId, dskId primitive.ObjectID) bool {
 	return false
 }
 
+func (s *State) Virts() []*vm.VirtualMachine {
+	return s.virts
+}
+
 func (s *State) GetVirt(instId primitive.ObjectID) *vm.VirtualMachine {
 	return s.virtsMap[instId]
 }
@@ -245,6 +266,24 @@ func (s *State) init() (err error) {
 	}
 	s.instancesMap = instancesMap
 
+	migrations, err := instance.GetAllVirt(db, &bson.M{
+		"migrate_node": s.nodeSelf.Id,
+	}, nil)
+	if err != nil {
+		return
+	}
+
+	for _, inst := range migrations {
+		dsks, e := disk.GetInstance(db, inst.Id)
+		if e != nil {
+			err = e
+			return
+		}
+
+		inst.LoadVirt(dsks)
+	}
+	s.migrations = migrations
+
 	curVirts, err := qemu.GetVms(db, instancesMap)
 	if err != nil {
 		return
@@ -259,6 +298,7 @@ func (s *State) init() (err error) {
 		}
 		virtsMap[virt.Id] = virt
 	}
+	s.virts = curVirts
 	s.virtsMap = virtsMap
 
 	nodeFirewall, firewalls, err := firewall.GetAllIngress(
@@ -284,6 +324,35 @@ func (s *State) init() (err error) {
 	s.vpcs = vpcs
 	s.vpcsMap = vpcsMap
 
+	elasticIpsMap := map[primitive.ObjectID]*elasticip.ElasticIp{}
+	elasticIpAddrs := set.NewSet()
+	if s.nodeSelf.NetworkMode == node.Static && len(s.nodeSelf.Blocks) > 0 {
+		blockIds := []primitive.ObjectID{}
+		for _, blckAttch := range s.nodeSelf.Blocks {
+			blockIds = append(blockIds, blckAttch.Block)
+		}
+
+		eips, e := elasticip.GetAll(db, &bson.M{
+			"block": &bson.M{
+				"$in": blockIds,
+			},
+		})
+		if e != nil {
+			err = e
+			return
+		}
+
+		for _, eip := range eips {
+			eip.Json()
+			elasticIpAddrs.Add(eip.Address)
+			if !eip.Instance.IsZero() {
+				elasticIpsMap[eip.Instance] = eip
+			}
+		}
+	}
+	s.elasticIpsMap = elasticIpsMap
+	s.elasticIpAddrs = elasticIpAddrs
+
 	recrds, err := domain.GetRecordAll(db, &bson.M{
 		"node": s.nodeSelf.Id,
 	})
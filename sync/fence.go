@@ -0,0 +1,41 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/qemu"
+)
+
+func fenceRunner() {
+	fenced := false
+
+	for {
+		time.Sleep(1 * time.Second)
+		if !node.Self.IsHypervisor() {
+			continue
+		}
+
+		if !node.Self.LeaseExpired() {
+			fenced = false
+			continue
+		}
+
+		if fenced {
+			continue
+		}
+		fenced = true
+
+		logrus.WithFields(logrus.Fields{
+			"node_id": node.Self.Id.Hex(),
+		}).Error("sync: Node lease expired, fencing high " +
+			"availability instances")
+
+		qemu.FenceHa()
+	}
+}
+
+func initFence() {
+	go fenceRunner()
+}
@@ -0,0 +1,44 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/metric"
+	"github.com/pritunl/pritunl-cloud/node"
+)
+
+func metricSync() (err error) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	err = metric.Collect(db)
+	if err != nil {
+		return
+	}
+
+	return
+}
+
+func metricRunner() {
+	time.Sleep(1 * time.Second)
+
+	for {
+		time.Sleep(metric.SampleInterval)
+		if !node.Self.IsHypervisor() {
+			continue
+		}
+
+		err := metricSync()
+		if err != nil {
+			logrus.WithFields(logrus.Fields{
+				"error": err,
+			}).Error("sync: Failed to collect instance metrics")
+		}
+	}
+}
+
+func initMetric() {
+	go metricRunner()
+}
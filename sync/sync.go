@@ -5,4 +5,6 @@ func Init() {
 	initNode()
 	initVm()
 	initLink()
+	initMetric()
+	initFence()
 }
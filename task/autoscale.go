@@ -0,0 +1,50 @@
+package task
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/autoscale"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/event"
+)
+
+var autoscaleReconcile = &Task{
+	Name:    "autoscale",
+	Hours:   AllHours,
+	Mins:    AllMins,
+	Handler: autoscaleHandler,
+}
+
+func autoscaleHandler(db *database.Database) (err error) {
+	grps, err := autoscale.GetAll(db, &bson.M{})
+	if err != nil {
+		return
+	}
+
+	changed := false
+	for _, grp := range grps {
+		grpChanged, e := grp.Reconcile(db)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"autoscale_group": grp.Id.Hex(),
+				"error":           e,
+			}).Error("task: Failed to reconcile autoscale group")
+			continue
+		}
+
+		if grpChanged {
+			changed = true
+		}
+	}
+
+	if changed {
+		event.PublishDispatch(db, "instance.change")
+		event.PublishDispatch(db, "autoscale_group.change")
+	}
+
+	return
+}
+
+func init() {
+	register(autoscaleReconcile)
+}
@@ -0,0 +1,64 @@
+package task
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/scheduler"
+	"github.com/pritunl/pritunl-cloud/settings"
+)
+
+var ha = &Task{
+	Name:    "ha",
+	Hours:   AllHours,
+	Mins:    AllMins,
+	Handler: haHandler,
+}
+
+func haHandler(db *database.Database) (err error) {
+	ndes, err := node.GetAll(db)
+	if err != nil {
+		return
+	}
+
+	timeout := time.Duration(settings.Hypervisor.HaTimeout) * time.Second
+
+	// Offline nodes fence their own instances once the node lease
+	// expires, failover must wait until after the node has fenced
+	fenceTimeout := time.Duration(
+		settings.Hypervisor.HaFenceTimeout)*time.Second + 30*time.Second
+	if timeout < fenceTimeout {
+		timeout = fenceTimeout
+	}
+
+	for _, nde := range ndes {
+		if !nde.IsHypervisor() || nde.Zone.IsZero() ||
+			nde.Timestamp.IsZero() || time.Since(nde.Timestamp) < timeout {
+
+			continue
+		}
+
+		insts, e := scheduler.Failover(db, nde)
+		if e != nil {
+			logrus.WithFields(logrus.Fields{
+				"node_id": nde.Id.Hex(),
+				"error":   e,
+			}).Error("task: Failed to failover node instances")
+			continue
+		}
+
+		if len(insts) > 0 {
+			event.PublishDispatch(db, "instance.change")
+			event.PublishDispatch(db, "disk.change")
+		}
+	}
+
+	return
+}
+
+func init() {
+	register(ha)
+}
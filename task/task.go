@@ -7,6 +7,7 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/telemetry"
 )
 
 var (
@@ -63,6 +64,7 @@ func (t *Task) run(now time.Time) {
 	}
 
 	err = t.Handler(db)
+	telemetry.ObserveTask(t.Name, err)
 	if err != nil {
 		logrus.WithFields(logrus.Fields{
 			"task":  t.Name,
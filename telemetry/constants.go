@@ -0,0 +1,13 @@
+package telemetry
+
+const (
+	namespace = "pritunl_cloud"
+
+	Success = "success"
+	Failed  = "failed"
+
+	Snapshot = "snapshot"
+	Backup   = "backup"
+)
+
+var durationBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 1800, 3600}
@@ -0,0 +1,29 @@
+package telemetry
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/pritunl/pritunl-cloud/settings"
+	"github.com/pritunl/pritunl-cloud/utils"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var handler = promhttp.Handler()
+
+func Handle(w http.ResponseWriter, r *http.Request) {
+	token := settings.System.MetricsToken
+	if token == "" {
+		utils.WriteStatus(w, 404)
+		return
+	}
+
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+		utils.WriteStatus(w, 401)
+		return
+	}
+
+	handler.ServeHTTP(w, r)
+}
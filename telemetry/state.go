@@ -0,0 +1,118 @@
+package telemetry
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	instancesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "instances"),
+		"Instances by state and virtual machine state",
+		[]string{"state", "vm_state"}, nil,
+	)
+	disksDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "disks"),
+		"Disks by state",
+		[]string{"state"}, nil,
+	)
+)
+
+type stateCount struct {
+	Id    map[string]string `bson:"_id"`
+	Count int               `bson:"count"`
+}
+
+type stateCollector struct{}
+
+func (s *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- instancesDesc
+	ch <- disksDesc
+}
+
+func (s *stateCollector) count(db *database.Database,
+	coll *database.Collection, fields ...string) (
+	counts []*stateCount, err error) {
+
+	counts = []*stateCount{}
+
+	group := bson.M{}
+	for _, field := range fields {
+		group[field] = "$" + field
+	}
+
+	cursor, err := coll.Aggregate(db, []*bson.M{
+		&bson.M{
+			"$group": &bson.M{
+				"_id": group,
+				"count": &bson.M{
+					"$sum": 1,
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		doc := &stateCount{}
+		err = cursor.Decode(doc)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		counts = append(counts, doc)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func (s *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	db := database.GetDatabase()
+	defer db.Close()
+
+	counts, err := s.count(db, db.Instances(), "state", "vm_state")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("telemetry: Failed to count instances")
+	} else {
+		for _, cnt := range counts {
+			ch <- prometheus.MustNewConstMetric(
+				instancesDesc,
+				prometheus.GaugeValue,
+				float64(cnt.Count),
+				cnt.Id["state"],
+				cnt.Id["vm_state"],
+			)
+		}
+	}
+
+	counts, err = s.count(db, db.Disks(), "state")
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"error": err,
+		}).Error("telemetry: Failed to count disks")
+	} else {
+		for _, cnt := range counts {
+			ch <- prometheus.MustNewConstMetric(
+				disksDesc,
+				prometheus.GaugeValue,
+				float64(cnt.Count),
+				cnt.Id["state"],
+			)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package telemetry
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	nodeLoad = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_load",
+		Help:      "Node load average percent",
+	}, []string{"period"})
+	nodeMemory = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_memory_percent",
+		Help:      "Node memory used percent",
+	})
+	nodeUnits = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_units",
+		Help:      "Node total resource units",
+	}, []string{"resource"})
+	nodeUnitsRes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "node_units_reserved",
+		Help:      "Node reserved resource units",
+	}, []string{"resource"})
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Web server requests",
+	}, []string{"method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Web server request latency",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+	taskJobs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_jobs_total",
+		Help:      "Task jobs run on node",
+	}, []string{"task", "result"})
+	eventLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_listener_lag_seconds",
+		Help:      "Delay between event publish and listener dispatch",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"channel"})
+	imageDownload = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "image_download_duration_seconds",
+		Help:      "Image download duration",
+		Buckets:   durationBuckets,
+	}, []string{"result"})
+	diskBackup = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "disk_backup_duration_seconds",
+		Help:      "Disk snapshot and backup duration",
+		Buckets:   durationBuckets,
+	}, []string{"type", "result"})
+)
+
+func getResult(err error) string {
+	if err != nil {
+		return Failed
+	}
+	return Success
+}
+
+func SetNode(load1, load5, load15, memory float64, cpuUnits int,
+	memoryUnits float64, cpuUnitsRes int, memoryUnitsRes float64) {
+
+	nodeLoad.WithLabelValues("1").Set(load1)
+	nodeLoad.WithLabelValues("5").Set(load5)
+	nodeLoad.WithLabelValues("15").Set(load15)
+	nodeMemory.Set(memory)
+	nodeUnits.WithLabelValues("cpu").Set(float64(cpuUnits))
+	nodeUnits.WithLabelValues("memory").Set(memoryUnits)
+	nodeUnitsRes.WithLabelValues("cpu").Set(float64(cpuUnitsRes))
+	nodeUnitsRes.WithLabelValues("memory").Set(memoryUnitsRes)
+}
+
+func ObserveRequest(method string, code int, duration time.Duration) {
+	httpRequests.WithLabelValues(method, strconv.Itoa(code)).Inc()
+	httpDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+func ObserveTask(name string, err error) {
+	taskJobs.WithLabelValues(name, getResult(err)).Inc()
+}
+
+func ObserveEvent(channel string, timestamp time.Time) {
+	eventLag.WithLabelValues(channel).Observe(
+		time.Since(timestamp).Seconds())
+}
+
+func ObserveImageDownload(start time.Time, err error) {
+	imageDownload.WithLabelValues(getResult(err)).Observe(
+		time.Since(start).Seconds())
+}
+
+func ObserveBackup(typ string, start time.Time, err error) {
+	diskBackup.WithLabelValues(typ, getResult(err)).Observe(
+		time.Since(start).Seconds())
+}
+
+func init() {
+	prometheus.MustRegister(
+		nodeLoad,
+		nodeMemory,
+		nodeUnits,
+		nodeUnitsRes,
+		httpRequests,
+		httpDuration,
+		taskJobs,
+		eventLag,
+		imageDownload,
+		diskBackup,
+		&stateCollector{},
+	)
+}
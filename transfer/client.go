@@ -0,0 +1,166 @@
+package transfer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type Client struct {
+	Address string
+	Port    int
+	Token   string
+	client  *http.Client
+}
+
+type progressWriter struct {
+	progress func(n int64)
+}
+
+func (p *progressWriter) Write(data []byte) (n int, err error) {
+	n = len(data)
+	p.progress(int64(n))
+	return
+}
+
+func (c *Client) Download(name, dest string, progress func(n int64)) (
+	err error) {
+
+	u := fmt.Sprintf("https://%s/file/%s",
+		net.JoinHostPort(c.Address, strconv.Itoa(c.Port)), name)
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "transfer: Failed to create request"),
+		}
+		return
+	}
+	req.Header.Set(TokenHeader, c.Token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "transfer: Request failed"),
+		}
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		err = &errortypes.RequestError{
+			errors.Newf("transfer: Bad status %d", resp.StatusCode),
+		}
+		return
+	}
+
+	tempPath := dest + ".transfer"
+	defer utils.RemoveAll(tempPath)
+
+	file, err := os.OpenFile(tempPath,
+		os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "transfer: Failed to create file"),
+		}
+		return
+	}
+
+	var writer io.Writer = file
+	if progress != nil {
+		writer = io.MultiWriter(file, &progressWriter{
+			progress: progress,
+		})
+	}
+
+	n, err := io.Copy(writer, resp.Body)
+	file.Close()
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "transfer: Failed to download file"),
+		}
+		return
+	}
+
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		err = &errortypes.ReadError{
+			errors.New("transfer: Incomplete file download"),
+		}
+		return
+	}
+
+	err = os.Rename(tempPath, dest)
+	if err != nil {
+		err = &errortypes.WriteError{
+			errors.Wrap(err, "transfer: Failed to move file"),
+		}
+		return
+	}
+
+	return
+}
+
+func NewClient(db *database.Database, ndeId primitive.ObjectID,
+	addr string, port int, token string) (client *Client, err error) {
+
+	nde, err := node.Get(db, ndeId)
+	if err != nil {
+		return
+	}
+
+	block, _ := pem.Decode([]byte(nde.SelfCertificate))
+	if block == nil {
+		err = &errortypes.ParseError{
+			errors.New("transfer: Failed to decode node certificate"),
+		}
+		return
+	}
+	nodeCert := block.Bytes
+
+	transport := &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			MinVersion:         tls.VersionTLS12,
+			MaxVersion:         tls.VersionTLS13,
+			VerifyPeerCertificate: func(rawCerts [][]byte,
+				_ [][]*x509.Certificate) error {
+
+				if len(rawCerts) == 0 ||
+					!bytes.Equal(rawCerts[0], nodeCert) {
+
+					return &errortypes.VerificationError{
+						errors.New("transfer: Node certificate mismatch"),
+					}
+				}
+
+				return nil
+			},
+		},
+	}
+
+	client = &Client{
+		Address: addr,
+		Port:    port,
+		Token:   token,
+		client: &http.Client{
+			Transport: transport,
+		},
+	}
+
+	return
+}
@@ -0,0 +1,5 @@
+package transfer
+
+const (
+	TokenHeader = "Pritunl-Transfer-Token"
+)
@@ -0,0 +1,135 @@
+package transfer
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/node"
+)
+
+type Server struct {
+	Port     int
+	Token    string
+	Files    map[string]string
+	listener net.Listener
+	server   *http.Server
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" || !strings.HasPrefix(r.URL.Path, "/file/") {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	token := r.Header.Get(TokenHeader)
+	if token == "" || subtle.ConstantTimeCompare(
+		[]byte(token), []byte(s.Token)) != 1 {
+
+		logrus.WithFields(logrus.Fields{
+			"remote_address": r.RemoteAddr,
+		}).Warn("transfer: Invalid transfer token")
+
+		http.Error(w, "Unauthorized", 401)
+		return
+	}
+
+	pth, ok := s.Files[strings.TrimPrefix(r.URL.Path, "/file/")]
+	if !ok {
+		http.Error(w, "Not found", 404)
+		return
+	}
+
+	file, err := os.Open(pth)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"path":  pth,
+			"error": err,
+		}).Error("transfer: Failed to open transfer file")
+
+		http.Error(w, "Server error", 500)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Server error", 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(200)
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		logrus.WithFields(logrus.Fields{
+			"path":  pth,
+			"error": err,
+		}).Error("transfer: Failed to send transfer file")
+	}
+}
+
+func (s *Server) Start() (err error) {
+	certPem, keyPem, err := node.SelfCert()
+	if err != nil {
+		return
+	}
+
+	keypair, err := tls.X509KeyPair(certPem, keyPem)
+	if err != nil {
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "transfer: Failed to load certificate"),
+		}
+		return
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", s.Port))
+	if err != nil {
+		err = &errortypes.NetworkError{
+			errors.Wrap(err, "transfer: Failed to listen"),
+		}
+		return
+	}
+
+	s.listener = tls.NewListener(listener, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS13,
+		Certificates: []tls.Certificate{keypair},
+	})
+
+	s.server = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+		IdleTimeout:       1 * time.Minute,
+		MaxHeaderBytes:    4096,
+	}
+
+	go func() {
+		e := s.server.Serve(s.listener)
+		if e != nil && e != http.ErrServerClosed {
+			logrus.WithFields(logrus.Fields{
+				"error": e,
+			}).Error("transfer: Transfer server error")
+		}
+	}()
+
+	return
+}
+
+func (s *Server) Close() {
+	if s.server != nil {
+		_ = s.server.Close()
+	}
+}
@@ -0,0 +1,299 @@
+package uhandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/autoscale"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type autoscaleGroupData struct {
+	Id              primitive.ObjectID `json:"id"`
+	Name            string             `json:"name"`
+	Comment         string             `json:"comment"`
+	Template        primitive.ObjectID `json:"template"`
+	Minimum         int                `json:"minimum"`
+	Maximum         int                `json:"maximum"`
+	Desired         int                `json:"desired"`
+	HealthCheck     string             `json:"health_check"`
+	HealthPort      int                `json:"health_port"`
+	HealthPath      string             `json:"health_path"`
+	HealthThreshold int                `json:"health_threshold"`
+	HealthGrace     int                `json:"health_grace"`
+	ScaleUpCpu      int                `json:"scale_up_cpu"`
+	ScaleDownCpu    int                `json:"scale_down_cpu"`
+	Cooldown        int                `json:"cooldown"`
+}
+
+type autoscaleGroupsData struct {
+	AutoscaleGroups []*autoscale.Group `json:"autoscale_groups"`
+	Count           int64              `json:"count"`
+}
+
+func autoscaleGroupPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &autoscaleGroupData{}
+
+	grpId, ok := utils.ParseObjectId(c.Param("group_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp, err := autoscale.GetOrg(db, userOrg, grpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp.Name = data.Name
+	grp.Comment = data.Comment
+	grp.Template = data.Template
+	grp.Minimum = data.Minimum
+	grp.Maximum = data.Maximum
+	grp.Desired = data.Desired
+	grp.HealthCheck = data.HealthCheck
+	grp.HealthPort = data.HealthPort
+	grp.HealthPath = data.HealthPath
+	grp.HealthThreshold = data.HealthThreshold
+	grp.HealthGrace = data.HealthGrace
+	grp.ScaleUpCpu = data.ScaleUpCpu
+	grp.ScaleDownCpu = data.ScaleDownCpu
+	grp.Cooldown = data.Cooldown
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"template",
+		"minimum",
+		"maximum",
+		"desired",
+		"health_check",
+		"health_port",
+		"health_path",
+		"health_threshold",
+		"health_grace",
+		"scale_up_cpu",
+		"scale_down_cpu",
+		"cooldown",
+	)
+
+	errData, err := grp.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = grp.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "autoscale_group.change")
+
+	c.JSON(200, grp)
+}
+
+func autoscaleGroupPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &autoscaleGroupData{
+		Name:            "New Autoscale Group",
+		Minimum:         1,
+		Maximum:         1,
+		Desired:         1,
+		HealthThreshold: 3,
+		HealthGrace:     300,
+		Cooldown:        300,
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	grp := &autoscale.Group{
+		Name:            data.Name,
+		Comment:         data.Comment,
+		Organization:    userOrg,
+		Template:        data.Template,
+		Minimum:         data.Minimum,
+		Maximum:         data.Maximum,
+		Desired:         data.Desired,
+		HealthCheck:     data.HealthCheck,
+		HealthPort:      data.HealthPort,
+		HealthPath:      data.HealthPath,
+		HealthThreshold: data.HealthThreshold,
+		HealthGrace:     data.HealthGrace,
+		ScaleUpCpu:      data.ScaleUpCpu,
+		ScaleDownCpu:    data.ScaleDownCpu,
+		Cooldown:        data.Cooldown,
+	}
+
+	errData, err := grp.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = grp.Insert(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "autoscale_group.change")
+
+	c.JSON(200, grp)
+}
+
+func autoscaleGroupDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	grpId, ok := utils.ParseObjectId(c.Param("group_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := autoscale.RemoveOrg(db, userOrg, grpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "autoscale_group.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func autoscaleGroupsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = autoscale.RemoveMultiOrg(db, userOrg, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "autoscale_group.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func autoscaleGroupGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	grpId, ok := utils.ParseObjectId(c.Param("group_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	grp, err := autoscale.GetOrg(db, userOrg, grpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.JSON(200, grp)
+}
+
+func autoscaleGroupsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"organization": userOrg,
+	}
+
+	grpId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = grpId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	tplId, ok := utils.ParseObjectId(c.Query("template"))
+	if ok {
+		query["template"] = tplId
+	}
+
+	grps, count, err := autoscale.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	data := &autoscaleGroupsData{
+		AutoscaleGroups: grps,
+		Count:           count,
+	}
+
+	c.JSON(200, data)
+}
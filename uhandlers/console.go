@@ -0,0 +1,187 @@
+package uhandlers
+
+import (
+	"github.com/dropbox/godropbox/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/audit"
+	"github.com/pritunl/pritunl-cloud/authorizer"
+	"github.com/pritunl/pritunl-cloud/console"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/qga"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func instanceConsole(c *gin.Context, typ, auditType string) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	stream, err := console.Dial(db, inst, typ)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		auditType,
+		audit.Fields{
+			"instance_id": inst.Id,
+			"node_id":     inst.Node,
+		},
+	)
+	if err != nil {
+		stream.Close()
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	conn, err := event.Upgrader.Upgrade(c.Writer, c.Request,
+		console.UpgradeHeader(c.Request))
+	if err != nil {
+		stream.Close()
+		err = &errortypes.RequestError{
+			errors.Wrap(err, "uhandlers: Failed to upgrade request"),
+		}
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	console.Relay(conn, stream)
+}
+
+func instanceSerialGet(c *gin.Context) {
+	instanceConsole(c, console.Serial, audit.UserInstanceSerial)
+}
+
+func instanceVncGet(c *gin.Context) {
+	instanceConsole(c, console.Vnc, audit.UserInstanceVnc)
+}
+
+func instanceSerialLogGet(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	output, err := console.GetSerialLog(db, inst)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	c.String(200, output)
+}
+
+func instanceAgentPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	authr := c.MustGet("authorizer").(*authorizer.Authorizer)
+	action := &qga.Action{}
+
+	instanceId, ok := utils.ParseObjectId(c.Param("instance_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(action)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	errData := action.Validate()
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	inst, err := instance.GetOrg(db, userOrg, instanceId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	usr, err := authr.GetUser(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = audit.New(
+		db,
+		c.Request,
+		usr.Id,
+		audit.UserInstanceAgent,
+		audit.Fields{
+			"instance_id": inst.Id,
+			"node_id":     inst.Node,
+			"action":      action.Action,
+		},
+	)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	result, errData, err := console.RunAgent(db, inst, action)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	c.JSON(200, result)
+}
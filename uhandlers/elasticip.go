@@ -0,0 +1,279 @@
+package uhandlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dropbox/godropbox/container/set"
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/block"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/demo"
+	"github.com/pritunl/pritunl-cloud/elasticip"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"github.com/pritunl/pritunl-cloud/event"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+type elasticIpData struct {
+	Id       primitive.ObjectID `json:"id"`
+	Name     string             `json:"name"`
+	Comment  string             `json:"comment"`
+	Block    primitive.ObjectID `json:"block"`
+	Instance primitive.ObjectID `json:"instance"`
+}
+
+type elasticIpsData struct {
+	ElasticIps []*elasticip.ElasticIp `json:"elastic_ips"`
+	Count      int64                  `json:"count"`
+}
+
+func elasticIpPut(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &elasticIpData{}
+
+	eipId, ok := utils.ParseObjectId(c.Param("eip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	eip, err := elasticip.GetOrg(db, userOrg, eipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	eip.Name = data.Name
+	eip.Comment = data.Comment
+	eip.Instance = data.Instance
+
+	fields := set.NewSet(
+		"name",
+		"comment",
+		"instance",
+	)
+
+	errData, err := eip.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = eip.CommitFields(db, fields)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	eip.Json()
+
+	event.PublishDispatch(db, "elastic_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, eip)
+}
+
+func elasticIpPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &elasticIpData{
+		Name: "New Elastic IP",
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	eip := &elasticip.ElasticIp{
+		Name:         data.Name,
+		Comment:      data.Comment,
+		Organization: userOrg,
+		Block:        data.Block,
+		Instance:     data.Instance,
+	}
+
+	errData, err := eip.Validate(db)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	err = eip.Insert(db)
+	if err != nil {
+		if _, ok := err.(*block.BlockFull); ok {
+			errData = &errortypes.ErrorData{
+				Error:   "block_full",
+				Message: "No addresses available in block",
+			}
+			c.JSON(400, errData)
+			return
+		}
+
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	eip.Json()
+
+	event.PublishDispatch(db, "elastic_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, eip)
+}
+
+func elasticIpDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	eipId, ok := utils.ParseObjectId(c.Param("eip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := elasticip.RemoveOrg(db, userOrg, eipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "elastic_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func elasticIpsDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := []primitive.ObjectID{}
+
+	err := c.Bind(&data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = elasticip.RemoveMultiOrg(db, userOrg, data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "elastic_ip.change")
+	event.PublishDispatch(db, "instance.change")
+
+	c.JSON(200, nil)
+}
+
+func elasticIpGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	eipId, ok := utils.ParseObjectId(c.Param("eip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	eip, err := elasticip.GetOrg(db, userOrg, eipId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	eip.Json()
+
+	c.JSON(200, eip)
+}
+
+func elasticIpsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	page, _ := strconv.ParseInt(c.Query("page"), 10, 0)
+	pageCount, _ := strconv.ParseInt(c.Query("page_count"), 10, 0)
+
+	query := bson.M{
+		"organization": userOrg,
+	}
+
+	eipId, ok := utils.ParseObjectId(c.Query("id"))
+	if ok {
+		query["_id"] = eipId
+	}
+
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		query["name"] = &bson.M{
+			"$regex":   fmt.Sprintf(".*%s.*", name),
+			"$options": "i",
+		}
+	}
+
+	blck, ok := utils.ParseObjectId(c.Query("block"))
+	if ok {
+		query["block"] = blck
+	}
+
+	inst, ok := utils.ParseObjectId(c.Query("instance"))
+	if ok {
+		query["instance"] = inst
+	}
+
+	eips, count, err := elasticip.GetAllPaged(db, &query, page, pageCount)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, eip := range eips {
+		eip.Json()
+	}
+
+	data := &elasticIpsData{
+		ElasticIps: eips,
+		Count:      count,
+	}
+
+	c.JSON(200, data)
+}
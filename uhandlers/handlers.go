@@ -36,6 +36,9 @@ func Register(engine *gin.Engine) {
 	orgGroup := csrfGroup.Group("")
 	orgGroup.Use(middlewear.UserOrg)
 
+	tokenGroup := dbGroup.Group("")
+	tokenGroup.Use(middlewear.MetadataToken)
+
 	engine.NoRoute(middlewear.NotFound)
 
 	engine.GET("/auth/state", authStateGet)
@@ -58,6 +61,13 @@ func Register(engine *gin.Engine) {
 	orgGroup.DELETE("/authority", authoritiesDelete)
 	orgGroup.DELETE("/authority/:authority_id", authorityDelete)
 
+	orgGroup.GET("/autoscale_group", autoscaleGroupsGet)
+	orgGroup.GET("/autoscale_group/:group_id", autoscaleGroupGet)
+	orgGroup.PUT("/autoscale_group/:group_id", autoscaleGroupPut)
+	orgGroup.POST("/autoscale_group", autoscaleGroupPost)
+	orgGroup.DELETE("/autoscale_group", autoscaleGroupsDelete)
+	orgGroup.DELETE("/autoscale_group/:group_id", autoscaleGroupDelete)
+
 	engine.GET("/check", checkGet)
 
 	authGroup.GET("/csrf", csrfGet)
@@ -102,16 +112,45 @@ func Register(engine *gin.Engine) {
 	orgGroup.PUT("/instance", instancesPut)
 	orgGroup.GET("/instance/:instance_id", instanceGet)
 	orgGroup.PUT("/instance/:instance_id", instancePut)
+	orgGroup.GET("/instance/:instance_id/serial", instanceSerialGet)
+	orgGroup.GET("/instance/:instance_id/serial_log", instanceSerialLogGet)
+	orgGroup.GET("/instance/:instance_id/vnc", instanceVncGet)
+	orgGroup.POST("/instance/:instance_id/agent", instanceAgentPost)
+	orgGroup.GET("/instance/:instance_id/metrics", instanceMetricsGet)
 	orgGroup.POST("/instance", instancePost)
 	orgGroup.DELETE("/instance", instancesDelete)
 	orgGroup.DELETE("/instance/:instance_id", instanceDelete)
 
 	csrfGroup.PUT("/license", licensePut)
 
+	tokenGroup.GET("/metadata/instance", metadataInstanceGet)
+
 	orgGroup.GET("/node", nodesGet)
 
 	csrfGroup.GET("/organization", organizationsGet)
 
+	orgGroup.GET("/placement_group", placementGroupsGet)
+	orgGroup.GET("/placement_group/:group_id", placementGroupGet)
+	orgGroup.PUT("/placement_group/:group_id", placementGroupPut)
+	orgGroup.POST("/placement_group", placementGroupPost)
+	orgGroup.DELETE("/placement_group", placementGroupsDelete)
+	orgGroup.DELETE("/placement_group/:group_id", placementGroupDelete)
+
+	orgGroup.GET("/elastic_ip", elasticIpsGet)
+	orgGroup.GET("/elastic_ip/:eip_id", elasticIpGet)
+	orgGroup.PUT("/elastic_ip/:eip_id", elasticIpPut)
+	orgGroup.POST("/elastic_ip", elasticIpPost)
+	orgGroup.DELETE("/elastic_ip", elasticIpsDelete)
+	orgGroup.DELETE("/elastic_ip/:eip_id", elasticIpDelete)
+
+	orgGroup.GET("/template", templatesGet)
+	orgGroup.GET("/template/:template_id", templateGet)
+	orgGroup.PUT("/template/:template_id", templatePut)
+	orgGroup.POST("/template", templatePost)
+	orgGroup.POST("/template/:template_id/launch", templateLaunchPost)
+	orgGroup.DELETE("/template", templatesDelete)
+	orgGroup.DELETE("/template/:template_id", templateDelete)
+
 	csrfGroup.PUT("/theme", themePut)
 
 	orgGroup.GET("/vpc", vpcsGet)
@@ -119,6 +158,9 @@ func Register(engine *gin.Engine) {
 	orgGroup.PUT("/vpc/:vpc_id", vpcPut)
 	orgGroup.GET("/vpc/:vpc_id/routes", vpcRoutesGet)
 	orgGroup.PUT("/vpc/:vpc_id/routes", vpcRoutesPut)
+	orgGroup.GET("/vpc/:vpc_id/ips", vpcIpsGet)
+	orgGroup.POST("/vpc/:vpc_id/ips", vpcIpPost)
+	orgGroup.DELETE("/vpc/:vpc_id/ips/:ip_id", vpcIpDelete)
 	orgGroup.POST("/vpc", vpcPost)
 	orgGroup.DELETE("/vpc", vpcsDelete)
 	orgGroup.DELETE("/vpc/:vpc_id", vpcDelete)
@@ -20,6 +20,7 @@ import (
 	"github.com/pritunl/pritunl-cloud/image"
 	"github.com/pritunl/pritunl-cloud/instance"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/scheduler"
 	"github.com/pritunl/pritunl-cloud/storage"
 	"github.com/pritunl/pritunl-cloud/usb"
 	"github.com/pritunl/pritunl-cloud/utils"
@@ -29,27 +30,35 @@ import (
 )
 
 type instanceData struct {
-	Id               primitive.ObjectID `json:"id"`
-	Zone             primitive.ObjectID `json:"zone"`
-	Vpc              primitive.ObjectID `json:"vpc"`
-	Subnet           primitive.ObjectID `json:"subnet"`
-	Node             primitive.ObjectID `json:"node"`
-	Image            primitive.ObjectID `json:"image"`
-	ImageBacking     bool               `json:"image_backing"`
-	Domain           primitive.ObjectID `json:"domain"`
-	Name             string             `json:"name"`
-	Comment          string             `json:"comment"`
-	State            string             `json:"state"`
-	DeleteProtection bool               `json:"delete_protection"`
-	InitDiskSize     int                `json:"init_disk_size"`
-	Memory           int                `json:"memory"`
-	Processors       int                `json:"processors"`
-	NetworkRoles     []string           `json:"network_roles"`
-	UsbDevices       []*usb.Device      `json:"usb_devices"`
-	Vnc              bool               `json:"vnc"`
-	NoPublicAddress  bool               `json:"no_public_address"`
-	NoHostAddress    bool               `json:"no_host_address"`
-	Count            int                `json:"count"`
+	Id               primitive.ObjectID  `json:"id"`
+	Datacenter       primitive.ObjectID  `json:"datacenter"`
+	Zone             primitive.ObjectID  `json:"zone"`
+	Vpc              primitive.ObjectID  `json:"vpc"`
+	Subnet           primitive.ObjectID  `json:"subnet"`
+	StaticIp         string              `json:"static_ip"`
+	Adapters         []*instance.Adapter `json:"adapters"`
+	Node             primitive.ObjectID  `json:"node"`
+	PlacementGroup   primitive.ObjectID  `json:"placement_group"`
+	Image            primitive.ObjectID  `json:"image"`
+	ImageBacking     bool                `json:"image_backing"`
+	Domain           primitive.ObjectID  `json:"domain"`
+	Name             string              `json:"name"`
+	Comment          string              `json:"comment"`
+	State            string              `json:"state"`
+	DeleteProtection bool                `json:"delete_protection"`
+	Ha               bool                `json:"ha"`
+	InitDiskSize     int                 `json:"init_disk_size"`
+	Memory           int                 `json:"memory"`
+	Processors       int                 `json:"processors"`
+	NetworkRoles     []string            `json:"network_roles"`
+	UserData         string              `json:"user_data"`
+	UsbDevices       []*usb.Device       `json:"usb_devices"`
+	Vnc              bool                `json:"vnc"`
+	NoPublicAddress  bool                `json:"no_public_address"`
+	NoHostAddress    bool                `json:"no_host_address"`
+	Firmware         string              `json:"firmware"`
+	Tpm              bool                `json:"tpm"`
+	Count            int                 `json:"count"`
 }
 
 type instanceMultiData struct {
@@ -99,6 +108,18 @@ func instancePut(c *gin.Context) {
 		return
 	}
 
+	for _, adapter := range dta.Adapters {
+		exists, err := vpc.ExistsOrg(db, userOrg, adapter.Vpc)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+	}
+
 	if !dta.Domain.IsZero() {
 		exists, err := domain.ExistsOrg(db, userOrg, dta.Domain)
 		if err != nil {
@@ -117,38 +138,53 @@ func instancePut(c *gin.Context) {
 	inst.Comment = dta.Comment
 	inst.Vpc = dta.Vpc
 	inst.Subnet = dta.Subnet
+	inst.StaticIp = dta.StaticIp
+	inst.Adapters = dta.Adapters
 	if dta.State != "" {
 		inst.State = dta.State
 	}
 	inst.DeleteProtection = dta.DeleteProtection
+	inst.Ha = dta.Ha
 	inst.Memory = dta.Memory
 	inst.Processors = dta.Processors
 	inst.NetworkRoles = dta.NetworkRoles
+	inst.UserData = dta.UserData
 	inst.UsbDevices = dta.UsbDevices
 	inst.Vnc = dta.Vnc
 	inst.Domain = dta.Domain
+	inst.PlacementGroup = dta.PlacementGroup
 	inst.NoPublicAddress = dta.NoPublicAddress
 	inst.NoHostAddress = dta.NoHostAddress
+	inst.Firmware = dta.Firmware
+	inst.Tpm = dta.Tpm
 
 	fields := set.NewSet(
 		"name",
 		"comment",
 		"vpc",
 		"subnet",
+		"static_ip",
+		"adapters",
 		"state",
 		"restart",
+		"restart_reason",
 		"restart_block_ip",
 		"delete_protection",
+		"ha",
 		"memory",
 		"processors",
 		"network_roles",
+		"user_data",
 		"usb_devices",
 		"vnc",
 		"vnc_display",
 		"vnc_password",
 		"domain",
+		"placement_group",
 		"no_public_address",
 		"no_host_address",
+		"firmware",
+		"tpm",
 	)
 
 	errData, err := inst.Validate(db)
@@ -188,7 +224,6 @@ func instancePost(c *gin.Context) {
 	}
 
 	db := c.MustGet("db").(*database.Database)
-	userOrg := c.MustGet("organization").(primitive.ObjectID)
 	dta := &instanceData{
 		Name: "New Instance",
 	}
@@ -199,13 +234,26 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
-	zne, err := zone.Get(db, dta.Zone)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
+	instanceCreate(c, db, dta)
+}
+
+func instanceCreate(c *gin.Context, db *database.Database,
+	dta *instanceData) {
+
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	dcId := dta.Datacenter
+	if !dta.Zone.IsZero() || dcId.IsZero() {
+		zne, err := zone.Get(db, dta.Zone)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+
+		dcId = zne.Datacenter
 	}
 
-	exists, err := datacenter.ExistsOrg(db, userOrg, zne.Datacenter)
+	exists, err := datacenter.ExistsOrg(db, userOrg, dcId)
 	if err != nil {
 		utils.AbortWithError(c, 500, err)
 		return
@@ -215,15 +263,39 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
-	nde, err := node.Get(db, dta.Node)
-	if err != nil {
-		utils.AbortWithError(c, 500, err)
-		return
-	}
+	var schd *scheduler.Scheduler
+	if !dta.Node.IsZero() {
+		nde, err := node.Get(db, dta.Node)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
 
-	if nde.Zone != zne.Id {
-		utils.AbortWithStatus(c, 405)
-		return
+		if nde.Zone != dta.Zone {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+
+		if nde.Maintenance {
+			errData := &errortypes.ErrorData{
+				Error:   "node_maintenance",
+				Message: "Node is in maintenance",
+			}
+			c.JSON(400, errData)
+			return
+		}
+	} else if !dta.Zone.IsZero() {
+		schd, err = scheduler.NewZone(db, dta.Zone)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+	} else {
+		schd, err = scheduler.NewDatacenter(db, dcId)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
 	}
 
 	exists, err = vpc.ExistsOrg(db, userOrg, dta.Vpc)
@@ -236,6 +308,18 @@ func instancePost(c *gin.Context) {
 		return
 	}
 
+	for _, adapter := range dta.Adapters {
+		exists, err := vpc.ExistsOrg(db, userOrg, adapter.Vpc)
+		if err != nil {
+			utils.AbortWithError(c, 500, err)
+			return
+		}
+		if !exists {
+			utils.AbortWithStatus(c, 405)
+			return
+		}
+	}
+
 	if !dta.Domain.IsZero() {
 		exists, err := domain.ExistsOrg(db, userOrg, dta.Domain)
 		if err != nil {
@@ -296,6 +380,22 @@ func instancePost(c *gin.Context) {
 		dta.Count = 1
 	}
 
+	staticIp := dta.StaticIp != ""
+	for _, adapter := range dta.Adapters {
+		if adapter.StaticIp != "" {
+			staticIp = true
+		}
+	}
+
+	if dta.Count > 1 && staticIp {
+		errData := &errortypes.ErrorData{
+			Error:   "static_ip_count",
+			Message: "Static private IP cannot be used with multiple instances",
+		}
+		c.JSON(400, errData)
+		return
+	}
+
 	for i := 0; i < dta.Count; i++ {
 		name := ""
 		if strings.Contains(dta.Name, "%") {
@@ -310,21 +410,44 @@ func instancePost(c *gin.Context) {
 			Zone:             dta.Zone,
 			Vpc:              dta.Vpc,
 			Subnet:           dta.Subnet,
+			StaticIp:         dta.StaticIp,
+			Adapters:         dta.Adapters,
 			Node:             dta.Node,
+			PlacementGroup:   dta.PlacementGroup,
 			Image:            dta.Image,
 			ImageBacking:     dta.ImageBacking,
 			DeleteProtection: dta.DeleteProtection,
+			Ha:               dta.Ha,
 			Name:             name,
 			Comment:          dta.Comment,
 			InitDiskSize:     dta.InitDiskSize,
 			Memory:           dta.Memory,
 			Processors:       dta.Processors,
 			NetworkRoles:     dta.NetworkRoles,
+			UserData:         dta.UserData,
 			UsbDevices:       dta.UsbDevices,
 			Vnc:              dta.Vnc,
 			Domain:           dta.Domain,
 			NoPublicAddress:  dta.NoPublicAddress,
 			NoHostAddress:    dta.NoHostAddress,
+			Firmware:         dta.Firmware,
+			Tpm:              dta.Tpm,
+		}
+
+		if schd != nil {
+			nde, errData, err := schd.ScheduleInstance(db, inst)
+			if err != nil {
+				utils.AbortWithError(c, 500, err)
+				return
+			}
+
+			if errData != nil {
+				c.JSON(400, errData)
+				return
+			}
+
+			inst.Node = nde.Id
+			inst.Zone = nde.Zone
 		}
 
 		errData, err := inst.Validate(db)
@@ -377,6 +500,7 @@ func instancesPut(c *gin.Context) {
 
 	if dta.State != instance.Start {
 		doc["restart"] = false
+		doc["restart_reason"] = ""
 		doc["restart_block_ip"] = false
 	}
 
@@ -0,0 +1,31 @@
+package uhandlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+	"github.com/pritunl/pritunl-cloud/database"
+	"github.com/pritunl/pritunl-cloud/instance"
+	"github.com/pritunl/pritunl-cloud/utils"
+)
+
+func metadataInstanceGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	tknOrg := c.MustGet("organization").(primitive.ObjectID)
+	tknInst := c.MustGet("instance").(primitive.ObjectID)
+
+	inst, err := instance.GetOrg(db, tknOrg, tknInst)
+	if err != nil {
+		switch err.(type) {
+		case *database.NotFoundError:
+			utils.AbortWithStatus(c, 404)
+			break
+		default:
+			utils.AbortWithError(c, 500, err)
+		}
+		return
+	}
+
+	inst.Json()
+
+	c.JSON(200, inst)
+}
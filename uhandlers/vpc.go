@@ -18,13 +18,23 @@ import (
 )
 
 type vpcData struct {
-	Id         primitive.ObjectID `json:"id"`
-	Name       string             `json:"name"`
-	Network    string             `json:"network"`
-	Subnets    []*vpc.Subnet      `json:"subnets"`
-	Datacenter primitive.ObjectID `json:"datacenter"`
-	Routes     []*vpc.Route       `json:"routes"`
-	LinkUris   []string           `json:"link_uris"`
+	Id            primitive.ObjectID `json:"id"`
+	Name          string             `json:"name"`
+	Network       string             `json:"network"`
+	Subnets       []*vpc.Subnet      `json:"subnets"`
+	Datacenter    primitive.ObjectID `json:"datacenter"`
+	Routes        []*vpc.Route       `json:"routes"`
+	LinkUris      []string           `json:"link_uris"`
+	Nameservers   []string           `json:"nameservers"`
+	SearchDomains []string           `json:"search_domains"`
+	NtpServers    []string           `json:"ntp_servers"`
+	Mtu           int                `json:"mtu"`
+	PrivateDns    bool               `json:"private_dns"`
+}
+
+type vpcIpData struct {
+	Subnet  primitive.ObjectID `json:"subnet"`
+	Address string             `json:"address"`
 }
 
 type vpcsData struct {
@@ -80,6 +90,11 @@ func vpcPut(c *gin.Context) {
 	vc.Routes = data.Routes
 	vc.Subnets = data.Subnets
 	vc.LinkUris = data.LinkUris
+	vc.Nameservers = data.Nameservers
+	vc.SearchDomains = data.SearchDomains
+	vc.NtpServers = data.NtpServers
+	vc.Mtu = data.Mtu
+	vc.PrivateDns = data.PrivateDns
 
 	fields := set.NewSet(
 		"state",
@@ -87,6 +102,11 @@ func vpcPut(c *gin.Context) {
 		"routes",
 		"subnets",
 		"link_uris",
+		"nameservers",
+		"search_domains",
+		"ntp_servers",
+		"mtu",
+		"private_dns",
 	)
 
 	errData, err := vc.Validate(db)
@@ -152,13 +172,18 @@ func vpcPost(c *gin.Context) {
 	}
 
 	vc := &vpc.Vpc{
-		Name:         data.Name,
-		Network:      data.Network,
-		Subnets:      data.Subnets,
-		Organization: userOrg,
-		Datacenter:   data.Datacenter,
-		Routes:       data.Routes,
-		LinkUris:     data.LinkUris,
+		Name:          data.Name,
+		Network:       data.Network,
+		Subnets:       data.Subnets,
+		Organization:  userOrg,
+		Datacenter:    data.Datacenter,
+		Routes:        data.Routes,
+		LinkUris:      data.LinkUris,
+		Nameservers:   data.Nameservers,
+		SearchDomains: data.SearchDomains,
+		NtpServers:    data.NtpServers,
+		Mtu:           data.Mtu,
+		PrivateDns:    data.PrivateDns,
 	}
 
 	vc.InitVpc()
@@ -357,6 +382,119 @@ func vpcRoutesPut(c *gin.Context) {
 	c.JSON(200, vc)
 }
 
+func vpcIpsGet(c *gin.Context) {
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	subnetId, _ := utils.ParseObjectId(c.Query("subnet"))
+
+	vc, err := vpc.GetOrg(db, userOrg, vpcId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	vcIps, err := vpc.GetIps(db, vc.Id, subnetId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	for _, vcIp := range vcIps {
+		vcIp.Json()
+	}
+
+	c.JSON(200, vcIps)
+}
+
+func vpcIpPost(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+	data := &vpcIpData{}
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	err := c.Bind(data)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	vc, err := vpc.GetOrg(db, userOrg, vpcId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	vcIp, errData, err := vc.ReserveIp(db, data.Subnet, data.Address)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	if errData != nil {
+		c.JSON(400, errData)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	vcIp.Json()
+
+	c.JSON(200, vcIp)
+}
+
+func vpcIpDelete(c *gin.Context) {
+	if demo.Blocked(c) {
+		return
+	}
+
+	db := c.MustGet("db").(*database.Database)
+	userOrg := c.MustGet("organization").(primitive.ObjectID)
+
+	vpcId, ok := utils.ParseObjectId(c.Param("vpc_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	vpcIpId, ok := utils.ParseObjectId(c.Param("ip_id"))
+	if !ok {
+		utils.AbortWithStatus(c, 400)
+		return
+	}
+
+	vc, err := vpc.GetOrg(db, userOrg, vpcId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	err = vpc.ReleaseIp(db, vc.Id, vpcIpId)
+	if err != nil {
+		utils.AbortWithError(c, 500, err)
+		return
+	}
+
+	event.PublishDispatch(db, "vpc.change")
+
+	c.JSON(200, nil)
+}
+
 func vpcsGet(c *gin.Context) {
 	db := c.MustGet("db").(*database.Database)
 	userOrg := c.MustGet("organization").(primitive.ObjectID)
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/dropbox/godropbox/errors"
+	"github.com/pritunl/pritunl-cloud/errortypes"
+	"golang.org/x/sys/unix"
+)
+
+func setNamespace(file *os.File) (err error) {
+	err = unix.Setns(int(file.Fd()), unix.CLONE_NEWNET)
+	if err != nil {
+		err = &errortypes.ExecError{
+			errors.Wrap(err, "utils: Failed to set namespace"),
+		}
+		return
+	}
+
+	return
+}
+
+// Sockets created in the callback remain in the namespace after the
+// thread is restored to the host namespace
+func ExecNamespace(namespace string, fn func() error) (err error) {
+	// Namespace is per thread, thread must stay locked until the
+	// original namespace is restored
+	runtime.LockOSThread()
+
+	hostNs, err := os.Open(fmt.Sprintf("/proc/%d/task/%d/ns/net",
+		os.Getpid(), syscall.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "utils: Failed to open host namespace"),
+		}
+		return
+	}
+	defer hostNs.Close()
+
+	ns, err := os.Open(path.Join("/var/run/netns", namespace))
+	if err != nil {
+		runtime.UnlockOSThread()
+		err = &errortypes.ReadError{
+			errors.Wrap(err, "utils: Failed to open namespace"),
+		}
+		return
+	}
+	defer ns.Close()
+
+	err = setNamespace(ns)
+	if err != nil {
+		runtime.UnlockOSThread()
+		return
+	}
+
+	err = fn()
+
+	e := setNamespace(hostNs)
+	if e != nil {
+		// Leave thread locked to discard it when the goroutine exits
+		logrus.WithFields(logrus.Fields{
+			"net_namespace": namespace,
+			"error":         e,
+		}).Error("utils: Failed to restore host namespace")
+		return
+	}
+
+	runtime.UnlockOSThread()
+
+	return
+}
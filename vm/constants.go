@@ -10,3 +10,9 @@ const (
 	Bridge       = "bridge"
 	Vxlan        = "vxlan"
 )
+
+const (
+	Bios       = "bios"
+	Uefi       = "uefi"
+	SecureUefi = "secure_uefi"
+)
@@ -17,12 +17,17 @@ type VirtualMachine struct {
 	Image           primitive.ObjectID `json:"image"`
 	Processors      int                `json:"processors"`
 	Memory          int                `json:"memory"`
+	MaxProcessors   int                `json:"max_processors"`
+	MaxMemory       int                `json:"max_memory"`
+	Resized         bool               `json:"resized"`
 	Vnc             bool               `json:"vnc"`
 	VncDisplay      int                `json:"vnc_display"`
 	Disks           []*Disk            `json:"disks"`
 	NetworkAdapters []*NetworkAdapter  `json:"network_adapters"`
 	NoPublicAddress bool               `json:"no_public_address"`
 	NoHostAddress   bool               `json:"no_host_address"`
+	Firmware        string             `json:"firmware"`
+	Tpm             bool               `json:"tpm"`
 	UsbDevices      []*UsbDevice       `json:"usb_devices"`
 }
 
@@ -51,6 +56,7 @@ type NetworkAdapter struct {
 	MacAddress string             `json:"mac_address"`
 	Vpc        primitive.ObjectID `json:"vpc"`
 	Subnet     primitive.ObjectID `json:"subnet"`
+	StaticIp   string             `json:"static_ip,omitempty"`
 	IpAddress  string             `json:"ip_address,omitempty"`
 	IpAddress6 string             `json:"ip_address6,omitempty"`
 }
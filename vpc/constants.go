@@ -4,3 +4,9 @@ const (
 	Instance = "instance"
 	Gateway  = "gateway"
 )
+
+const (
+	MaxNameservers = 3
+	MinMtu         = 576
+	MaxMtu         = 9000
+)
@@ -8,13 +8,20 @@ import (
 )
 
 type VpcIp struct {
-	Id       primitive.ObjectID `bson:"_id,omitempty"`
-	Vpc      primitive.ObjectID `bson:"vpc"`
-	Subnet   primitive.ObjectID `bson:"subnet"`
-	Ip       int64              `bson:"ip"`
-	Instance primitive.ObjectID `bson:"instance"`
+	Id       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Vpc      primitive.ObjectID `bson:"vpc" json:"vpc"`
+	Subnet   primitive.ObjectID `bson:"subnet" json:"subnet"`
+	Ip       int64              `bson:"ip" json:"-"`
+	Address  string             `bson:"-" json:"address"`
+	Instance primitive.ObjectID `bson:"instance" json:"instance"`
+	Reserved bool               `bson:"reserved" json:"reserved"`
 }
 
 func (i *VpcIp) GetIps() (net.IP, net.IP) {
 	return utils.IpIndex2Ip(i.Ip)
 }
+
+func (i *VpcIp) Json() {
+	addr, _ := i.GetIps()
+	i.Address = addr.String()
+}
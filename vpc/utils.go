@@ -1,6 +1,8 @@
 package vpc
 
 import (
+	"regexp"
+
 	"github.com/dropbox/godropbox/container/set"
 	"github.com/pritunl/mongo-go-driver/bson"
 	"github.com/pritunl/mongo-go-driver/bson/primitive"
@@ -9,6 +11,16 @@ import (
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
+var (
+	hostnameReg = regexp.MustCompile(
+		"^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\\.)*" +
+			"[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$")
+)
+
+func validHostname(name string) bool {
+	return len(name) <= 253 && hostnameReg.MatchString(name)
+}
+
 func Get(db *database.Database, vcId primitive.ObjectID) (
 	vc *Vpc, err error) {
 
@@ -448,3 +460,76 @@ func RemoveInstanceIp(db *database.Database, instId,
 
 	return
 }
+
+func GetIps(db *database.Database, vcId, subId primitive.ObjectID) (
+	vcIps []*VpcIp, err error) {
+
+	coll := db.VpcsIp()
+	vcIps = []*VpcIp{}
+
+	query := bson.M{
+		"vpc": vcId,
+	}
+	if !subId.IsZero() {
+		query["subnet"] = subId
+	}
+
+	cursor, err := coll.Find(
+		db,
+		query,
+		&options.FindOptions{
+			Sort: &bson.D{
+				{"ip", 1},
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+	defer cursor.Close(db)
+
+	for cursor.Next(db) {
+		vcIp := &VpcIp{}
+		err = cursor.Decode(vcIp)
+		if err != nil {
+			err = database.ParseError(err)
+			return
+		}
+
+		vcIps = append(vcIps, vcIp)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
+func ReleaseIp(db *database.Database, vcId, vcIpId primitive.ObjectID) (
+	err error) {
+
+	coll := db.VpcsIp()
+
+	_, err = coll.UpdateOne(
+		db,
+		&bson.M{
+			"_id": vcIpId,
+			"vpc": vcId,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"reserved": false,
+			},
+		},
+	)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
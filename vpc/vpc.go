@@ -17,7 +17,9 @@ import (
 	"github.com/pritunl/pritunl-cloud/database"
 	"github.com/pritunl/pritunl-cloud/errortypes"
 	"github.com/pritunl/pritunl-cloud/node"
+	"github.com/pritunl/pritunl-cloud/organization"
 	"github.com/pritunl/pritunl-cloud/requires"
+	"github.com/pritunl/pritunl-cloud/settings"
 	"github.com/pritunl/pritunl-cloud/utils"
 )
 
@@ -40,6 +42,11 @@ type Vpc struct {
 	LinkUris      []string           `bson:"link_uris" json:"link_uris"`
 	LinkNode      primitive.ObjectID `bson:"link_node,omitempty" json:"link_node"`
 	LinkTimestamp time.Time          `bson:"link_timestamp" json:"link_timestamp"`
+	Nameservers   []string           `bson:"nameservers" json:"nameservers"`
+	SearchDomains []string           `bson:"search_domains" json:"search_domains"`
+	NtpServers    []string           `bson:"ntp_servers" json:"ntp_servers"`
+	Mtu           int                `bson:"mtu" json:"mtu"`
+	PrivateDns    bool               `bson:"private_dns" json:"private_dns"`
 	curSubnets    []*Subnet          `bson:"-" json:"-"`
 }
 
@@ -276,6 +283,114 @@ func (v *Vpc) Validate(db *database.Database) (
 		}
 	}
 
+	errData = v.validateDhcp(settings.Hypervisor.JumboMtu)
+	if errData != nil {
+		return
+	}
+
+	if v.Id.IsZero() {
+		errData, err = organization.CheckQuota(db, v.Organization,
+			&organization.Usage{
+				Vpcs: 1,
+			})
+		if err != nil {
+			return
+		}
+
+		if errData != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (v *Vpc) validateDhcp(hostMtu int) (errData *errortypes.ErrorData) {
+	nameservers := []string{}
+	for _, nameserver := range v.Nameservers {
+		nameserver = strings.TrimSpace(nameserver)
+		if nameserver == "" {
+			continue
+		}
+
+		ip := net.ParseIP(nameserver)
+		if ip == nil {
+			errData = &errortypes.ErrorData{
+				Error:   "nameserver_invalid",
+				Message: "Nameserver address invalid",
+			}
+			return
+		}
+
+		nameservers = append(nameservers, ip.String())
+	}
+
+	if len(nameservers) > MaxNameservers {
+		errData = &errortypes.ErrorData{
+			Error:   "nameservers_invalid",
+			Message: "Too many nameservers",
+		}
+		return
+	}
+	v.Nameservers = nameservers
+
+	searchDomains := []string{}
+	for _, searchDomain := range v.SearchDomains {
+		searchDomain = strings.Trim(strings.TrimSpace(searchDomain), ".")
+		if searchDomain == "" {
+			continue
+		}
+
+		if !validHostname(searchDomain) {
+			errData = &errortypes.ErrorData{
+				Error:   "search_domain_invalid",
+				Message: "Search domain invalid",
+			}
+			return
+		}
+
+		searchDomains = append(searchDomains, searchDomain)
+	}
+	v.SearchDomains = searchDomains
+
+	ntpServers := []string{}
+	for _, ntpServer := range v.NtpServers {
+		ntpServer = strings.TrimSpace(ntpServer)
+		if ntpServer == "" {
+			continue
+		}
+
+		ip := net.ParseIP(ntpServer)
+		if ip != nil {
+			ntpServer = ip.String()
+		} else if !validHostname(ntpServer) {
+			errData = &errortypes.ErrorData{
+				Error:   "ntp_server_invalid",
+				Message: "NTP server invalid",
+			}
+			return
+		}
+
+		ntpServers = append(ntpServers, ntpServer)
+	}
+	v.NtpServers = ntpServers
+
+	if v.Mtu != 0 && (v.Mtu < MinMtu || v.Mtu > MaxMtu) {
+		errData = &errortypes.ErrorData{
+			Error:   "mtu_invalid",
+			Message: "MTU size invalid",
+		}
+		return
+	}
+
+	if v.Mtu > hostMtu {
+		errData = &errortypes.ErrorData{
+			Error:   "mtu_invalid",
+			Message: "MTU size exceeds host network MTU",
+		}
+		return
+	}
+
 	return
 }
 
@@ -474,6 +589,9 @@ func (v *Vpc) GetIp(db *database.Database,
 				"vpc":      v.Id,
 				"subnet":   subId,
 				"instance": nil,
+				"reserved": &bson.M{
+					"$ne": true,
+				},
 			},
 			&bson.M{
 				"$set": &bson.M{
@@ -565,6 +683,194 @@ func (v *Vpc) GetIp(db *database.Database,
 	return
 }
 
+func (v *Vpc) ParseIp(subId primitive.ObjectID, addr string) (
+	index int64, errData *errortypes.ErrorData) {
+
+	subnet := v.GetSubnet(subId)
+	if subnet == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "vpc_subnet_missing",
+			Message: "VPC subnet does not exist",
+		}
+		return
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil || ip.To4() == nil {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_invalid",
+			Message: "Private IP address is invalid",
+		}
+		return
+	}
+
+	index, err := utils.Int2IpIndex(utils.IpAddress2Int(ip.To4()))
+	if err != nil {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_invalid",
+			Message: "Private IP address must be an even address",
+		}
+		return
+	}
+
+	start, stop, err := subnet.GetIndexRange()
+	if err != nil || index < start || index > stop {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_invalid",
+			Message: "Private IP address not in VPC subnet",
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) CheckIp(db *database.Database, subId,
+	instId primitive.ObjectID, addr string) (
+	errData *errortypes.ErrorData, err error) {
+
+	index, errData := v.ParseIp(subId, addr)
+	if errData != nil {
+		return
+	}
+
+	coll := db.VpcsIp()
+	vpcIp := &VpcIp{}
+
+	err = coll.FindOne(db, &bson.M{
+		"vpc": v.Id,
+		"ip":  index,
+	}).Decode(vpcIp)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.NotFoundError); ok {
+			err = nil
+		}
+		return
+	}
+
+	if !vpcIp.Instance.IsZero() && vpcIp.Instance != instId {
+		errData = &errortypes.ErrorData{
+			Error:   "private_ip_in_use",
+			Message: "Private IP address in use by another instance",
+		}
+		return
+	}
+
+	return
+}
+
+func (v *Vpc) GetInstanceIp(db *database.Database,
+	subId, instId primitive.ObjectID, staticIp string) (
+	instIp, gateIp net.IP, err error) {
+
+	if staticIp == "" {
+		instIp, gateIp, err = v.GetIp(db, subId, instId)
+		return
+	}
+
+	index, errData := v.ParseIp(subId, staticIp)
+	if errData != nil {
+		err = &errortypes.ParseError{
+			errors.New("vpc: " + errData.Message),
+		}
+		return
+	}
+
+	coll := db.VpcsIp()
+
+	_, err = coll.UpdateMany(db, &bson.M{
+		"vpc":      v.Id,
+		"instance": instId,
+		"ip": &bson.M{
+			"$ne": index,
+		},
+	}, &bson.M{
+		"$set": &bson.M{
+			"instance": nil,
+		},
+	})
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	vpcIp := &VpcIp{}
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetReturnDocument(options.After)
+	opts.SetUpsert(true)
+
+	err = coll.FindOneAndUpdate(
+		db,
+		&bson.M{
+			"vpc": v.Id,
+			"ip":  index,
+			"instance": &bson.M{
+				"$in": []interface{}{nil, instId},
+			},
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"subnet":   subId,
+				"instance": instId,
+			},
+		},
+		opts,
+	).Decode(vpcIp)
+	if err != nil {
+		err = database.ParseError(err)
+		if _, ok := err.(*database.DuplicateKeyError); ok {
+			err = &errortypes.NotFoundError{
+				errors.New("vpc: Static address in use"),
+			}
+		}
+		return
+	}
+
+	instIp, gateIp = vpcIp.GetIps()
+
+	return
+}
+
+func (v *Vpc) ReserveIp(db *database.Database, subId primitive.ObjectID,
+	addr string) (vpcIp *VpcIp, errData *errortypes.ErrorData, err error) {
+
+	index, errData := v.ParseIp(subId, addr)
+	if errData != nil {
+		return
+	}
+
+	coll := db.VpcsIp()
+	vpcIp = &VpcIp{}
+	opts := &options.FindOneAndUpdateOptions{}
+	opts.SetReturnDocument(options.After)
+	opts.SetUpsert(true)
+
+	err = coll.FindOneAndUpdate(
+		db,
+		&bson.M{
+			"vpc": v.Id,
+			"ip":  index,
+		},
+		&bson.M{
+			"$set": &bson.M{
+				"subnet":   subId,
+				"reserved": true,
+			},
+			"$setOnInsert": &bson.M{
+				"instance": nil,
+			},
+		},
+		opts,
+	).Decode(vpcIp)
+	if err != nil {
+		err = database.ParseError(err)
+		return
+	}
+
+	return
+}
+
 func (v *Vpc) GetIp6(addr net.IP) net.IP {
 	netHash := md5.New()
 	netHash.Write(v.Id[:])
@@ -0,0 +1,338 @@
+package vpc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pritunl/mongo-go-driver/bson/primitive"
+)
+
+func TestValidateDhcp(t *testing.T) {
+	tests := []struct {
+		name              string
+		vc                *Vpc
+		hostMtu           int
+		wantErr           string
+		wantNameservers   []string
+		wantSearchDomains []string
+		wantNtpServers    []string
+	}{
+		{
+			name:              "empty",
+			vc:                &Vpc{},
+			hostMtu:           1500,
+			wantNameservers:   []string{},
+			wantSearchDomains: []string{},
+			wantNtpServers:    []string{},
+		},
+		{
+			name: "normalized",
+			vc: &Vpc{
+				Nameservers: []string{
+					" 8.8.8.8 ",
+					"",
+					"2001:4860:0:0:0:0:0:8888",
+				},
+				SearchDomains: []string{
+					" .example.com. ",
+					"  ",
+					"internal",
+				},
+				NtpServers: []string{
+					"pool.ntp.org",
+					" 10.0.0.1",
+					"",
+				},
+				Mtu: 1400,
+			},
+			hostMtu: 1500,
+			wantNameservers: []string{
+				"8.8.8.8",
+				"2001:4860::8888",
+			},
+			wantSearchDomains: []string{
+				"example.com",
+				"internal",
+			},
+			wantNtpServers: []string{
+				"pool.ntp.org",
+				"10.0.0.1",
+			},
+		},
+		{
+			name: "nameserver hostname",
+			vc: &Vpc{
+				Nameservers: []string{"dns.example.com"},
+			},
+			hostMtu: 1500,
+			wantErr: "nameserver_invalid",
+		},
+		{
+			name: "nameserver limit",
+			vc: &Vpc{
+				Nameservers: []string{
+					"1.1.1.1",
+					"",
+					"8.8.8.8",
+					"9.9.9.9",
+				},
+			},
+			hostMtu:           1500,
+			wantNameservers:   []string{"1.1.1.1", "8.8.8.8", "9.9.9.9"},
+			wantSearchDomains: []string{},
+			wantNtpServers:    []string{},
+		},
+		{
+			name: "too many nameservers",
+			vc: &Vpc{
+				Nameservers: []string{
+					"1.1.1.1",
+					"1.0.0.1",
+					"8.8.8.8",
+					"9.9.9.9",
+				},
+			},
+			hostMtu: 1500,
+			wantErr: "nameservers_invalid",
+		},
+		{
+			name: "search domain invalid",
+			vc: &Vpc{
+				SearchDomains: []string{"example..com"},
+			},
+			hostMtu: 1500,
+			wantErr: "search_domain_invalid",
+		},
+		{
+			name: "search domain hyphen",
+			vc: &Vpc{
+				SearchDomains: []string{"-example.com"},
+			},
+			hostMtu: 1500,
+			wantErr: "search_domain_invalid",
+		},
+		{
+			name: "ntp server invalid",
+			vc: &Vpc{
+				NtpServers: []string{"ntp_server"},
+			},
+			hostMtu: 1500,
+			wantErr: "ntp_server_invalid",
+		},
+		{
+			name: "mtu minimum",
+			vc: &Vpc{
+				Mtu: MinMtu,
+			},
+			hostMtu:           1500,
+			wantNameservers:   []string{},
+			wantSearchDomains: []string{},
+			wantNtpServers:    []string{},
+		},
+		{
+			name: "mtu below minimum",
+			vc: &Vpc{
+				Mtu: MinMtu - 1,
+			},
+			hostMtu: 1500,
+			wantErr: "mtu_invalid",
+		},
+		{
+			name: "mtu above maximum",
+			vc: &Vpc{
+				Mtu: MaxMtu + 1,
+			},
+			hostMtu: MaxMtu + 100,
+			wantErr: "mtu_invalid",
+		},
+		{
+			name: "mtu host",
+			vc: &Vpc{
+				Mtu: 1500,
+			},
+			hostMtu:           1500,
+			wantNameservers:   []string{},
+			wantSearchDomains: []string{},
+			wantNtpServers:    []string{},
+		},
+		{
+			name: "mtu above host",
+			vc: &Vpc{
+				Mtu: 1501,
+			},
+			hostMtu: 1500,
+			wantErr: "mtu_invalid",
+		},
+		{
+			name: "mtu jumbo",
+			vc: &Vpc{
+				Mtu: 9000,
+			},
+			hostMtu:           9000,
+			wantNameservers:   []string{},
+			wantSearchDomains: []string{},
+			wantNtpServers:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		errData := test.vc.validateDhcp(test.hostMtu)
+		if test.wantErr != "" {
+			if errData == nil {
+				t.Errorf("%s: expected %s", test.name, test.wantErr)
+			} else if errData.Error != test.wantErr {
+				t.Errorf("%s: error %s, want %s",
+					test.name, errData.Error, test.wantErr)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			continue
+		}
+
+		if !reflect.DeepEqual(test.vc.Nameservers, test.wantNameservers) {
+			t.Errorf("%s: nameservers %v, want %v", test.name,
+				test.vc.Nameservers, test.wantNameservers)
+		}
+		if !reflect.DeepEqual(test.vc.SearchDomains,
+			test.wantSearchDomains) {
+
+			t.Errorf("%s: search domains %v, want %v", test.name,
+				test.vc.SearchDomains, test.wantSearchDomains)
+		}
+		if !reflect.DeepEqual(test.vc.NtpServers, test.wantNtpServers) {
+			t.Errorf("%s: ntp servers %v, want %v", test.name,
+				test.vc.NtpServers, test.wantNtpServers)
+		}
+	}
+}
+
+func TestParseIp(t *testing.T) {
+	subId := primitive.NewObjectID()
+	vc := &Vpc{
+		Subnets: []*Subnet{
+			{
+				Id:      primitive.NewObjectID(),
+				Name:    "other",
+				Network: "10.0.1.0/24",
+			},
+			{
+				Id:      subId,
+				Name:    "primary",
+				Network: "10.0.0.0/24",
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		subId     primitive.ObjectID
+		addr      string
+		wantErr   string
+		wantIndex int64
+	}{
+		{
+			name:    "zero subnet",
+			subId:   primitive.NilObjectID,
+			addr:    "10.0.0.10",
+			wantErr: "vpc_subnet_missing",
+		},
+		{
+			name:    "unknown subnet",
+			subId:   primitive.NewObjectID(),
+			addr:    "10.0.0.10",
+			wantErr: "vpc_subnet_missing",
+		},
+		{
+			name:    "empty",
+			subId:   subId,
+			addr:    "",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:    "invalid",
+			subId:   subId,
+			addr:    "10.0.0",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:    "ipv6",
+			subId:   subId,
+			addr:    "fd00::a",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:    "odd",
+			subId:   subId,
+			addr:    "10.0.0.11",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:    "network address",
+			subId:   subId,
+			addr:    "10.0.0.0",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:    "last address",
+			subId:   subId,
+			addr:    "10.0.0.254",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:    "other subnet",
+			subId:   subId,
+			addr:    "10.0.1.10",
+			wantErr: "private_ip_invalid",
+		},
+		{
+			name:      "first",
+			subId:     subId,
+			addr:      "10.0.0.2",
+			wantIndex: (10<<24 + 2) / 2,
+		},
+		{
+			name:      "middle",
+			subId:     subId,
+			addr:      "10.0.0.100",
+			wantIndex: (10<<24 + 100) / 2,
+		},
+		{
+			name:      "last",
+			subId:     subId,
+			addr:      "10.0.0.252",
+			wantIndex: (10<<24 + 252) / 2,
+		},
+		{
+			name:      "mapped",
+			subId:     subId,
+			addr:      "::ffff:10.0.0.20",
+			wantIndex: (10<<24 + 20) / 2,
+		},
+	}
+
+	for _, test := range tests {
+		index, errData := vc.ParseIp(test.subId, test.addr)
+		if test.wantErr != "" {
+			if errData == nil {
+				t.Errorf("%s: expected %s", test.name, test.wantErr)
+			} else if errData.Error != test.wantErr {
+				t.Errorf("%s: error %s, want %s",
+					test.name, errData.Error, test.wantErr)
+			}
+			continue
+		}
+
+		if errData != nil {
+			t.Errorf("%s: unexpected error %s", test.name, errData.Error)
+			continue
+		}
+
+		if index != test.wantIndex {
+			t.Errorf("%s: index %d, want %d",
+				test.name, index, test.wantIndex)
+		}
+	}
+}
@@ -218,6 +218,19 @@ export default class ImageDetailed extends React.Component<Props, State> {
 				break;
 		}
 
+		let consistency = 'Unknown';
+		switch (this.props.image.consistency) {
+			case 'offline':
+				consistency = 'Offline';
+				break;
+			case 'application':
+				consistency = 'Application Consistent';
+				break;
+			case 'crash':
+				consistency = 'Crash Consistent';
+				break;
+		}
+
 		return <td
 			className="bp3-cell"
 			colSpan={5}
@@ -304,6 +317,10 @@ export default class ImageDetailed extends React.Component<Props, State> {
 								label: 'Storage Class',
 								value: storageClass,
 							},
+							{
+								label: 'Consistency',
+								value: consistency,
+							},
 							{
 								label: 'Last Modified',
 								value: MiscUtils.formatDate(
@@ -589,6 +589,13 @@ export default class InstanceDetailed extends React.Component<Props, State> {
 				label: 'Node',
 				value: info.node || 'None',
 			},
+			{
+				label: 'Placement Group',
+				value: info.placement ? info.placement + (
+					info.placement_violation ? ' (Violation)' : '') : 'None',
+				valueClass: info.placement_violation ?
+					'bp3-text-intent-danger' : '',
+			},
 			{
 				label: 'State',
 				value: (this.props.instance.state || 'None') + ':' + (
@@ -633,8 +640,9 @@ export default class InstanceDetailed extends React.Component<Props, State> {
 		if (this.props.instance.vnc) {
 			fields.push(
 				{
-					label: 'VNC Port',
-					value: this.props.instance.vnc_display + 5900,
+					label: 'VNC WebSocket',
+					value: '/instance/' + this.props.instance.id + '/vnc',
+					copy: true,
 				},
 				{
 					label: 'VNC Password',
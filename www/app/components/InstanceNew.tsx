@@ -18,6 +18,7 @@ import NodesZoneStore from '../stores/NodesZoneStore';
 import InstanceLicense from './InstanceLicense';
 import PageInput from './PageInput';
 import PageInputButton from './PageInputButton';
+import PageTextArea from './PageTextArea';
 import PageCreate from './PageCreate';
 import PageSelect from './PageSelect';
 import PageSwitch from "./PageSwitch";
@@ -600,6 +601,17 @@ export default class InstanceNew extends React.Component<Props, State> {
 						>
 							{subnetSelect}
 						</PageSelect>
+						<PageInput
+							label="Static Private IP"
+							help="Optional private IP address to assign from the subnet, leave blank to automatically allocate an address."
+							type="text"
+							placeholder="Automatic"
+							disabled={this.state.disabled}
+							value={instance.static_ip}
+							onChange={(val): void => {
+								this.set('static_ip', val);
+							}}
+						/>
 						<PageSelect
 							disabled={this.state.disabled || !hasNodes}
 							label="Node"
@@ -699,6 +711,16 @@ export default class InstanceNew extends React.Component<Props, State> {
 							}}
 							onSubmit={this.onAddNetworkRole}
 						/>
+						<PageTextArea
+							label="User Data"
+							help="Optional cloud-init user data merged with the generated configuration. Must begin with #cloud-config or a #! script interpreter."
+							placeholder="#cloud-config"
+							rows={6}
+							value={instance.user_data}
+							onChange={(val: string): void => {
+								this.set('user_data', val);
+							}}
+						/>
 						<PageNumInput
 							label="Disk Size"
 							help="Instance memory size in megabytes."
@@ -296,6 +296,16 @@ export default class Settings extends React.Component<{}, State> {
 								!this.state.settings.elastic_proxy_requests);
 						}}
 					/>
+					<PageInput
+						label="Metrics Token"
+						help="Bearer token required to access the Prometheus /metrics endpoint on each node. Leave blank to disable the metrics endpoint."
+						type="text"
+						placeholder="Metrics token"
+						value={this.state.settings.metrics_token}
+						onChange={(val): void => {
+							this.set('metrics_token', val);
+						}}
+					/>
 				</PagePanel>
 			</PageSplit>
 			<PageSave
@@ -10,6 +10,8 @@ import VpcRoute from './VpcRoute';
 import VpcSubnet from './VpcSubnet';
 import VpcLinkUri from './VpcLinkUri';
 import PageInput from './PageInput';
+import PageNumInput from './PageNumInput';
+import PageSwitch from './PageSwitch';
 import PageInfo from './PageInfo';
 import PageSave from './PageSave';
 import ConfirmButton from './ConfirmButton';
@@ -715,6 +717,60 @@ export default class VpcDetailed extends React.Component<Props, State> {
 					<div style={css.list}>
 						{routes}
 					</div>
+					<PageInput
+						label="DNS Servers"
+						help="Comma separated list of DNS servers provided to instances. Defaults to 8.8.8.8 and 8.8.4.4 when empty. Changes take effect on next instance start."
+						type="text"
+						placeholder="Enter DNS servers"
+						value={(vpc.nameservers || []).join(', ')}
+						onChange={(val): void => {
+							this.set('nameservers', val.split(',').map(
+								(item: string): string => item.trim()));
+						}}
+					/>
+					<PageInput
+						label="Search Domains"
+						help="Comma separated list of DNS search domains provided to instances."
+						type="text"
+						placeholder="Enter search domains"
+						value={(vpc.search_domains || []).join(', ')}
+						onChange={(val): void => {
+							this.set('search_domains', val.split(',').map(
+								(item: string): string => item.trim()));
+						}}
+					/>
+					<PageInput
+						label="NTP Servers"
+						help="Comma separated list of NTP servers configured on instances."
+						type="text"
+						placeholder="Enter NTP servers"
+						value={(vpc.ntp_servers || []).join(', ')}
+						onChange={(val): void => {
+							this.set('ntp_servers', val.split(',').map(
+								(item: string): string => item.trim()));
+						}}
+					/>
+					<PageNumInput
+						label="MTU Override"
+						help="Override the instance interface MTU, set to 0 to use the node default. Values larger than the node network MTU are ignored."
+						min={0}
+						minorStepSize={1}
+						stepSize={100}
+						majorStepSize={500}
+						selectAllOnFocus={true}
+						onChange={(val: number): void => {
+							this.set('mtu', val);
+						}}
+						value={vpc.mtu || 0}
+					/>
+					<PageSwitch
+						label="Private DNS"
+						help="Use the node DNS resolver on instances to resolve instance names in the form instance.vpc.internal. Other queries are forwarded to the DNS servers above. Changes take effect on next instance start."
+						checked={!!vpc.private_dns}
+						onToggle={(): void => {
+							this.set('private_dns', !vpc.private_dns);
+						}}
+					/>
 				</div>
 			</div>
 			<PageSave
@@ -17,6 +17,7 @@ export interface Image {
 	etag?: string;
 	last_modified?: string;
 	storage_class?: string;
+	consistency?: string;
 }
 
 export interface Filter {
@@ -10,9 +10,11 @@ export interface Instance {
 	organization?: string;
 	zone?: string;
 	node?: string;
+	placement_group?: string;
 	image?: string;
 	image_backing?: boolean;
 	status?: string;
+	restart_reason?: string;
 	uptime?: string;
 	state?: string;
 	vm_state?: string;
@@ -29,6 +31,7 @@ export interface Instance {
 	memory?: number;
 	processors?: number;
 	network_roles?: string[];
+	user_data?: string;
 	usb_devices?: UsbDevice[];
 	vnc?: boolean;
 	vnc_password?: string;
@@ -36,8 +39,12 @@ export interface Instance {
 	domain?: string;
 	no_public_address?: boolean;
 	no_host_address?: boolean;
+	firmware?: string;
+	tpm?: boolean;
 	vpc?: string;
 	subnet?: string;
+	static_ip?: string;
+	adapters?: Adapter[];
 	count?: number;
 	info?: Info;
 }
@@ -53,6 +60,12 @@ export interface Filter {
 	vpc?: string;
 }
 
+export interface Adapter {
+	vpc?: string;
+	subnet?: string;
+	static_ip?: string;
+}
+
 export interface UsbDevice {
 	name?: string;
 	vendor?: string;
@@ -65,6 +78,8 @@ export interface Info {
 	authorities?: string[];
 	disks?: string[];
 	usb_devices?: UsbDevice[];
+	placement?: string;
+	placement_violation?: boolean;
 }
 
 export type Instances = Instance[];
@@ -78,6 +78,7 @@ export interface Settings {
 	auth_user_max_duration: number;
 	elastic_address: string;
 	elastic_proxy_requests: boolean;
+	metrics_token: string;
 }
 
 export type SettingsRo = Readonly<Settings>;
@@ -17,6 +17,11 @@ export interface Vpc {
 	routes?: Route[];
 	link_uris?: string[];
 	link_node?: string;
+	nameservers?: string[];
+	search_domains?: string[];
+	ntp_servers?: string[];
+	mtu?: number;
+	private_dns?: boolean;
 }
 
 export interface Subnet {
@@ -30,6 +35,15 @@ export interface Route {
 	target?: string;
 }
 
+export interface VpcIp {
+	id?: string;
+	vpc?: string;
+	subnet?: string;
+	address?: string;
+	instance?: string;
+	reserved?: boolean;
+}
+
 export interface Filter {
 	id?: string;
 	name?: string;